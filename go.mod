@@ -5,6 +5,5 @@ go 1.24
 require (
 	github.com/disintegration/imaging v1.6.2
 	github.com/maruel/natural v1.1.1
+	golang.org/x/image v0.26.0
 )
-
-require golang.org/x/image v0.26.0 // indirect
@@ -9,6 +9,7 @@ import (
 	"os"
 	"path/filepath"
 	"rectpack2d/rectpack"
+	"rectpack2d/rectpack/render"
 	"runtime"
 	"sort"
 	"sync"
@@ -104,7 +105,8 @@ func GetImageBBox(img image.Image, alphaThreshold uint32) image.Rectangle {
 	return image.Rect(minX, minY, maxX+1, maxY+1)
 }
 
-func processImages(paths []string, options *Options) ([]rectpack.Size2D, []image.Rectangle, error) {
+// processImages 读取图片的尺寸和裁切区域，按形状嵌套时同时返回每个图片(以ID索引)的占用位图
+func processImages(paths []string, options *Options) ([]rectpack.Size2D, []image.Rectangle, map[int]*rectpack.Mask, error) {
 	if debugInfo.IsDebug {
 		start := time.Now() // 记录开始时间
 		defer func() {
@@ -114,6 +116,7 @@ func processImages(paths []string, options *Options) ([]rectpack.Size2D, []image
 	}
 	sourceRects := make([]image.Rectangle, len(paths))
 	sizes := make([]rectpack.Size2D, len(paths))
+	masks := make([]*rectpack.Mask, len(paths))
 	// 创建错误通道
 	errChan := make(chan error, len(paths))
 	// 创建互斥锁保护对errChan的并发访问
@@ -128,7 +131,7 @@ func processImages(paths []string, options *Options) ([]rectpack.Size2D, []image
 			mu.Unlock()
 			return
 		}
-		if options.IsTrimTransparent {
+		if options.IsTrimTransparent || options.MaskCell > 0 {
 			// 完全解码图片以分析透明区域
 			src, err := imaging.Decode(file)
 			file.Close()
@@ -141,10 +144,15 @@ func processImages(paths []string, options *Options) ([]rectpack.Size2D, []image
 			// 获取原始尺寸
 			origBounds := src.Bounds()
 			sourceRects[i] = origBounds
-			// 获取透明边界区域
-			trimRect := GetImageBBox(src, options.TransparencyThreshold)
-			sizes[i] = rectpack.NewSize2DByID(i, trimRect.Dx(), trimRect.Dy())
-			sourceRects[i] = trimRect
+			if options.IsTrimTransparent {
+				// 获取透明边界区域
+				sourceRects[i] = GetImageBBox(src, options.TransparencyThreshold)
+			}
+			sizes[i] = rectpack.NewSize2DByID(i, sourceRects[i].Dx(), sourceRects[i].Dy())
+			if options.MaskCell > 0 {
+				// 所有不完全透明的像素都参与碰撞，绘制时互不覆盖
+				masks[i] = rectpack.MaskFromImage(src, sourceRects[i], 0)
+			}
 		} else {
 			// 只解码图片头部以获取尺寸信息
 			cfg, _, err := image.DecodeConfig(file)
@@ -164,15 +172,22 @@ func processImages(paths []string, options *Options) ([]rectpack.Size2D, []image
 	close(errChan)
 	for err := range errChan {
 		if err != nil {
-			return nil, nil, err
+			return nil, nil, nil, err
+		}
+	}
+	var spriteMasks map[int]*rectpack.Mask
+	if options.MaskCell > 0 {
+		spriteMasks = make(map[int]*rectpack.Mask, len(masks))
+		for i, mask := range masks {
+			spriteMasks[i] = mask
 		}
 	}
 
-	return sizes, sourceRects, nil
+	return sizes, sourceRects, spriteMasks, nil
 }
 
-// readImageFiles 读取目录中的所有图片文件并返回它们的尺寸
-func readImageFiles(options *Options) ([]rectpack.Size2D, []string, []image.Rectangle) {
+// readImageFiles 读取目录中的所有图片文件并返回它们的尺寸，按形状嵌套时同时返回占用位图
+func readImageFiles(options *Options) ([]rectpack.Size2D, []string, []image.Rectangle, map[int]*rectpack.Mask) {
 	// 确保输入目录存在
 	if _, err := os.Stat(options.InputDir); os.IsNotExist(err) {
 		panic(fmt.Errorf("输入目录 %s 不存在", options.InputDir))
@@ -191,12 +206,15 @@ func readImageFiles(options *Options) ([]rectpack.Size2D, []string, []image.Rect
 	if options.IsTrimTransparent {
 		fmt.Println("已开启透明区域裁切...")
 	}
-	size2Ds, sourceRects, err := processImages(imagePaths, options)
+	if options.MaskCell > 0 {
+		fmt.Printf("已开启按形状嵌套打包，碰撞单元格 %dpx...\n", options.MaskCell)
+	}
+	size2Ds, sourceRects, spriteMasks, err := processImages(imagePaths, options)
 	if err != nil {
 		panic(err)
 	}
 	fmt.Printf("预先处理 %d 个图片文件\n", len(size2Ds))
-	return size2Ds, imagePaths, sourceRects
+	return size2Ds, imagePaths, sourceRects, spriteMasks
 }
 
 func nextPowerOfTwo(n int) int {
@@ -206,8 +224,8 @@ func nextPowerOfTwo(n int) int {
 	return int(math.Pow(2, math.Ceil(math.Log2(float64(n)))))
 }
 
-// CreateAtlasImage 创建图集图像
-func CreateAtlasImage(packer *rectpack.Packer, imagePaths []string, sourceRects []image.Rectangle) (*image.NRGBA, map[string]SpriteInfo, error) {
+// CreateAtlasImage 创建图集图像，grid 为网格布局时图集的网格，否则为 nil
+func CreateAtlasImage(packer *rectpack.Packer, grid *rectpack.Grid, imagePaths []string, sourceRects []image.Rectangle) (*image.NRGBA, map[string]SpriteInfo, error) {
 	if debugInfo.IsDebug {
 		start := time.Now() // 记录开始时间
 		defer func() {
@@ -217,6 +235,10 @@ func CreateAtlasImage(packer *rectpack.Packer, imagePaths []string, sourceRects
 	}
 	// 获取图集所需的最终尺寸
 	atlasSize := packer.MinSize()
+	if grid != nil {
+		// 网格布局的图集包含所有单元格，使引擎可以按单元格切分
+		atlasSize = grid.Size()
+	}
 	if options.PowerOfTwo {
 		atlasSize.Width = nextPowerOfTwo(atlasSize.Width)
 		atlasSize.Height = nextPowerOfTwo(atlasSize.Height)
@@ -275,6 +297,10 @@ func CreateAtlasImage(packer *rectpack.Packer, imagePaths []string, sourceRects
 			spriteInfo.Rotated = isRotated
 			spriteInfo.SourceSize.W = origBounds.Dx()
 			spriteInfo.SourceSize.H = origBounds.Dy()
+			if grid != nil {
+				column, row := grid.Cell(r)
+				spriteInfo.Cell = &CellInfo{Column: column, Row: row, Index: row*grid.Columns + column}
+			}
 
 			// 检查是否进行了裁剪
 			isTrimmed := srcRect.Min.X > 0 || srcRect.Min.Y > 0 ||
@@ -296,7 +322,12 @@ func CreateAtlasImage(packer *rectpack.Packer, imagePaths []string, sourceRects
 			//srcImage 源图像
 			//sourceRect.Min 源矩形的左上角坐标
 			//draw.Src 绘制操作的选项，这里是使用源图像的原始像素
-			draw.Draw(dstImage, dstRect, srcImage, srcRect.Min, draw.Src)
+			//按形状嵌套时外接矩形可能重叠，使用 draw.Over 避免透明像素覆盖相邻的图片
+			op := draw.Src
+			if options.MaskCell > 0 {
+				op = draw.Over
+			}
+			draw.Draw(dstImage, dstRect, srcImage, srcRect.Min, op)
 			spriteInfoMapping[path] = spriteInfo
 			mu.Unlock()
 
@@ -316,3 +347,14 @@ func CreateAtlasImage(packer *rectpack.Packer, imagePaths []string, sourceRects
 
 	return dstImage, spriteInfoMapping, nil
 }
+
+// saveDebugOverlay 在图集的副本上叠加绘制布局(矩形边框、文件名、旋转标记和间距)，保存为 PNG
+func saveDebugOverlay(atlas *image.NRGBA, packer *rectpack.Packer, imagePaths []string, outputPath string) error {
+	overlay := imaging.Clone(atlas)
+	scene := render.FromPacker(packer)
+	scene.Bin = rectpack.NewSize2D(atlas.Bounds().Dx(), atlas.Bounds().Dy())
+	render.Draw(overlay, scene, &render.Options{Label: func(id int) string {
+		return filepath.Base(imagePaths[id])
+	}})
+	return imaging.Save(overlay, outputPath)
+}
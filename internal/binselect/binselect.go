@@ -0,0 +1,126 @@
+// Package binselect 是 rectpack 和 rectpack3d 按容器目录打包时共用的容器选择策略
+package binselect
+
+import "math"
+
+// Trial 是把剩余物品试装进目录中一种容器的结果
+type Trial[T any] struct {
+	// Cost 是该容器的成本
+	Cost float64
+	// Placed 是装入物品的面积或体积，0 表示该容器不可用或装不下任何物品
+	Placed int
+	// Leftover 是装不下的物品
+	Leftover []T
+	// Limit 是该容器还可用的数量(包括这一个)，0 表示不限
+	Limit int
+}
+
+// Choose 返回估算总成本最低的容器下标，没有可用容器时返回 -1
+//
+// 总成本为该容器的成本加上剩余物品还需要的容器成本。剩余物品中放不进任何可用容器的不计入；
+// 其余物品按需求量(demand)估算：取能放下全部这些物品的容器中 ceil(需求量/该容器的装入量)*成本
+// 最低的，以及把每个物品分给能放下它的单位装入量成本最低的容器后分别估算的总和，两者中较低的。
+// 估算时每种容器最多使用 Limit 个(选中的容器已用掉一个)，用完的容器不再计入。
+// 总成本相同时选择装入量大的容器。fits 判断物品能否放入下标为 bin 的容器。
+func Choose[T any](trials []Trial[T], fits func(item T, bin int) bool, demand func(item T) int) int {
+	best, bestTotal, bestPlaced := -1, math.Inf(1), 0
+	left := make([]int, len(trials))
+	for i, trial := range trials {
+		if trial.Placed == 0 {
+			continue
+		}
+		for j := range trials {
+			left[j] = trials[j].Limit
+		}
+		left[i]--
+		total := trial.Cost + estimate(trials, left, trial.Leftover, fits, demand)
+		if total < bestTotal || total == bestTotal && trial.Placed > bestPlaced {
+			best, bestTotal, bestPlaced = i, total, trial.Placed
+		}
+	}
+	return best
+}
+
+// estimate 估算放下 items 中能放入某个可用容器的物品还需要的成本
+//
+// left[j] 是下标为 j 的容器还可用的数量，小于等于0且 Limit 不为0时该容器已用完。
+func estimate[T any](trials []Trial[T], left []int, items []T, fits func(item T, bin int) bool, demand func(item T) int) float64 {
+	available := func(j int) bool {
+		return trials[j].Placed > 0 && (trials[j].Limit == 0 || left[j] > 0)
+	}
+	// capacity 是按可用数量估算的每种容器还能装入的需求量，-1 表示不限
+	capacity := make([]int, len(trials))
+	for j, trial := range trials {
+		capacity[j] = -1
+		if trial.Limit > 0 {
+			capacity[j] = max(left[j], 0) * trial.Placed
+		}
+	}
+	// grouped 按单位装入量成本最低、且还有容量的容器分组累计需求量
+	grouped := make([]int, len(trials))
+	total, placeable := 0, false
+	for _, item := range items {
+		need := demand(item)
+		cheapest, unit := -1, math.Inf(1)
+		for j, trial := range trials {
+			if available(j) && capacity[j] != 0 && fits(item, j) && trial.Cost/float64(trial.Placed) < unit {
+				cheapest, unit = j, trial.Cost/float64(trial.Placed)
+			}
+		}
+		if cheapest < 0 {
+			// 没有能放下它的容器，或者这些容器的剩余数量已经估算用完
+			continue
+		}
+		grouped[cheapest] += need
+		if capacity[cheapest] > 0 {
+			capacity[cheapest] = max(capacity[cheapest]-need, 0)
+		}
+		total += need
+		placeable = true
+	}
+	if !placeable {
+		return 0
+	}
+	var cost float64
+	for j, need := range grouped {
+		if need > 0 {
+			cost += pages(need, trials[j].Placed) * trials[j].Cost
+		}
+	}
+	// 单一容器能放下全部物品时，全部改用它可能更便宜，例如只剩少量物品时
+	for j, trial := range trials {
+		if !available(j) {
+			continue
+		}
+		n := pages(total, trial.Placed)
+		if trial.Limit > 0 && n > float64(left[j]) {
+			continue
+		}
+		all := true
+		for _, item := range items {
+			if !fits(item, j) && fitsAny(trials, item, fits, available) {
+				all = false
+				break
+			}
+		}
+		if all {
+			cost = min(cost, n*trial.Cost)
+		}
+	}
+	return cost
+}
+
+// fitsAny 判断物品能否放入某个可用容器
+func fitsAny[T any](trials []Trial[T], item T, fits func(item T, bin int) bool, available func(bin int) bool) bool {
+	for j := range trials {
+		if available(j) && fits(item, j) {
+			return true
+		}
+	}
+	return false
+}
+
+// pages 返回需求量 need 按每个容器装入 placed 估算需要的容器数
+func pages(need, placed int) float64 {
+	return math.Ceil(float64(need) / float64(placed))
+}
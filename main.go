@@ -8,6 +8,7 @@ import (
 	"os"
 	"path/filepath"
 	"rectpack2d/rectpack"
+	"strings"
 	"time"
 
 	"github.com/disintegration/imaging"
@@ -48,6 +49,17 @@ type Options struct {
 	IsAutoSize            bool               //是否自动收缩
 	Algorithm             rectpack.Heuristic // 算法
 	PowerOfTwo            bool               //是否使用2的幂
+	Bins                  []rectpack.BinType // 可选的图集尺寸目录，为空时每页都使用最大宽高
+	DebugOverlay          bool               // 是否在每个图集旁输出标注了布局的调试图
+	MaskCell              int                // 按形状嵌套时碰撞检测的单元格边长，0 表示按矩形打包
+	ScenesPath            string             // 场景文件，按场景的共用关系分配图集
+	Layout                string             // 布局方式: pack(打包), grid(网格), strip(条带)
+	GridColumns           int                // 网格列数，0 表示按图集宽度尽量多
+	CellWidth             int                // 网格单元格宽度，0 表示取最大帧的宽度
+	CellHeight            int                // 网格单元格高度，0 表示取最大帧的高度
+	GridSpacing           int                // 网格单元格之间的间距
+	GridMargin            int                // 网格与图集边缘的留白
+	RowPerAnimation       bool               // 网格中每个动画从新的一行开始
 }
 
 // SpriteInfo 存储精灵图的信息
@@ -69,8 +81,9 @@ type SpriteInfo struct {
 		W int `json:"w"`
 		H int `json:"h"`
 	} `json:"sourceRect,omitempty"`
-	Trimmed bool `json:"trimmed"`
-	Rotated bool `json:"rotated"`
+	Trimmed bool      `json:"trimmed"`
+	Rotated bool      `json:"rotated"`
+	Cell    *CellInfo `json:"cell,omitempty"` // 网格和条带布局时所在的单元格
 }
 
 // MultiAtlasData 存储多个图集的信息
@@ -86,11 +99,13 @@ type MultiAtlasData struct {
 			W int `json:"w"`
 			H int `json:"h"`
 		} `json:"totalSize"`
+		Grid *GridInfo `json:"grid,omitempty"`
 	} `json:"atlases"`
 }
 
 // generateMultiAtlasJSON 生成包含多个图集信息的JSON元数据
-func generateMultiAtlasJSON(atlasMappings []map[string]SpriteInfo, atlasImagePaths []string, outputPath string) error {
+// grids 为每个图集的网格信息，非网格布局的图集为 nil
+func generateMultiAtlasJSON(atlasMappings []map[string]SpriteInfo, grids []*GridInfo, atlasImagePaths []string, outputPath string) error {
 	if debugInfo.IsDebug {
 		start := time.Now() // 记录开始时间
 		defer func() {
@@ -114,6 +129,7 @@ func generateMultiAtlasJSON(atlasMappings []map[string]SpriteInfo, atlasImagePat
 				W int `json:"w"`
 				H int `json:"h"`
 			} `json:"totalSize"`
+			Grid *GridInfo `json:"grid,omitempty"`
 		}, len(atlasMappings)),
 	}
 
@@ -121,6 +137,9 @@ func generateMultiAtlasJSON(atlasMappings []map[string]SpriteInfo, atlasImagePat
 	for i, mapping := range atlasMappings {
 		atlas := &multiAtlasData.Atlases[i]
 		atlas.AtlasName = filepath.Base(atlasImagePaths[i])
+		if i < len(grids) {
+			atlas.Grid = grids[i]
+		}
 		atlas.SpriteList = make(map[string]SpriteInfo)
 
 		// 计算图集的总尺寸
@@ -160,10 +179,41 @@ func outputResult(packer *rectpack.Packer) {
 	fmt.Printf("打包区域大小: %dx%d\n", size.Width, size.Height)
 	fmt.Printf("空间利用率: %.2f%%\n", packer.GetAreaUsedRate(true)*100)
 	fmt.Printf("已打包矩形数量: %d\n", len(rects))
-	fmt.Printf("未打包矩形数量: %d\n\n", len(packer.GetUnpackedRects()))
+	fmt.Printf("未打包矩形数量: %d\n", len(packer.GetUnpackedRects()))
+	largest := packer.LargestFreeRect()
+	fmt.Printf("剩余空间: %d, 最大剩余矩形: %dx%d, 碎片化指数: %.2f\n\n",
+		packer.FreeArea(), largest.Width, largest.Height, packer.Fragmentation())
 }
 
-func packing(sizes []rectpack.Size2D, options *Options) *rectpack.Packer {
+// outputDiagnoses 输出未能打包的图片及原因，grid 为网格布局时图集的网格，否则为 nil
+// 返回换一张新图集后仍有可能放下的尺寸
+func outputDiagnoses(packer *rectpack.Packer, grid *rectpack.Grid, imagePaths []string) []rectpack.Size2D {
+	if grid != nil {
+		// 网格布局中每一帧都能放入新图集的单元格，按顺序排到下一个图集
+		for _, size := range packer.GetUnpackedRects() {
+			fmt.Printf("  %s (%dx%d): 网格已满，排到下一个图集\n", filepath.Base(imagePaths[size.ID]), size.Width, size.Height)
+		}
+		return packer.GetUnpackedRects()
+	}
+	var retry []rectpack.Size2D
+	for _, d := range packer.Diagnose() {
+		fmt.Printf("  %s (%dx%d): %s", filepath.Base(imagePaths[d.Size.ID]), d.Size.Width, d.Size.Height, d.Reason)
+		if d.Reason == rectpack.ReasonFragmentation {
+			fmt.Printf(", 最大剩余空间 %dx%d", d.LargestFree.Width, d.LargestFree.Height)
+		}
+		fmt.Println()
+		if d.Reason.FitsEmptyBin() {
+			retry = append(retry, d.Size)
+		} else {
+			fmt.Printf("  已跳过 %s\n", filepath.Base(imagePaths[d.Size.ID]))
+		}
+	}
+	return retry
+}
+
+// packing 打包一个图集，spriteMasks 为按形状嵌套时每个图片的占用位图
+// 返回打包器，以及网格和条带布局时图集的网格(其他布局为 nil)
+func packing(sizes []rectpack.Size2D, spriteMasks map[int]*rectpack.Mask, options *Options) (*rectpack.Packer, *rectpack.Grid) {
 	if debugInfo.IsDebug {
 		start := time.Now() // 记录开始时间
 		defer func() {
@@ -179,15 +229,72 @@ func packing(sizes []rectpack.Size2D, options *Options) *rectpack.Packer {
 	packer.AllowRotate(options.IsAllowRotate)
 	packer.SetPadding(options.SpritePadding)
 	packer.Insert(sizes...)
-	successful := packer.Pack()
-	if successful && options.IsAutoSize {
-		fmt.Println("空间自动收缩优化...")
-		packer.Shrink()
+	var successful bool
+	var grid *rectpack.Grid
+	if options.gridLayout() {
+		// 网格和条带保持输入顺序，不收缩
+		var g rectpack.Grid
+		g, successful = packingGrid(packer, options)
+		grid = &g
+	} else if options.MaskCell > 0 {
+		// 按形状嵌套的外接矩形可能互相重叠，不能再按矩形收缩
+		successful = packer.PackMasks(spriteMasks, options.MaskCell)
+	} else {
+		successful = packer.Pack()
+		if successful && options.IsAutoSize {
+			fmt.Println("空间自动收缩优化...")
+			packer.Shrink()
+		}
 	}
 	if !successful {
 		fmt.Println("警告: 部分图片无法打包到指定尺寸的图集中")
 	}
-	return packer
+	return packer, grid
+}
+
+// packingCatalog 从图集尺寸目录中选择每页的尺寸，使总成本尽量小
+// 返回每一页的打包器
+func packingCatalog(sizes []rectpack.Size2D, imagePaths []string, options *Options) []*rectpack.Packer {
+	if debugInfo.IsDebug {
+		start := time.Now()
+		defer func() {
+			debugInfo.PackTime += time.Since(start)
+		}()
+	}
+	// 用目录中最大的尺寸创建打包器，只用于携带算法配置和诊断无法打包的图片
+	var maxW, maxH int
+	for _, bin := range options.Bins {
+		maxW, maxH = max(maxW, bin.Width), max(maxH, bin.Height)
+	}
+	packer, err := rectpack.NewPacker(maxW, maxH, options.Algorithm)
+	if err != nil {
+		fmt.Printf("创建打包器失败: %v\n", err)
+		os.Exit(1)
+	}
+	packer.AllowRotate(options.IsAllowRotate)
+	packer.SetPadding(options.SpritePadding)
+	packer.Insert(sizes...)
+	pages, successful := packer.PackCatalog(options.Bins)
+
+	var packers []*rectpack.Packer
+	var cost float64
+	for i, page := range pages {
+		fmt.Printf("图集 #%d 尺寸: %dx%d, 成本: %g\n", i, page.Bin.Width, page.Bin.Height, page.Bin.Cost)
+		if options.IsAutoSize {
+			page.Packer.Shrink()
+		}
+		outputResult(page.Packer)
+		packers = append(packers, page.Packer)
+		cost += page.Bin.Cost
+	}
+	fmt.Printf("图集数量: %d, 总成本: %g\n", len(pages), cost)
+	if !successful {
+		fmt.Println("警告: 部分图片无法打包到目录中的任何图集尺寸")
+		for _, size := range packer.GetUnpackedRects() {
+			fmt.Printf("  已跳过 %s (%dx%d)\n", filepath.Base(imagePaths[size.ID]), size.Width, size.Height)
+		}
+	}
+	return packers
 }
 
 func flagArgs() {
@@ -206,8 +313,56 @@ func flagArgs() {
 	variantPtr := flag.String("variant", "BestAreaFit", "打包算法变体 (BestShortSideFit, BestLongSideFit, BestAreaFit)")
 	autoSizePtr := flag.Bool("auto-size", true, "启用自动布局区域收缩优化")
 	powOfTwo := flag.Bool("pow-of-two", false, "启用2的幂")
+	debugOverlayPtr := flag.Bool("debug-overlay", false, "在每个图集旁输出标注了矩形边框、ID、旋转和间距的调试图 (*_debug.png)")
+	maskCellPtr := flag.Int("mask-cell", 0, "按 alpha 通道的形状嵌套打包，值为碰撞检测的单元格边长(像素，越大越快但越松)，0 表示按矩形打包")
+	scenesPtr := flag.String("scenes", "", "场景文件(JSON，场景名 -> 精灵文件名数组或 {\"weight\", \"sprites\"})，多图集时使每个场景用到的图集尽量少")
+	layoutPtr := flag.String("layout", "pack", "布局方式 (pack: 打包, grid: 按输入顺序排成网格, strip: 按输入顺序排成一行)，网格和条带不裁切也不旋转")
+	columnsPtr := flag.Int("columns", 0, "网格列数，0 表示按图集宽度尽量多")
+	cellPtr := flag.String("cell", "", "网格单元格尺寸 宽x高，默认取最大帧的尺寸")
+	spacingPtr := flag.Int("spacing", 0, "网格单元格之间的间距")
+	marginPtr := flag.Int("margin", 0, "网格与图集边缘的留白")
+	rowPerAnimPtr := flag.Bool("row-per-anim", false, "网格和条带中每个动画(文件名去掉末尾编号后相同的帧)从新的一行开始")
+	binsPtr := flag.String("bins", "", "图集尺寸目录，逗号分隔的 宽x高[:成本[:数量]]，未指定成本时按面积计算，例如 2048x2048,1024x1024,512x512")
 	flag.Parse()
 
+	var bins []rectpack.BinType
+	if *binsPtr != "" {
+		var err error
+		if bins, err = rectpack.ParseBinCatalog(*binsPtr); err != nil {
+			fmt.Printf("图集尺寸目录无效: %v\n", err)
+			os.Exit(1)
+		}
+	}
+	if *maskCellPtr > 0 && len(bins) > 0 {
+		fmt.Println("按形状嵌套打包不支持图集尺寸目录 (-mask-cell 与 -bins 不能同时使用)")
+		os.Exit(1)
+	}
+	if *scenesPtr != "" && (*maskCellPtr > 0 || len(bins) > 0) {
+		fmt.Println("按场景分配图集不支持按形状嵌套和图集尺寸目录 (-scenes 不能与 -mask-cell 或 -bins 同时使用)")
+		os.Exit(1)
+	}
+	var cellWidth, cellHeight int
+	switch *layoutPtr {
+	case "pack":
+	case "grid", "strip":
+		if *maskCellPtr > 0 || len(bins) > 0 || *scenesPtr != "" {
+			fmt.Println("网格和条带布局不能与 -mask-cell、-bins 或 -scenes 同时使用")
+			os.Exit(1)
+		}
+		if *cellPtr != "" {
+			var err error
+			if cellWidth, cellHeight, err = parseCellSize(*cellPtr); err != nil {
+				fmt.Printf("单元格尺寸无效: %v\n", err)
+				os.Exit(1)
+			}
+		}
+		// 帧按原始尺寸放入单元格，裁切后的偏移无法从网格中还原
+		*trimPtr = false
+	default:
+		fmt.Printf("未知的布局方式: %s (可选 pack, grid, strip)\n", *layoutPtr)
+		os.Exit(1)
+	}
+
 	// 创建对象
 	options = Options{
 		UnpackPath:            *unpackPath,
@@ -224,6 +379,17 @@ func flagArgs() {
 		IsAutoSize:            *autoSizePtr,
 		Algorithm:             rectpack.ResolveAlgorithm(*algorithmPtr, *variantPtr),
 		PowerOfTwo:            *powOfTwo,
+		Bins:                  bins,
+		DebugOverlay:          *debugOverlayPtr,
+		MaskCell:              max(*maskCellPtr, 0),
+		ScenesPath:            *scenesPtr,
+		Layout:                *layoutPtr,
+		GridColumns:           *columnsPtr,
+		CellWidth:             cellWidth,
+		CellHeight:            cellHeight,
+		GridSpacing:           *spacingPtr,
+		GridMargin:            *marginPtr,
+		RowPerAnimation:       *rowPerAnimPtr,
 	}
 	// 解包
 	if options.UnpackPath != "" {
@@ -233,6 +399,9 @@ func flagArgs() {
 }
 
 func main() {
+	if len(os.Args) > 1 && os.Args[1] == "rects" {
+		os.Exit(runRects(os.Args[2:], os.Stdin, os.Stdout, os.Stderr))
+	}
 	if debugInfo.IsDebug {
 		start := time.Now() // 记录开始时间
 		defer func() {
@@ -250,34 +419,62 @@ func main() {
 	flagArgs()
 
 	// 读取输入目录中的图片文件
-	size2Ds, imagePaths, sourceRects := readImageFiles(&options)
+	size2Ds, imagePaths, sourceRects, spriteMasks := readImageFiles(&options)
+	if options.gridLayout() {
+		if options.RowPerAnimation {
+			assignAnimations(size2Ds, imagePaths)
+		}
+		// 每一页使用相同的单元格：未指定时取所有帧中最大的宽和高
+		if options.CellWidth == 0 {
+			for _, size := range size2Ds {
+				options.CellWidth, options.CellHeight = max(options.CellWidth, size.Width), max(options.CellHeight, size.Height)
+			}
+		}
+	}
 
-	pakerList := make([]*rectpack.Packer, 0)
-	// 创建打包器并打包当前批次的图片
-	packer := packing(size2Ds, &options)
-	// 输出当前打包结果
-	outputResult(packer)
+	var pakerList []*rectpack.Packer
+	// grids 为每个图集的网格，非网格布局的图集为 nil
+	var grids []*rectpack.Grid
+	if len(options.Bins) > 0 {
+		pakerList = packingCatalog(size2Ds, imagePaths, &options)
+		grids = make([]*rectpack.Grid, len(pakerList))
+	} else if options.ScenesPath != "" {
+		pakerList = packingScenes(size2Ds, imagePaths, &options)
+		grids = make([]*rectpack.Grid, len(pakerList))
+	} else {
+		// 创建打包器并打包当前批次的图片
+		packer, grid := packing(size2Ds, spriteMasks, &options)
+		// 输出当前打包结果
+		outputResult(packer)
 
-	pakerList = append(pakerList, packer)
+		pakerList = append(pakerList, packer)
+		grids = append(grids, grid)
 
-	for unpackedRects := packer.GetUnpackedRects(); len(unpackedRects) > 0; {
-		p := packing(unpackedRects, &options)
-		outputResult(p)
-		pakerList = append(pakerList, p)
-		unpackedRects = p.GetUnpackedRects()
+		// 放不下的图片换新图集继续打包，新图集也放不下的图片会被跳过
+		for unpackedRects := outputDiagnoses(packer, grid, imagePaths); len(unpackedRects) > 0; {
+			p, g := packing(unpackedRects, spriteMasks, &options)
+			outputResult(p)
+			pakerList = append(pakerList, p)
+			grids = append(grids, g)
+			unpackedRects = outputDiagnoses(p, g, imagePaths)
+		}
 	}
 
 	atlasList := make([]*image.NRGBA, 0)
+	atlasPackers := make([]*rectpack.Packer, 0)
 	multiSpiteInfo := make([]map[string]SpriteInfo, 0)
+	multiGridInfo := make([]*GridInfo, 0)
 
 	for atlasIndex, packer := range pakerList {
-		atlasImage, spriteInfoMapping, err := CreateAtlasImage(packer, imagePaths, sourceRects)
+		atlasImage, spriteInfoMapping, err := CreateAtlasImage(packer, grids[atlasIndex], imagePaths, sourceRects)
 		if err != nil {
 			fmt.Printf("生成图集 #%d 失败: %v\n", atlasIndex, err)
 			continue
 		}
 		atlasList = append(atlasList, atlasImage)
+		atlasPackers = append(atlasPackers, packer)
 		multiSpiteInfo = append(multiSpiteInfo, spriteInfoMapping)
+		multiGridInfo = append(multiGridInfo, gridInfo(packer, grids[atlasIndex], imagePaths, options.RowPerAnimation))
 	}
 	atlasImagePaths := make([]string, 0)
 
@@ -300,12 +497,18 @@ func main() {
 		file, _ := os.Create(outputPath)
 		imaging.Encode(file, a, imaging.PNG)
 		file.Close()
+		if options.DebugOverlay {
+			debugPath := strings.TrimSuffix(outputPath, ".png") + "_debug.png"
+			if err := saveDebugOverlay(a, atlasPackers[i], imagePaths, debugPath); err != nil {
+				fmt.Printf("生成调试图 %s 失败: %v\n", debugPath, err)
+			}
+		}
 	}
 	elapsed := time.Since(start)
 	fmt.Println("图像写入耗时:", elapsed)
 	// 图集的JSON元数据
 	multiAtlasJsonPath := filepath.Join(options.OutputDir, "atlases.json")
-	if err := generateMultiAtlasJSON(multiSpiteInfo, atlasImagePaths, multiAtlasJsonPath); err != nil {
+	if err := generateMultiAtlasJSON(multiSpiteInfo, multiGridInfo, atlasImagePaths, multiAtlasJsonPath); err != nil {
 		fmt.Printf("生成JSON元数据失败: %v\n", err)
 		os.Exit(1)
 	}
@@ -56,18 +56,18 @@ func runBenchmark(options *Options, name string) {
 	}()
 
 	// 读取输入目录中的图片文件
-	size2Ds, imagePaths, sourceRects := readImageFiles(options)
+	size2Ds, imagePaths, sourceRects, spriteMasks := readImageFiles(options)
 
 	pakerList := make([]*rectpack.Packer, 0)
 	// 创建打包器并打包当前批次的图片
-	packer := packing(size2Ds, options)
+	packer, _ := packing(size2Ds, spriteMasks, options)
 	// 输出当前打包结果
 	outputResult(packer)
 
 	pakerList = append(pakerList, packer)
 
 	for unpackedRects := packer.GetUnpackedRects(); len(unpackedRects) > 0; {
-		p := packing(unpackedRects, options)
+		p, _ := packing(unpackedRects, spriteMasks, options)
 		outputResult(p)
 		pakerList = append(pakerList, p)
 		unpackedRects = p.GetUnpackedRects()
@@ -77,7 +77,7 @@ func runBenchmark(options *Options, name string) {
 	multiSpiteInfo := make([]map[string]SpriteInfo, 0)
 
 	for atlasIndex, packer := range pakerList {
-		atlasImage, spriteInfoMapping, err := CreateAtlasImage(packer, imagePaths, sourceRects)
+		atlasImage, spriteInfoMapping, err := CreateAtlasImage(packer, nil, imagePaths, sourceRects)
 		if err != nil {
 			fmt.Printf("生成图集 #%d 失败: %v\n", atlasIndex, err)
 			continue
@@ -107,9 +107,22 @@ func runBenchmark(options *Options, name string) {
 		file.Close()
 	}
 	multiAtlasJsonPath := filepath.Join(options.OutputDir, name+".json")
-	if err := generateMultiAtlasJSON(multiSpiteInfo, atlasImagePaths, multiAtlasJsonPath); err != nil {
+	if err := generateMultiAtlasJSON(multiSpiteInfo, nil, atlasImagePaths, multiAtlasJsonPath); err != nil {
 		fmt.Printf("生成JSON元数据失败: %v\n", err)
 		os.Exit(1)
 	}
 	fmt.Printf("- 图集元数据: %s\n\n", multiAtlasJsonPath)
 }
+
+func TestPackingDefaultLayout(t *testing.T) {
+	// 未设置 Layout 的 Options 应使用打包而不是网格布局
+	options := Options{AtlasMaxWidth: 64, AtlasMaxHeight: 64, Algorithm: rectpack.MaxRectsBSSF}
+	sizes := []rectpack.Size2D{rectpack.NewSize2DByID(0, 40, 10), rectpack.NewSize2DByID(1, 10, 40)}
+	packer, grid := packing(sizes, nil, &options)
+	if grid != nil {
+		t.Fatal("zero-value Layout used the grid layout")
+	}
+	if got := len(packer.GetPackedRects()); got != 2 {
+		t.Errorf("packed %d rects, want 2", got)
+	}
+}
@@ -8,10 +8,17 @@ type packAlgorithm interface {
 	// 计算使用率，返回值在0.0（空）到1.0（完美利用）之间。
 	GetAreaUsedRate() float64
 	// 插入新矩形，指定矩形间的间距。
-	// 返回无法包装的尺寸。
+	// 返回无法包装的尺寸，由算法内部管理，下次插入前有效。
 	Insert(padding int, sizes ...Size2D) []Size2D
+	// 从 sizes 中选出评分最优的一个矩形并放置，返回其索引。
+	// 无法放置任何矩形时返回 -1。
+	insertBest(padding int, sizes []Size2D) int
 	// 返回已包装的矩形列表。
 	GetPackedRects() []Rect2D
+	// 替换已包装的矩形列表，不改变剩余空间。
+	setPackedRects(rects []Rect2D)
+	// 返回剩余空间的矩形列表(可能互相重叠)，算法不记录剩余空间时返回 nil。
+	getFreeRects() []Rect2D
 	// 设置是否允许旋转矩形以优化放置。
 	// 默认：false
 	AllowRotate(enabled bool)
@@ -21,6 +28,14 @@ type packAlgorithm interface {
 	GetUsedArea() int
 
 	GetIdMapRotated() map[int]bool
+	// 保存算法状态，用于序列化包装器，state 可能引用算法内部的切片。
+	saveState(state *algorithmState)
+	// 从 state 恢复算法状态，调用前算法已按 state 的尺寸和启发式创建。
+	loadState(state *algorithmState) error
+	// 设置记录打包过程的 Tracer，nil 表示不记录。
+	setTracer(tracer Tracer)
+	// 用局部搜索得到的布局替换已包装的矩形并重建剩余空间，rects 不含间距。
+	relayout(rects []Rect2D, rotated map[int]bool, padding int)
 }
 
 // algorithmBase 是一个包装算法的基础实现
@@ -31,6 +46,12 @@ type algorithmBase struct {
 	usedArea     int      // 已使用的面积
 	allowRotate  bool     // 是否允许旋转矩形
 	idMapRotated map[int]bool
+	classes      sizeClasses // insertClasses 复用的缓冲区
+	failed       []Size2D    // Insert 返回的无法包装的尺寸，下次插入时复用
+	tracer       Tracer      // 记录打包过程，见 trace.go
+	traceIndex   int         // 本次运行中下一步的序号
+	// 按顺序放置时下一个矩形的位置和当前行的最大高度，多次插入之间保持
+	cursorX, cursorY, rowHeight int
 }
 
 // Reset 重置包装器的状态，设置新的最大宽度和最大高度，清空已包装矩形。
@@ -42,6 +63,12 @@ func (p *algorithmBase) Reset(width, height int) {
 	p.maxHeight = height
 	p.usedArea = 0
 	p.packed = p.packed[:0]
+	p.cursorX, p.cursorY, p.rowHeight = 0, 0, 0
+	clear(p.idMapRotated)
+	p.traceIndex = 0
+	if p.tracer != nil {
+		p.tracer.Reset(NewSize2D(width, height))
+	}
 }
 
 // GetAreaUsedRate 返回当前包装器的使用率，值在 0.0 到 1.0 之间，表示包装器的空间利用程度。
@@ -54,6 +81,16 @@ func (p *algorithmBase) GetPackedRects() []Rect2D {
 	return p.packed
 }
 
+// setPackedRects 替换已包装的矩形列表，用于分层打包等需要改写结果的场景。
+func (p *algorithmBase) setPackedRects(rects []Rect2D) {
+	p.packed = rects
+}
+
+// getFreeRects 按顺序放置的基础算法不记录剩余空间，返回 nil。
+func (p *algorithmBase) getFreeRects() []Rect2D {
+	return nil
+}
+
 // AllowRotate 设置是否允许旋转矩形以优化放置。
 func (p *algorithmBase) AllowRotate(enabled bool) {
 	p.allowRotate = enabled
@@ -69,43 +106,73 @@ func (p *algorithmBase) GetUsedArea() int {
 	return p.usedArea
 }
 
-// 插入新矩形，指定矩形间的间距,简单按顺序放置
-// 返回无法包装的尺寸。
+// 插入新矩形，指定矩形间的间距,简单按顺序放置，从上次插入结束的位置继续
+// 返回无法包装的尺寸(由内部管理，下次插入前有效)。
 func (p *algorithmBase) Insert(padding int, sizes ...Size2D) []Size2D {
-	var unpacked []Size2D
-	// 当前放置位置的坐标
-	x, y := 0, 0
-	// 当前行的最大高度
-	rowHeight := 0
-	for _, size := range sizes {
-		width, height := size.Width, size.Height
-		// 检查是否需要换行
-		if x+width+padding > p.maxWidth {
-			// 换到下一行
-			x = 0
-			y += rowHeight + padding
-			rowHeight = 0
-		}
-		// 检查是否超出高度限制
-		if y+height+padding > p.maxHeight {
+	unpacked := p.failed[:0]
+	for i, size := range sizes {
+		if !p.place(padding, size, i) {
 			// 无法放置，添加到未包装列表
 			unpacked = append(unpacked, size)
-			continue
-		}
-		// 放置矩形
-		rect := NewRect(x, y, width, height)
-		rect.ID = size.ID
-		p.packed = append(p.packed, rect)
-		p.usedArea += width * height
-		// 更新当前位置和行高
-		x += width + padding
-		if height > rowHeight {
-			rowHeight = height
 		}
 	}
+	p.failed = unpacked
 	return unpacked
 }
 
+// position 返回按顺序放置 size 的位置(不含间距)，当前行放不下时换到下一行
+// 不修改放置位置，容器放不下时 ok 为 false
+func (p *algorithmBase) position(padding int, size Size2D) (x, y int, ok bool) {
+	x, y = p.cursorX, p.cursorY
+	// 检查是否需要换行
+	if x+size.Width+padding > p.maxWidth {
+		x, y = 0, y+p.rowHeight+padding
+	}
+	// 检查是否超出宽度或高度限制
+	return x, y, x+size.Width+padding <= p.maxWidth && y+size.Height+padding <= p.maxHeight
+}
+
+// place 把 size 放在下一个位置并更新放置位置，index 为记录打包过程时的序号
+func (p *algorithmBase) place(padding int, size Size2D, index int) bool {
+	x, y, ok := p.position(padding, size)
+	if !ok {
+		return false
+	}
+	if y != p.cursorY {
+		// 换到了下一行
+		p.rowHeight = 0
+	}
+	rect := Rect2D{Point2D: NewPoint(x, y), Size2D: size}
+	rect.Offset(max(padding, 0), max(padding, 0))
+	p.packed = append(p.packed, rect)
+	p.usedArea += size.Width * size.Height
+	if p.tracer != nil {
+		p.traceEnd(p.traceBegin(nil), index, rect, false, nil)
+	}
+	// 更新当前位置和行高
+	p.cursorX, p.cursorY = x+size.Width+padding, y
+	p.rowHeight = max(p.rowHeight, size.Height)
+	return true
+}
+
+// moveCursorBelow 把按顺序放置的位置移到所有已包装矩形的下方，用于替换布局之后继续插入
+func (p *algorithmBase) moveCursorBelow() {
+	p.cursorX, p.cursorY, p.rowHeight = 0, 0, 0
+	for _, rect := range p.packed {
+		p.cursorY = max(p.cursorY, rect.Y+rect.Height)
+	}
+}
+
+// insertBest 按顺序放置第一个能放下的矩形，都放不下时返回 -1
+func (p *algorithmBase) insertBest(padding int, sizes []Size2D) int {
+	for i, size := range sizes {
+		if p.place(padding, size, i) {
+			return i
+		}
+	}
+	return -1
+}
+
 func (p *algorithmBase) GetIdMapRotated() map[int]bool {
 	return p.idMapRotated
 }
@@ -0,0 +1,88 @@
+package rectpack
+
+import (
+	"fmt"
+	"math/rand/v2"
+	"testing"
+)
+
+// randomInstance 生成与 data.txt 同类的随机实例：尺寸在 [20, 120) 之间，总面积约为容器的1.2倍
+func randomInstance(seed uint64, bin Size2D) []Size2D {
+	r := rand.New(rand.NewPCG(seed, seed))
+	var sizes []Size2D
+	for area := 0; area < bin.Area()*6/5; {
+		size := NewSize2DByID(len(sizes), r.IntN(100)+20, r.IntN(100)+20)
+		area += size.Area()
+		sizes = append(sizes, size)
+	}
+	return sizes
+}
+
+// BenchmarkQuantity 打包大量重复的瓦片，相同尺寸按类评分
+func BenchmarkQuantity(b *testing.B) {
+	for _, heuristic := range []Heuristic{MaxRectsBSSF, GuillotineBAF} {
+		b.Run(heuristic.String(), func(b *testing.B) {
+			for i := 0; i < b.N; i++ {
+				packer, _ := NewPacker(4096, 4096, heuristic)
+				packer.AllowRotate(true)
+				packer.Insert(Size2D{ID: 0, Width: 48, Height: 32, Quantity: 5000}, Size2D{ID: 5000, Width: 32, Height: 16, Quantity: 2000})
+				packer.Pack()
+			}
+		})
+	}
+}
+
+// BenchmarkOnlineAllocs 在同一个包装器上反复重置并逐个在线插入矩形，
+// 预热后每次打包都应复用内部缓冲区，不产生内存分配
+func BenchmarkOnlineAllocs(b *testing.B) {
+	sizes := randomInstance(1, NewSize2D(512, 512))
+	cases := []struct {
+		heuristic Heuristic
+		policy    MergePolicy
+	}{
+		{MaxRectsBSSF, MergeNone}, {MaxRectsBL, MergeNone}, {GuillotineBAF, MergeNone}, {GuillotineBAF, MergeMaximal},
+	}
+	for _, c := range cases {
+		b.Run(fmt.Sprintf("%v/%v", c.heuristic, c.policy), func(b *testing.B) {
+			packer, _ := NewPacker(512, 512, c.heuristic)
+			packer.AllowRotate(true)
+			packer.SetMergePolicy(c.policy)
+			packer.Online = true
+			pack := func() {
+				packer.Reset()
+				for _, size := range sizes {
+					packer.Insert(size)
+				}
+			}
+			pack()
+			b.ReportAllocs()
+			b.ResetTimer()
+			for i := 0; i < b.N; i++ {
+				pack()
+			}
+		})
+	}
+}
+
+// BenchmarkShrinkAllocs 反复离线打包并收缩，收缩的每次尝试都复用同一份尺寸
+func BenchmarkShrinkAllocs(b *testing.B) {
+	sizes := randomInstance(2, NewSize2D(256, 256))
+	for _, heuristic := range []Heuristic{MaxRectsBSSF, GuillotineBAF} {
+		b.Run(heuristic.String(), func(b *testing.B) {
+			packer, _ := NewPacker(512, 512, heuristic)
+			packer.AllowRotate(true)
+			pack := func() {
+				packer.ResetMaxSize(512, 512)
+				packer.Insert(sizes...)
+				packer.Pack()
+				packer.Shrink()
+			}
+			pack()
+			b.ReportAllocs()
+			b.ResetTimer()
+			for i := 0; i < b.N; i++ {
+				pack()
+			}
+		})
+	}
+}
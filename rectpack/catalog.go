@@ -0,0 +1,138 @@
+package rectpack
+
+import (
+	"fmt"
+	"rectpack2d/internal/binselect"
+	"strconv"
+	"strings"
+)
+
+// BinType 是容器目录中的一种容器
+type BinType struct {
+	Width  int
+	Height int
+	// Cost 是每个容器的成本，例如纹理显存占用
+	Cost float64
+	// Limit 是可用数量，0 表示不限
+	Limit int
+}
+
+// Page 是按容器目录打包时打开的一个容器
+type Page struct {
+	// Bin 是该页所用的容器类型
+	Bin BinType
+	// Packer 保存该页的打包结果
+	Packer *Packer
+
+	index int // Bin 在容器目录中的下标
+}
+
+// PackCatalog 从容器目录中选择容器，把暂存的矩形打包到多个页面中，使总成本尽量小
+//
+// 每次为剩余矩形依次尝试每种还有数量的容器，估算选择它之后的总成本：该容器的成本，
+// 加上剩余矩形还需要的页数乘以可以放下它们的容器的成本(按单位已用面积成本估算，不超过每种容器的剩余数量)，
+// 选择总成本最低的容器。例如最后一页用小尺寸容器而不是大部分为空的大容器，
+// 但只有大容器能放下的矩形不会因为先选了便宜的小容器而多占一页。
+// 重复直到矩形全部放下或没有容器能再放下任何矩形。
+// 每一页使用与当前包装器相同的算法和配置。
+// 返回:
+//
+//	打开的页面；true: 全部打包成功 false: 部分失败(可通过GetUnpackedRects获取失败尺寸)
+func (p *Packer) PackCatalog(catalog []BinType) ([]Page, bool) {
+	pages, unpacked := packBins(p.unpackedSize2Ds, catalog, func(bin BinType) *Packer {
+		sub := p.newSubPacker(bin.Width, bin.Height)
+		sub.Hierarchical = p.Hierarchical
+		return sub
+	})
+	p.unpackedSize2Ds = unpacked
+	return pages, len(unpacked) == 0
+}
+
+// packBins 按 PackCatalog 描述的策略选择容器并打包，newPacker 为一种容器创建空的包装器
+func packBins(sizes []Size2D, catalog []BinType, newPacker func(bin BinType) *Packer) ([]Page, []Size2D) {
+	remaining := make([]int, len(catalog))
+	for i, bin := range catalog {
+		remaining[i] = bin.Limit
+	}
+	var pages []Page
+	for len(sizes) > 0 {
+		trials := make([]binselect.Trial[Size2D], len(catalog))
+		packers := make([]*Packer, len(catalog))
+		padding := 0
+		for i, bin := range catalog {
+			if bin.Width <= 0 || bin.Height <= 0 || bin.Limit > 0 && remaining[i] == 0 {
+				continue
+			}
+			packer := newPacker(bin)
+			packer.Insert(sizes...)
+			packer.Pack()
+			packers[i], padding = packer, packer.padding
+			trials[i] = binselect.Trial[Size2D]{Cost: bin.Cost, Placed: packer.algo.GetUsedArea(), Leftover: packer.GetUnpackedRects(), Limit: remaining[i]}
+		}
+		best := binselect.Choose(trials, func(size Size2D, bin int) bool {
+			return packers[bin].fits(size)
+		}, func(size Size2D) int {
+			padSize(&size, padding)
+			return size.Area()
+		})
+		if best < 0 {
+			break
+		}
+		remaining[best]--
+		pages = append(pages, Page{Bin: catalog[best], Packer: packers[best], index: best})
+		sizes = packers[best].GetUnpackedRects()
+	}
+	return pages, sizes
+}
+
+// fits 判断尺寸(加上间距后)能否放入当前的空容器
+func (p *Packer) fits(size Size2D) bool {
+	padSize(&size, p.padding)
+	return fitsIn(size, p.algo.MaxSize(), p.allowRotate && !size.NoRotate)
+}
+
+// ParseBinCatalog 解析容器目录
+//
+// 格式为逗号分隔的 "宽x高[:成本[:数量]]"，例如 "2048x2048,1024x1024:0.3,512x512::4"。
+// 未指定成本时以面积(像素数)作为成本。
+func ParseBinCatalog(s string) ([]BinType, error) {
+	var catalog []BinType
+	for _, item := range strings.Split(s, ",") {
+		item = strings.TrimSpace(item)
+		if item == "" {
+			continue
+		}
+		fields := strings.Split(item, ":")
+		if len(fields) > 3 {
+			return nil, fmt.Errorf("invalid bin %q", item)
+		}
+		w, h, ok := strings.Cut(fields[0], "x")
+		if !ok {
+			return nil, fmt.Errorf("invalid bin size %q", fields[0])
+		}
+		var bin BinType
+		var err error
+		if bin.Width, err = strconv.Atoi(w); err != nil || bin.Width <= 0 {
+			return nil, fmt.Errorf("invalid bin width %q", w)
+		}
+		if bin.Height, err = strconv.Atoi(h); err != nil || bin.Height <= 0 {
+			return nil, fmt.Errorf("invalid bin height %q", h)
+		}
+		bin.Cost = float64(bin.Width * bin.Height)
+		if len(fields) > 1 && fields[1] != "" {
+			if bin.Cost, err = strconv.ParseFloat(fields[1], 64); err != nil || bin.Cost < 0 {
+				return nil, fmt.Errorf("invalid bin cost %q", fields[1])
+			}
+		}
+		if len(fields) > 2 && fields[2] != "" {
+			if bin.Limit, err = strconv.Atoi(fields[2]); err != nil || bin.Limit < 0 {
+				return nil, fmt.Errorf("invalid bin limit %q", fields[2])
+			}
+		}
+		catalog = append(catalog, bin)
+	}
+	if len(catalog) == 0 {
+		return nil, fmt.Errorf("empty bin catalog %q", s)
+	}
+	return catalog, nil
+}
@@ -0,0 +1,211 @@
+package rectpack
+
+import (
+	"errors"
+	"sync"
+	"sync/atomic"
+)
+
+// ConcurrentPacker 是可以被多个 goroutine 同时使用的在线包装器
+//
+// 矩形被放入多个页面，每个页面是一个与模板配置相同的在线包装器，各有一把锁，
+// 不同页面上的插入和移除可以并行。插入时从按轮转选择的页面开始，先跳过被其他 goroutine
+// 占用的页面，都放不下时再等待这些页面，仍然放不下才打开新页面。
+//
+// 默认模式下放置结果取决于 goroutine 的调度。Deterministic 为 true 时所有操作串行执行，
+// 总是从第一页开始依次尝试，结果只取决于调用的顺序。
+type ConcurrentPacker struct {
+	// Deterministic 表示以确定的方式放置，开始使用前设置
+	Deterministic bool
+	// MaxPages 是页面数量上限，0 表示不限，开始使用前设置
+	MaxPages int
+
+	template *Packer
+	mu       sync.RWMutex // 保护 pages 和 owners
+	pages    []*concurrentPage
+	owners   map[int]*concurrentPage // 每个已放置ID所在的页面
+	serial   sync.Mutex              // 确定模式下串行执行所有操作
+	next     atomic.Uint64           // 轮转选择起始页面的计数
+}
+
+// concurrentPage 是 ConcurrentPacker 的一个页面
+type concurrentPage struct {
+	mu     sync.Mutex
+	index  int
+	packer *Packer
+}
+
+// Placement 是 ConcurrentPacker 的一次放置结果
+type Placement struct {
+	// Page 是矩形所在页面的序号，从0开始
+	Page int
+	// Rect 是放置结果(不含间距)
+	Rect    Rect2D
+	Rotated bool
+}
+
+// NewConcurrentPacker 创建并发包装器，每个页面使用与 template 相同的尺寸、算法和配置(旋转、间距、排序等)
+//
+// template 本身不会被修改或使用。按顺序放置的基础算法不记录剩余空间，不能在线插入，会返回错误。
+func NewConcurrentPacker(template *Packer) (*ConcurrentPacker, error) {
+	if _, ok := template.algo.(*algorithmBase); ok {
+		return nil, errors.New("sequential algorithm does not support online insertion")
+	}
+	return &ConcurrentPacker{template: template, owners: make(map[int]*concurrentPage)}, nil
+}
+
+// newPage 创建一个空的页面
+func (c *ConcurrentPacker) newPage() *concurrentPage {
+	size := c.template.algo.MaxSize()
+	packer := c.template.newSubPacker(size.Width, size.Height)
+	packer.Online = true
+	return &concurrentPage{packer: packer}
+}
+
+// Insert 放置一个矩形，Quantity 被忽略，ID 在所有页面中应当唯一
+// 返回:
+//
+//	放置结果；true: 放置成功 false: 空页面也放不下或页面数量已达上限
+func (c *ConcurrentPacker) Insert(size Size2D) (Placement, bool) {
+	size.Quantity = 0
+	if c.Deterministic {
+		c.serial.Lock()
+		defer c.serial.Unlock()
+	}
+	var tried int // 已尝试过的页面数，之前的页面都放不下
+	for {
+		c.mu.RLock()
+		pages := c.pages
+		c.mu.RUnlock()
+
+		if placement, ok := c.insertPages(pages, tried, size); ok {
+			return placement, true
+		}
+		tried = len(pages)
+
+		// 在其他 goroutine 打开的新页面中重试，否则打开新页面
+		c.mu.Lock()
+		if len(c.pages) > tried {
+			c.mu.Unlock()
+			continue
+		}
+		if c.MaxPages > 0 && len(c.pages) >= c.MaxPages {
+			c.mu.Unlock()
+			return Placement{}, false
+		}
+		page := c.newPage()
+		placement, ok := page.insert(size)
+		if ok {
+			page.index = len(c.pages)
+			placement.Page = page.index
+			c.pages = append(c.pages, page)
+			c.owners[size.ID] = page
+		}
+		c.mu.Unlock()
+		return placement, ok
+	}
+}
+
+// insertPages 尝试把尺寸放入 pages[tried:]，先跳过被占用的页面，再等待它们
+func (c *ConcurrentPacker) insertPages(pages []*concurrentPage, tried int, size Size2D) (Placement, bool) {
+	n := len(pages) - tried
+	if n <= 0 {
+		return Placement{}, false
+	}
+	start := 0
+	if !c.Deterministic {
+		start = int(c.next.Add(1) % uint64(n))
+	}
+	var busy []*concurrentPage
+	for i := range n {
+		page := pages[tried+(start+i)%n]
+		if !page.mu.TryLock() {
+			busy = append(busy, page)
+			continue
+		}
+		if placement, ok := c.insertLocked(page, size); ok {
+			return placement, true
+		}
+	}
+	for _, page := range busy {
+		page.mu.Lock()
+		if placement, ok := c.insertLocked(page, size); ok {
+			return placement, true
+		}
+	}
+	return Placement{}, false
+}
+
+// insertLocked 在已加锁的页面中放置尺寸并解锁，成功时记录所在页面
+func (c *ConcurrentPacker) insertLocked(page *concurrentPage, size Size2D) (Placement, bool) {
+	defer page.mu.Unlock()
+	placement, ok := page.insert(size)
+	if ok {
+		c.mu.Lock()
+		c.owners[size.ID] = page
+		c.mu.Unlock()
+	}
+	return placement, ok
+}
+
+// insert 在页面中放置尺寸，调用方持有页面的锁或页面尚未公开
+func (page *concurrentPage) insert(size Size2D) (Placement, bool) {
+	if len(page.packer.Insert(size)) > 0 {
+		return Placement{}, false
+	}
+	rects := page.packer.GetPackedRects()
+	rect := rects[len(rects)-1]
+	return Placement{Page: page.index, Rect: rect, Rotated: page.packer.GetIdMapRotated()[rect.ID]}, true
+}
+
+// Remove 移除已放置的矩形并释放它占用的空间
+// 返回:
+//
+//	true: 移除成功 false: 没有该ID的矩形或页面不支持移除(见 Packer.Remove)
+func (c *ConcurrentPacker) Remove(id int) bool {
+	if c.Deterministic {
+		c.serial.Lock()
+		defer c.serial.Unlock()
+	}
+	c.mu.RLock()
+	page := c.owners[id]
+	c.mu.RUnlock()
+	if page == nil {
+		return false
+	}
+	page.mu.Lock()
+	defer page.mu.Unlock()
+	if !page.packer.Remove(id) {
+		return false
+	}
+	c.mu.Lock()
+	delete(c.owners, id)
+	c.mu.Unlock()
+	return true
+}
+
+// Pages 返回当前的页面数量
+func (c *ConcurrentPacker) Pages() int {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+	return len(c.pages)
+}
+
+// Len 返回所有页面中已放置的矩形数量
+func (c *ConcurrentPacker) Len() int {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+	return len(c.owners)
+}
+
+// View 依次锁定每个页面并调用 f，f 只能读取包装器，不能保留它的引用
+func (c *ConcurrentPacker) View(f func(page int, packer *Packer)) {
+	c.mu.RLock()
+	pages := c.pages
+	c.mu.RUnlock()
+	for _, page := range pages {
+		page.mu.Lock()
+		f(page.index, page.packer)
+		page.mu.Unlock()
+	}
+}
@@ -0,0 +1,230 @@
+package rectpack
+
+import (
+	"encoding/csv"
+	"errors"
+	"io"
+	"strconv"
+)
+
+// Part 是切割清单中的一种零件
+type Part struct {
+	// Label 是零件标签，会出现在切割清单中
+	Label  string
+	Width  int
+	Height int
+	// Quantity 是需要的数量，小于1时视为1
+	Quantity int
+	// Grain 为 true 时零件有纹理方向，不允许旋转
+	Grain bool
+}
+
+// Sheet 是库存中的一种板材
+type Sheet struct {
+	// Label 是板材标签，会出现在切割清单中
+	Label  string
+	Width  int
+	Height int
+	// Quantity 是库存数量，0 表示不限
+	Quantity int
+	// Cost 是每张板材的成本
+	Cost float64
+}
+
+// CuttingOptions 切割模式的选项
+type CuttingOptions struct {
+	// Kerf 是锯缝宽度，只在零件之间保留，不在板材边缘保留
+	Kerf int
+	// Heuristic 是 Guillotine 算法的启发式，0 表示 GuillotineBAF
+	Heuristic Heuristic
+	// Stages 是切割阶段数上限，0 表示不限制，参见 Packer.SetGuillotineStages
+	Stages int
+}
+
+// PlacedPart 是放置在板材上的一个零件
+type PlacedPart struct {
+	Label string
+	// Rect 是零件在板材上的位置，ID 为零件在展开数量后的序号
+	Rect    Rect2D
+	Rotated bool
+}
+
+// SheetPlan 是一张板材的切割方案
+type SheetPlan struct {
+	Sheet Sheet
+	Parts []PlacedPart
+	// Tree 是这张板材的切割树，坐标不含板材边缘的锯缝
+	Tree *CutNode
+}
+
+// CuttingPlan 是切割模式的结果
+type CuttingPlan struct {
+	Sheets []SheetPlan
+	// Unplaced 是库存不足或尺寸过大而无法切出的零件，数量已合并
+	Unplaced []Part
+	// Cost 是所用板材的总成本
+	Cost float64
+}
+
+// PlanCuts 为零件选择库存板材并生成 Guillotine 切割方案
+//
+// 板材按 Packer.PackCatalog 相同的策略选择：估算选择每种板材后连同剩余零件还需要的板材的
+// 总成本，选择总成本最低的板材，直到零件全部切出或库存耗尽。
+// 返回:
+//
+//	切割方案；零件或板材尺寸无效、启发式不是 Guillotine 时返回错误
+func PlanCuts(parts []Part, stock []Sheet, options CuttingOptions) (*CuttingPlan, error) {
+	heuristic := options.Heuristic
+	if heuristic == 0 {
+		heuristic = GuillotineBAF
+	}
+	if heuristic.Algorithm() != Guillotine {
+		return nil, errors.New("cutting mode requires a Guillotine heuristic")
+	}
+	if options.Kerf < 0 {
+		return nil, errors.New("kerf must not be negative")
+	}
+	for _, sheet := range stock {
+		if sheet.Width <= 0 || sheet.Height <= 0 {
+			return nil, errors.New("sheet dimensions must be positive")
+		}
+	}
+
+	// owner 记录展开数量后每个序号对应的零件种类
+	var sizes []Size2D
+	var owner []int
+	for i, part := range parts {
+		if part.Width <= 0 || part.Height <= 0 {
+			return nil, errors.New("part dimensions must be positive")
+		}
+		for range max(part.Quantity, 1) {
+			size := NewSize2DByID(len(sizes), part.Width, part.Height)
+			size.NoRotate = part.Grain
+			sizes = append(sizes, size)
+			owner = append(owner, i)
+		}
+	}
+
+	catalog := make([]BinType, len(stock))
+	for i, sheet := range stock {
+		catalog[i] = BinType{Width: sheet.Width, Height: sheet.Height, Cost: sheet.Cost, Limit: sheet.Quantity}
+	}
+	pages, sizes := packBins(sizes, catalog, func(bin BinType) *Packer {
+		return cuttingPacker(bin, heuristic, options)
+	})
+	plan := &CuttingPlan{}
+	for _, page := range pages {
+		plan.Sheets = append(plan.Sheets, sheetPlan(stock[page.index], parts, owner, page.Packer, options.Kerf))
+		plan.Cost += page.Bin.Cost
+	}
+
+	// 无法切出的零件按种类合并数量
+	counts := make(map[int]int)
+	for _, size := range sizes {
+		counts[owner[size.ID]]++
+	}
+	for i, part := range parts {
+		if counts[i] > 0 {
+			part.Quantity = counts[i]
+			plan.Unplaced = append(plan.Unplaced, part)
+		}
+	}
+	return plan, nil
+}
+
+// cuttingPacker 创建切割一张板材所用的包装器
+//
+// 间距会加在每个零件的左上方，因此把容器放大一个锯缝宽度，
+// 放置后再整体平移回来，使锯缝只出现在零件之间。
+func cuttingPacker(sheet BinType, heuristic Heuristic, options CuttingOptions) *Packer {
+	packer, _ := NewPacker(sheet.Width+options.Kerf, sheet.Height+options.Kerf, heuristic)
+	packer.AllowRotate(true)
+	packer.SetPadding(options.Kerf)
+	packer.SetMergePolicy(MergeNone)
+	packer.SetGuillotineStages(options.Stages)
+	return packer
+}
+
+// sheetPlan 把一张板材的打包结果转换为切割方案，并去掉板材边缘的锯缝
+func sheetPlan(sheet Sheet, parts []Part, owner []int, packer *Packer, kerf int) SheetPlan {
+	rotated := packer.GetIdMapRotated()
+	plan := SheetPlan{Sheet: sheet, Tree: packer.CutTree()}
+	for _, rect := range packer.GetPackedRects() {
+		rect.Offset(-kerf, -kerf)
+		rect.NoRotate = false
+		plan.Parts = append(plan.Parts, PlacedPart{
+			Label:   parts[owner[rect.ID]].Label,
+			Rect:    rect,
+			Rotated: rotated[rect.ID],
+		})
+	}
+	if plan.Tree != nil {
+		trimKerf(plan.Tree, kerf)
+	}
+	return plan
+}
+
+// trimKerf 把切割树平移回板材坐标，紧贴板材上边缘和左边缘的节点去掉多出的锯缝
+func trimKerf(node *CutNode, kerf int) {
+	node.walk(func(n *CutNode) {
+		n.X -= kerf
+		n.Y -= kerf
+		if n.Cut != CutNone {
+			n.Offset -= kerf
+		}
+		if n.X < 0 {
+			n.Width += n.X
+			n.X = 0
+		}
+		if n.Y < 0 {
+			n.Height += n.Y
+			n.Y = 0
+		}
+	})
+}
+
+// cutListHeader 是 CSV 切割清单的表头
+var cutListHeader = []string{"sheet", "sheet_label", "part", "id", "x", "y", "width", "height", "rotated"}
+
+// WriteCSV 把切割方案写为 CSV 切割清单，每个零件一行
+//
+// 列依次为：板材序号、板材标签、零件标签、零件序号、x、y、宽、高、是否旋转。
+// 宽高为零件在板材上的实际方向。
+func (plan *CuttingPlan) WriteCSV(w io.Writer) error {
+	writer := csv.NewWriter(w)
+	writer.Write(cutListHeader)
+	for i := range plan.Sheets {
+		if err := plan.Sheets[i].writeRows(writer, i); err != nil {
+			return err
+		}
+	}
+	writer.Flush()
+	return writer.Error()
+}
+
+// WriteCSV 把单张板材的切割清单写为 CSV，列与 CuttingPlan.WriteCSV 相同
+func (s *SheetPlan) WriteCSV(w io.Writer) error {
+	writer := csv.NewWriter(w)
+	writer.Write(cutListHeader)
+	if err := s.writeRows(writer, 0); err != nil {
+		return err
+	}
+	writer.Flush()
+	return writer.Error()
+}
+
+// writeRows 写入板材上每个零件的一行
+func (s *SheetPlan) writeRows(writer *csv.Writer, index int) error {
+	for _, part := range s.Parts {
+		err := writer.Write([]string{
+			strconv.Itoa(index), s.Sheet.Label, part.Label, strconv.Itoa(part.Rect.ID),
+			strconv.Itoa(part.Rect.X), strconv.Itoa(part.Rect.Y),
+			strconv.Itoa(part.Rect.Width), strconv.Itoa(part.Rect.Height),
+			strconv.FormatBool(part.Rotated),
+		})
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
@@ -0,0 +1,231 @@
+package rectpack
+
+import "fmt"
+
+// CutDirection 切割方向
+type CutDirection int
+
+const (
+	// CutNone 表示节点没有被切割(叶子节点)
+	CutNone CutDirection = iota
+	// CutHorizontal 水平切割，沿 y = Offset 把材料分为上下两块
+	CutHorizontal
+	// CutVertical 垂直切割，沿 x = Offset 把材料分为左右两块
+	CutVertical
+)
+
+// String 返回切割方向的名称
+func (d CutDirection) String() string {
+	switch d {
+	case CutNone:
+		return "none"
+	case CutHorizontal:
+		return "horizontal"
+	case CutVertical:
+		return "vertical"
+	}
+	return fmt.Sprintf("CutDirection(%d)", int(d))
+}
+
+// MarshalText 实现 encoding.TextMarshaler，使切割方向在 JSON 中以名称表示
+func (d CutDirection) MarshalText() ([]byte, error) {
+	return []byte(d.String()), nil
+}
+
+// UnmarshalText 实现 encoding.TextUnmarshaler
+func (d *CutDirection) UnmarshalText(text []byte) error {
+	switch string(text) {
+	case "none":
+		*d = CutNone
+	case "horizontal":
+		*d = CutHorizontal
+	case "vertical":
+		*d = CutVertical
+	default:
+		return fmt.Errorf("invalid cut direction %q", text)
+	}
+	return nil
+}
+
+// CutNode 是 Guillotine 切割树的节点，表示一块矩形材料
+//
+// 非叶子节点沿 Cut 方向在 Offset 处被一刀切成两块，Children 依次为上/左和下/右两块。
+// 叶子节点要么是已放置的矩形(Placed 为 true)，要么是剩余材料。
+// 坐标包含间距，即已放置矩形的叶子节点比矩形本身多出 padding 的宽高。
+type CutNode struct {
+	X      int `json:"x"`
+	Y      int `json:"y"`
+	Width  int `json:"width"`
+	Height int `json:"height"`
+	// Cut 是本节点的切割方向，叶子节点为 CutNone
+	Cut CutDirection `json:"cut"`
+	// Offset 是切割位置的绝对坐标，水平切割为 y，垂直切割为 x
+	Offset int `json:"offset,omitempty"`
+	// Stage 是产生本节点的切割所在的阶段，根节点为0，方向每改变一次阶段加1
+	Stage int `json:"stage"`
+	// Placed 为 true 时本节点是已放置的矩形，ID 为其标识
+	Placed   bool       `json:"placed,omitempty"`
+	ID       int        `json:"id,omitempty"`
+	Children []*CutNode `json:"children,omitempty"`
+
+	from CutDirection // 产生本节点的切割方向
+}
+
+// Cut 描述切割序列中的一刀
+type Cut struct {
+	Direction CutDirection `json:"direction"`
+	// Offset 是切割位置的绝对坐标，水平切割为 y，垂直切割为 x
+	Offset int `json:"offset"`
+	Stage  int `json:"stage"`
+	// Piece 是被这一刀切开的材料
+	Piece Rect2D `json:"-"`
+}
+
+// cutNodeChunk 是节点池每次分配的节点数
+const cutNodeChunk = 64
+
+// cutNodePool 分块分配切割树节点，重置后复用已分配的节点，使切割不产生内存分配
+type cutNodePool struct {
+	nodes    [][]CutNode
+	children [][]*CutNode
+	used     int // 已使用的节点数
+	links    int // 已使用的子节点指针数
+}
+
+// reset 回收所有节点，之前返回的切割树随之失效
+func (pool *cutNodePool) reset() {
+	pool.used, pool.links = 0, 0
+}
+
+// node 返回一个新的节点
+func (pool *cutNodePool) node(node CutNode) *CutNode {
+	chunk, i := pool.used/cutNodeChunk, pool.used%cutNodeChunk
+	if chunk == len(pool.nodes) {
+		pool.nodes = append(pool.nodes, make([]CutNode, cutNodeChunk))
+	}
+	pool.used++
+	n := &pool.nodes[chunk][i]
+	*n = node
+	return n
+}
+
+// pair 返回由两个子节点组成的 Children
+func (pool *cutNodePool) pair(first, second *CutNode) []*CutNode {
+	chunk, i := pool.links/(2*cutNodeChunk), pool.links%(2*cutNodeChunk)
+	if chunk == len(pool.children) {
+		pool.children = append(pool.children, make([]*CutNode, 2*cutNodeChunk))
+	}
+	pool.links += 2
+	children := pool.children[chunk][i : i+2 : i+2]
+	children[0], children[1] = first, second
+	return children
+}
+
+// root 创建覆盖整个容器的根节点
+func (pool *cutNodePool) root(width, height int) *CutNode {
+	return pool.node(CutNode{Width: width, Height: height})
+}
+
+// Rect 返回节点覆盖的矩形
+func (n *CutNode) Rect() Rect2D {
+	return NewRect(n.X, n.Y, n.Width, n.Height)
+}
+
+// Sequence 按先序遍历返回可依次执行的切割序列
+func (n *CutNode) Sequence() []Cut {
+	var cuts []Cut
+	n.walk(func(node *CutNode) {
+		if node.Cut != CutNone {
+			cuts = append(cuts, Cut{Direction: node.Cut, Offset: node.Offset, Stage: node.Children[0].Stage, Piece: node.Rect()})
+		}
+	})
+	return cuts
+}
+
+// Stages 返回切割树用到的最大阶段数
+func (n *CutNode) Stages() int {
+	stages := 0
+	n.walk(func(node *CutNode) {
+		stages = max(stages, node.Stage)
+	})
+	return stages
+}
+
+// walk 先序遍历所有节点
+func (n *CutNode) walk(fn func(node *CutNode)) {
+	fn(n)
+	for _, child := range n.Children {
+		child.walk(fn)
+	}
+}
+
+// nextStage 返回在来自 from 方向切割的材料上再沿 dir 方向切割时所在的阶段
+func nextStage(stage int, from, dir CutDirection) int {
+	if from == dir {
+		return stage
+	}
+	return stage + 1
+}
+
+// cut 沿 dir 方向在 offset 处把叶子节点切成两块，返回上/左和下/右两块
+func (n *CutNode) cut(pool *cutNodePool, dir CutDirection, offset int) (*CutNode, *CutNode) {
+	stage := nextStage(n.Stage, n.from, dir)
+	first := pool.node(CutNode{X: n.X, Y: n.Y, Width: n.Width, Height: n.Height, Stage: stage, from: dir})
+	second := pool.node(CutNode{X: n.X, Y: n.Y, Width: n.Width, Height: n.Height, Stage: stage, from: dir})
+	if dir == CutHorizontal {
+		first.Height = offset - n.Y
+		second.Y = offset
+		second.Height = n.Y + n.Height - offset
+	} else {
+		first.Width = offset - n.X
+		second.X = offset
+		second.Width = n.X + n.Width - offset
+	}
+	n.Cut = dir
+	n.Offset = offset
+	n.Children = pool.pair(first, second)
+	return first, second
+}
+
+// split 在左上角放置 width x height 的矩形，horizontal 为 true 时先水平切割再垂直切割，
+// 返回放置矩形所在的节点及下方、右侧剩余材料的节点(不需要切割时为 nil)
+func (n *CutNode) split(pool *cutNodePool, width, height int, horizontal bool) (item, bottom, right *CutNode) {
+	rest := n
+	if horizontal {
+		if height < n.Height {
+			rest, bottom = n.cut(pool, CutHorizontal, n.Y+height)
+		}
+		item = rest
+		if width < n.Width {
+			item, right = rest.cut(pool, CutVertical, n.X+width)
+		}
+	} else {
+		if width < n.Width {
+			rest, right = n.cut(pool, CutVertical, n.X+width)
+		}
+		item = rest
+		if height < n.Height {
+			item, bottom = rest.cut(pool, CutHorizontal, n.Y+height)
+		}
+	}
+	return item, bottom, right
+}
+
+// stagesFor 返回在本节点左上角切出 width x height 的矩形需要用到的最大阶段数
+func (n *CutNode) stagesFor(width, height int, horizontal bool) int {
+	stage, from := n.Stage, n.from
+	first, second := CutHorizontal, CutVertical
+	firstNeeded, secondNeeded := height < n.Height, width < n.Width
+	if !horizontal {
+		first, second = CutVertical, CutHorizontal
+		firstNeeded, secondNeeded = secondNeeded, firstNeeded
+	}
+	if firstNeeded {
+		stage = nextStage(stage, from, first)
+		from = first
+	}
+	if secondNeeded {
+		stage = nextStage(stage, from, second)
+	}
+	return stage
+}
@@ -0,0 +1,102 @@
+package rectpack
+
+// FailReason 描述矩形无法被打包的原因
+type FailReason int
+
+const (
+	// ReasonTooLarge 无论是否旋转，矩形都比容器大
+	ReasonTooLarge FailReason = iota + 1
+	// ReasonRotationDisabled 矩形只有旋转后才能放入容器，但旋转被禁用
+	ReasonRotationDisabled
+	// ReasonPadding 矩形本身能放入容器，但加上间距后放不下
+	ReasonPadding
+	// ReasonAreaExhausted 剩余空间的总面积已不足以容纳该矩形
+	ReasonAreaExhausted
+	// ReasonFragmentation 剩余空间的总面积足够，但没有任何一块剩余矩形足够大
+	ReasonFragmentation
+	// ReasonPlacement 剩余空间中有足够大的位置(或算法不记录剩余空间)，但放置顺序没有用到它，
+	// 例如按顺序放置的基础算法、分层打包的分组或前瞻缓冲
+	ReasonPlacement
+)
+
+// String 返回原因的中文描述
+func (r FailReason) String() string {
+	switch r {
+	case ReasonTooLarge:
+		return "尺寸超过容器(旋转后也放不下)"
+	case ReasonRotationDisabled:
+		return "未允许旋转(旋转后可以放下)"
+	case ReasonPadding:
+		return "加上间距后超过容器"
+	case ReasonAreaExhausted:
+		return "剩余面积不足"
+	case ReasonFragmentation:
+		return "剩余空间碎片化(总面积足够但没有足够大的连续空间)"
+	case ReasonPlacement:
+		return "放置顺序所限(剩余空间足够但未被使用)"
+	}
+	return "未知原因"
+}
+
+// FitsEmptyBin 判断该原因下矩形是否有可能放入一个新的同尺寸空容器
+func (r FailReason) FitsEmptyBin() bool {
+	return r == ReasonAreaExhausted || r == ReasonFragmentation || r == ReasonPlacement
+}
+
+// Diagnosis 描述一个未能打包的矩形及其失败原因
+type Diagnosis struct {
+	// Size 是未能打包的矩形尺寸
+	Size Size2D
+	// Reason 是失败原因
+	Reason FailReason
+	// FreeArea 是诊断时剩余空间的总面积
+	FreeArea int
+	// LargestFree 是诊断时面积最大的剩余矩形，算法不记录剩余空间时为空矩形
+	LargestFree Rect2D
+}
+
+// Diagnose 分析 GetUnpackedRects 中每个矩形无法被打包的原因
+// 返回:
+//
+//	每个未打包矩形的诊断结果，顺序与 GetUnpackedRects 相同
+func (p *Packer) Diagnose() []Diagnosis {
+	if len(p.unpackedSize2Ds) == 0 {
+		return nil
+	}
+	bin := p.algo.MaxSize()
+	freeArea := p.FreeArea()
+	largest := p.LargestFreeRect()
+	tracked := p.algo.getFreeRects() != nil
+
+	diagnoses := make([]Diagnosis, 0, len(p.unpackedSize2Ds))
+	for _, size := range p.unpackedSize2Ds {
+		d := Diagnosis{Size: size, FreeArea: freeArea, LargestFree: largest}
+		rotate := p.allowRotate && !size.NoRotate
+		padded := size
+		padSize(&padded, p.padding)
+		switch {
+		case !fitsIn(size, bin, true):
+			d.Reason = ReasonTooLarge
+		case !fitsIn(size, bin, rotate):
+			d.Reason = ReasonRotationDisabled
+		case !fitsIn(padded, bin, rotate):
+			d.Reason = ReasonPadding
+		case freeArea < padded.Area():
+			d.Reason = ReasonAreaExhausted
+		case !tracked || p.CanFit(size):
+			d.Reason = ReasonPlacement
+		default:
+			d.Reason = ReasonFragmentation
+		}
+		diagnoses = append(diagnoses, d)
+	}
+	return diagnoses
+}
+
+// fitsIn 判断尺寸能否放入容器，rotate 为 true 时也尝试旋转后的方向
+func fitsIn(size, bin Size2D, rotate bool) bool {
+	if size.Width <= bin.Width && size.Height <= bin.Height {
+		return true
+	}
+	return rotate && size.Height <= bin.Width && size.Width <= bin.Height
+}
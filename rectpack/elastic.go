@@ -0,0 +1,298 @@
+package rectpack
+
+import (
+	"maps"
+	"math"
+	"slices"
+)
+
+// Elastic 描述可伸缩矩形允许的尺寸
+//
+// 未设置 Scales 时，宽高可以在 [MinWidth, MaxWidth] 和 [MinHeight, MaxHeight] 范围内任意取值，
+// 为0的范围字段视为 Size2D 原始的宽或高。
+// 设置了 Scales 时，矩形只能按列出的比例对 Size2D 原始宽高等比缩放，范围字段被忽略。
+type Elastic struct {
+	MinWidth  int
+	MaxWidth  int
+	MinHeight int
+	MaxHeight int
+	// Scales 是允许的等比缩放比例，例如 {0.5, 0.75, 1}
+	Scales []float64
+}
+
+// ElasticGoal 可伸缩打包的优化目标
+type ElasticGoal int
+
+const (
+	// ElasticFit 在全部放入一个容器的前提下，选择尽可能大的统一伸缩程度
+	ElasticFit ElasticGoal = iota
+	// ElasticFill 在 ElasticFit 的基础上，把每个可伸缩矩形继续向周围的剩余空间扩展以提高填充率。
+	// 扩展阶段基于 MaxRects 的剩余矩形列表，其他算法只执行 ElasticFit。
+	ElasticFill
+)
+
+// elasticSearchSteps 二分查找伸缩程度的迭代次数
+const elasticSearchSteps = 12
+
+// widthRange 返回宽度的取值范围
+func (e *Elastic) widthRange(base Size2D) (int, int) {
+	lo, hi := e.MinWidth, e.MaxWidth
+	if lo <= 0 {
+		lo = base.Width
+	}
+	if hi <= 0 {
+		hi = base.Width
+	}
+	return lo, max(lo, hi)
+}
+
+// heightRange 返回高度的取值范围
+func (e *Elastic) heightRange(base Size2D) (int, int) {
+	lo, hi := e.MinHeight, e.MaxHeight
+	if lo <= 0 {
+		lo = base.Height
+	}
+	if hi <= 0 {
+		hi = base.Height
+	}
+	return lo, max(lo, hi)
+}
+
+// scales 返回升序排列的缩放比例副本
+func (e *Elastic) scales() []float64 {
+	scales := slices.Clone(e.Scales)
+	slices.Sort(scales)
+	return scales
+}
+
+// nthScale 返回升序排列后的第 k 个缩放比例，不复制 Scales
+func (e *Elastic) nthScale(k int) float64 {
+	for _, scale := range e.Scales {
+		less, equal := 0, 0
+		for _, other := range e.Scales {
+			if other < scale {
+				less++
+			} else if other == scale {
+				equal++
+			}
+		}
+		if less <= k && k < less+equal {
+			return scale
+		}
+	}
+	return e.Scales[k]
+}
+
+// scaled 返回按比例缩放后的尺寸，每边至少为1
+func scaled(base Size2D, scale float64) (int, int) {
+	w := max(1, int(math.Round(float64(base.Width)*scale)))
+	h := max(1, int(math.Round(float64(base.Height)*scale)))
+	return w, h
+}
+
+// at 返回伸缩程度为 t (0.0 最小, 1.0 最大) 时的具体尺寸
+func (e *Elastic) at(base Size2D, t float64) Size2D {
+	size := base
+	if len(e.Scales) > 0 {
+		size.Width, size.Height = scaled(base, e.nthScale(int(t*float64(len(e.Scales)-1))))
+		return size
+	}
+	minW, maxW := e.widthRange(base)
+	minH, maxH := e.heightRange(base)
+	size.Width = minW + int(t*float64(maxW-minW))
+	size.Height = minH + int(t*float64(maxH-minH))
+	return size
+}
+
+// resolveElastic 把所有尺寸在伸缩程度 t 下的具体尺寸写入 dst 并返回
+func resolveElastic(dst, sizes []Size2D, t float64) []Size2D {
+	dst = dst[:0]
+	for _, size := range sizes {
+		if size.Elastic != nil {
+			size = size.Elastic.at(size, t)
+		}
+		dst = append(dst, size)
+	}
+	return dst
+}
+
+// tryElastic 在已放置的矩形之上以伸缩程度 t 重新打包所有暂存的尺寸，返回无法包装的尺寸
+//
+//	placed, rotated - 调用 PackElastic 之前已放置的矩形及其旋转状态，每次尝试都从它们重新开始
+func (p *Packer) tryElastic(t float64, placed []Rect2D, rotated map[int]bool) []Size2D {
+	if len(placed) == 0 {
+		size := p.algo.MaxSize()
+		p.algo.Reset(size.Width, size.Height)
+	} else {
+		p.algo.relayout(placed, rotated, p.padding)
+	}
+	p.scratch = resolveElastic(p.scratch, p.unpackedSize2Ds, t)
+	p.sortSizes(p.scratch)
+	return p.algo.Insert(p.padding, p.scratch...)
+}
+
+// PackElastic 打包暂存的矩形，并为可伸缩矩形(Size2D.Elastic 不为 nil)选择具体尺寸
+//
+// 先用二分查找确定所有可伸缩矩形能全部放入容器的最大统一伸缩程度，
+// goal 为 ElasticFill 时再把每个可伸缩矩形向周围剩余空间扩展。
+// 已放置的矩形保持原位，暂存的矩形放在它们周围的剩余空间中。
+// 选定的尺寸体现在 GetPackedRects 返回的矩形宽高上，也可通过 GetElasticSizes 获取。
+// 返回:
+//
+//	true: 全部打包成功 false: 即使取最小尺寸也无法全部放下(可通过GetUnpackedRects获取失败尺寸)
+func (p *Packer) PackElastic(goal ElasticGoal) bool {
+	if len(p.unpackedSize2Ds) == 0 {
+		return true
+	}
+	placed := slices.Clone(p.algo.GetPackedRects())
+	rotated := maps.Clone(p.algo.GetIdMapRotated())
+	if failed := p.tryElastic(0, placed, rotated); len(failed) != 0 {
+		p.unpackedSize2Ds = append(p.unpackedSize2Ds[:0], failed...)
+		return false
+	}
+
+	originals := make(map[int]Size2D)
+	for _, size := range p.unpackedSize2Ds {
+		if size.Elastic != nil {
+			originals[size.ID] = size
+		}
+	}
+
+	best := 0.0
+	if len(p.tryElastic(1, placed, rotated)) == 0 {
+		best = 1
+	} else {
+		lo, hi := 0.0, 1.0
+		for i := 0; i < elasticSearchSteps; i++ {
+			mid := (lo + hi) / 2
+			if len(p.tryElastic(mid, placed, rotated)) == 0 {
+				lo = mid
+			} else {
+				hi = mid
+			}
+		}
+		best = lo
+		p.tryElastic(best, placed, rotated)
+	}
+	p.unpackedSize2Ds = p.unpackedSize2Ds[:0]
+
+	if goal == ElasticFill {
+		if algo, ok := p.algo.(*maxRects); ok {
+			p.growElastic(algo, originals)
+		}
+	}
+	return true
+}
+
+// growElastic 依次把本次放置的每个可伸缩矩形向右下方的剩余空间扩展，并用 MaxRects 的剩余矩形列表记录新占用的空间
+//
+//	originals - 本次放置的可伸缩矩形伸缩前的原始尺寸，键为矩形ID
+func (p *Packer) growElastic(algo *maxRects, originals map[int]Size2D) {
+	rotated := algo.GetIdMapRotated()
+	rects := algo.GetPackedRects()
+	for i, rect := range rects {
+		base, ok := originals[rect.ID]
+		if !ok {
+			continue
+		}
+		grown := p.growRect(rects, i, base, rotated[rect.ID])
+		if grown.Eq(rect) {
+			continue
+		}
+		oldNode, newNode := rect, grown
+		padRect(&oldNode, p.padding)
+		padRect(&newNode, p.padding)
+		algo.placeRect(newNode)
+		algo.usedArea -= oldNode.Area()
+		rects[i] = grown
+	}
+}
+
+// growRect 返回第 i 个矩形在不与其他矩形冲突的前提下所能扩展到的最大尺寸，base 为伸缩前的原始尺寸
+func (p *Packer) growRect(rects []Rect2D, i int, base Size2D, rotated bool) Rect2D {
+	rect := rects[i]
+	best := rect
+
+	if len(rect.Elastic.Scales) > 0 {
+		scales := rect.Elastic.scales()
+		for j := len(scales) - 1; j >= 0; j-- {
+			candidate := rect
+			candidate.Width, candidate.Height = scaled(base, scales[j])
+			if rotated {
+				candidate.Width, candidate.Height = candidate.Height, candidate.Width
+			}
+			if candidate.Area() <= best.Area() {
+				break
+			}
+			if p.growLimit(rects, i, candidate, true) >= candidate.Width &&
+				p.growLimit(rects, i, candidate, false) >= candidate.Height {
+				return candidate
+			}
+		}
+		return best
+	}
+
+	maxW, maxH := 0, 0
+	if rotated {
+		_, maxW = rect.Elastic.heightRange(base)
+		_, maxH = rect.Elastic.widthRange(base)
+	} else {
+		_, maxW = rect.Elastic.widthRange(base)
+		_, maxH = rect.Elastic.heightRange(base)
+	}
+	// 分别尝试先扩展宽度和先扩展高度，取面积较大者
+	for _, horizontalFirst := range []bool{true, false} {
+		candidate := rect
+		for _, horizontal := range []bool{horizontalFirst, !horizontalFirst} {
+			if horizontal {
+				candidate.Width = max(candidate.Width, min(maxW, p.growLimit(rects, i, candidate, true)))
+			} else {
+				candidate.Height = max(candidate.Height, min(maxH, p.growLimit(rects, i, candidate, false)))
+			}
+		}
+		if candidate.Area() > best.Area() {
+			best = candidate
+		}
+	}
+	return best
+}
+
+// growLimit 计算矩形从左上角向右(horizontal)或向下扩展时，不与其他矩形及容器边界冲突的最大宽度或高度
+func (p *Packer) growLimit(rects []Rect2D, i int, rect Rect2D, horizontal bool) int {
+	size := p.algo.MaxSize()
+	if horizontal {
+		limit := size.Width - rect.X
+		for j, other := range rects {
+			if j != i && other.X >= rects[i].Right() &&
+				other.Y < rect.Bottom()+p.padding && rect.Y < other.Bottom()+p.padding {
+				limit = min(limit, other.X-p.padding-rect.X)
+			}
+		}
+		return limit
+	}
+	limit := size.Height - rect.Y
+	for j, other := range rects {
+		if j != i && other.Y >= rects[i].Bottom() &&
+			other.X < rect.Right()+p.padding && rect.X < other.Right()+p.padding {
+			limit = min(limit, other.Y-p.padding-rect.Y)
+		}
+	}
+	return limit
+}
+
+// GetElasticSizes 获取可伸缩矩形最终选定的尺寸(未旋转时的宽高)，键为矩形ID
+func (p *Packer) GetElasticSizes() map[int]Size2D {
+	rotated := p.algo.GetIdMapRotated()
+	sizes := make(map[int]Size2D)
+	for _, rect := range p.algo.GetPackedRects() {
+		if rect.Elastic == nil {
+			continue
+		}
+		size := rect.Size2D
+		if rotated[rect.ID] {
+			size.Width, size.Height = size.Height, size.Width
+		}
+		sizes[rect.ID] = size
+	}
+	return sizes
+}
@@ -0,0 +1,183 @@
+package rectpack
+
+import "math"
+
+// fitCacheSize 是每种尺寸缓存的候选位置数量
+const fitCacheSize = 32
+
+// fitCandidate 是一个候选位置
+type fitCandidate struct {
+	serial  int // 剩余矩形的编号
+	pos     int // 加入候选时剩余矩形的下标，评分相同的候选按它排列
+	score1  int
+	score2  int
+	rotated bool
+}
+
+// less 按评分和下标比较两个候选
+func (a *fitCandidate) less(b *fitCandidate) bool {
+	if a.score1 != b.score1 {
+		return a.score1 < b.score1
+	}
+	if a.score2 != b.score2 {
+		return a.score2 < b.score2
+	}
+	return a.pos < b.pos
+}
+
+// sameScore 判断两个候选评分是否相同
+func (a *fitCandidate) sameScore(b *fitCandidate) bool {
+	return a.score1 == b.score1 && a.score2 == b.score2
+}
+
+// fitCache 缓存一种尺寸在当前剩余矩形中评分最优的若干个位置
+//
+// MaxRects 每一步都要为每个待放置的尺寸在所有剩余矩形中寻找最优位置，
+// 但放置一个矩形只会移除和新增少量剩余矩形。缓存按评分从小到大保存最多 fitCacheSize 个候选位置，
+// 并保证不在候选中的剩余矩形评分都不小于 (limit1, limit2)。
+// 更新时只需为新增的剩余矩形评分；最优候选的评分小于该界限时就是全局最优，
+// 否则(候选都已被移除，或评分与界限相同)重新扫描所有剩余矩形。
+// 评分相同时取下标最小的剩余矩形，因此结果与每步完整扫描完全一致。
+type fitCache struct {
+	width      int // 加上间距后的宽
+	height     int // 加上间距后的高
+	rotate     bool
+	valid      bool
+	candidates []fitCandidate // candidates[head:] 为按评分排列的候选，已移除的剩余矩形在轮到时跳过
+	head       int
+	limit1     int
+	limit2     int
+	best       int // 最优候选在 candidates 中的下标，-1 表示放不下
+}
+
+// matches 判断缓存是否属于加上间距后为 width x height 的尺寸
+func (c *fitCache) matches(width, height int, rotate bool) bool {
+	return c.valid && c.width == width && c.height == height && c.rotate == rotate
+}
+
+// updateCache 使 p.cache 与 sizes 一一对应并反映当前的剩余矩形
+func (p *maxRects) updateCache(padding int, sizes []Size2D) {
+	for i, size := range sizes {
+		padSize(&size, padding)
+		rotate := p.allowRotate && !size.NoRotate
+		if i == len(p.cache) {
+			// 复用 Reset 前留下的缓存，保留其候选缓冲区
+			if i < cap(p.cache) {
+				p.cache = p.cache[:i+1]
+				p.cache[i].valid = false
+			} else {
+				p.cache = append(p.cache, fitCache{})
+			}
+		}
+		c := &p.cache[i]
+		if !c.matches(size.Width, size.Height, rotate) {
+			// 放完的尺寸被最后一个尺寸填补时，缓存也跟着移动
+			if last := len(p.cache) - 1; last >= len(sizes) && p.cache[last].matches(size.Width, size.Height, rotate) {
+				p.cache[i], p.cache[last] = p.cache[last], p.cache[i]
+			} else {
+				c.width, c.height, c.rotate, c.valid = size.Width, size.Height, rotate, true
+				p.scan(c)
+				continue
+			}
+		}
+		for serial := p.firstNew; serial < len(p.serialPos); serial++ {
+			if pos := p.serialPos[serial]; pos >= 0 {
+				p.consider(c, serial, pos)
+			}
+		}
+		if !p.pickBest(c) {
+			p.scan(c)
+		}
+	}
+	if len(p.cache) > len(sizes) {
+		p.cache = p.cache[:len(sizes)]
+	}
+	p.firstNew = len(p.serialPos)
+}
+
+// scan 按下标顺序为所有剩余矩形评分，重建候选位置
+//
+// 评分相同的候选按下标排列，被淘汰的总是下标最大的，因此扫描后的最优候选一定是全局最优。
+func (p *maxRects) scan(c *fitCache) {
+	c.candidates = c.candidates[:0]
+	c.head = 0
+	c.limit1, c.limit2 = math.MaxInt, math.MaxInt
+	for i := range p.freeRects {
+		p.consider(c, p.freeSerials[i], i)
+	}
+	c.pickBest(p, true)
+}
+
+// pickBest 选出最优候选，返回它是否一定是全局最优
+func (p *maxRects) pickBest(c *fitCache) bool {
+	return c.pickBest(p, false)
+}
+
+// pickBest 跳过已移除的候选，在评分最小的候选中选出当前下标最小的。
+// fresh 为 true 表示刚刚扫描过，此时与界限评分相同也可信；
+// 否则被淘汰的剩余矩形移动后下标可能更小，只有评分小于界限才可信
+func (c *fitCache) pickBest(p *maxRects, fresh bool) bool {
+	for c.head < len(c.candidates) && p.serialPos[c.candidates[c.head].serial] < 0 {
+		c.head++
+	}
+	if c.head == len(c.candidates) {
+		c.best = -1
+		// 没有候选时，只有界限为无穷大(没有任何剩余矩形放得下)才可信
+		return c.limit1 == math.MaxInt
+	}
+	c.best = c.head
+	bestPos := p.serialPos[c.candidates[c.head].serial]
+	for i := c.head + 1; i < len(c.candidates) && c.candidates[i].sameScore(&c.candidates[c.head]); i++ {
+		if pos := p.serialPos[c.candidates[i].serial]; pos >= 0 && pos < bestPos {
+			c.best, bestPos = i, pos
+		}
+	}
+	best := &c.candidates[c.best]
+	return fresh || best.score1 < c.limit1 || (best.score1 == c.limit1 && best.score2 < c.limit2)
+}
+
+// consider 为下标为 pos 的剩余矩形评分，评分小于界限时加入候选
+func (p *maxRects) consider(c *fitCache, serial, pos int) {
+	score1, score2, rotated, ok := p.fitRect(&p.freeRects[pos], c.width, c.height, c.rotate)
+	if !ok || !(score1 < c.limit1 || (score1 == c.limit1 && score2 < c.limit2)) {
+		return
+	}
+	candidate := fitCandidate{serial, pos, score1, score2, rotated}
+	// 新的候选通常评分较小，插入时移动前面的候选
+	i := len(c.candidates)
+	for i > c.head && candidate.less(&c.candidates[i-1]) {
+		i--
+	}
+	if c.head > 0 {
+		copy(c.candidates[c.head-1:i-1], c.candidates[c.head:i])
+		c.head--
+		c.candidates[i-1] = candidate
+	} else {
+		c.candidates = append(c.candidates, fitCandidate{})
+		copy(c.candidates[i+1:], c.candidates[i:])
+		c.candidates[i] = candidate
+	}
+	if len(c.candidates)-c.head > fitCacheSize {
+		// 淘汰评分最大的候选，它的评分成为新的界限
+		last := len(c.candidates) - 1
+		c.limit1, c.limit2 = c.candidates[last].score1, c.candidates[last].score2
+		c.candidates = c.candidates[:last]
+	}
+}
+
+// result 返回缓存的放置位置和用于比较不同尺寸的评分
+func (c *fitCache) result(p *maxRects) (Rect2D, int, int, bool) {
+	if c.best < 0 {
+		return Rect2D{}, math.MaxInt, math.MaxInt, false
+	}
+	best := &c.candidates[c.best]
+	freeRect := &p.freeRects[p.serialPos[best.serial]]
+	node := NewRect(freeRect.X, freeRect.Y, c.width, c.height)
+	if best.rotated {
+		node.Width, node.Height = c.height, c.width
+	}
+	if p.swapScores {
+		return node, best.score2, best.score1, best.rotated
+	}
+	return node, best.score1, best.score2, best.rotated
+}
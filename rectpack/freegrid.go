@@ -0,0 +1,215 @@
+package rectpack
+
+import "slices"
+
+const (
+	// freeGridThreshold 是启用网格索引的剩余矩形数量，数量较少时直接遍历更快
+	freeGridThreshold = 256
+	// freeGridCells 是网格每个方向的格子数
+	freeGridCells = 64
+	// freeGridLarge 是单独记录的剩余矩形覆盖的格子数，大片空白区域的剩余矩形不逐格记录
+	freeGridLarge = 16
+)
+
+// freeGrid 是 MaxRects 剩余矩形的均匀网格索引
+//
+// 每个格子记录与它相交的剩余矩形编号，已移除的编号在查询到该格子时才清理，
+// 覆盖格子较多的剩余矩形单独记录，每次查询都检查。
+// 放置矩形时只需检查与其相交的格子，剪枝时包含新剩余矩形的旧剩余矩形一定覆盖其左上角所在的格子。
+// 查询结果按剩余矩形的下标处理，使剩余矩形列表的顺序与逐个遍历完全一致。
+type freeGrid struct {
+	enabled    bool
+	cellWidth  int
+	cellHeight int
+	cells      [][]int // 每个格子中的剩余矩形编号，最后一个为覆盖格子较多的剩余矩形
+	stamp      []int   // 每个编号最近一次被查询到的序号，用于去重
+	query      int
+	hits       []int // 查询到的剩余矩形下标
+	killers    []int // 每个新剩余矩形被剪枝的时机
+}
+
+// reset 清空索引，剩余矩形数量再次超过阈值时才重新启用
+func (g *freeGrid) reset() {
+	g.enabled = false
+	for i := range g.cells {
+		g.cells[i] = g.cells[i][:0]
+	}
+	g.stamp = g.stamp[:0]
+	g.query = 0
+}
+
+// buildGrid 为当前所有剩余矩形建立索引
+func (p *maxRects) buildGrid() {
+	g := &p.grid
+	g.enabled = true
+	g.cellWidth = max(1, (p.maxWidth+freeGridCells-1)/freeGridCells)
+	g.cellHeight = max(1, (p.maxHeight+freeGridCells-1)/freeGridCells)
+	if g.cells == nil {
+		g.cells = make([][]int, freeGridCells*freeGridCells+1)
+	}
+	for i := range p.freeRects {
+		g.add(p.freeSerials[i], &p.freeRects[i])
+	}
+}
+
+// cellRange 返回矩形覆盖的格子范围(包含两端)，宽高为0的矩形视为覆盖其所在的格子
+func (g *freeGrid) cellRange(rect *Rect2D) (x0, y0, x1, y1 int) {
+	x0 = min(rect.X/g.cellWidth, freeGridCells-1)
+	y0 = min(rect.Y/g.cellHeight, freeGridCells-1)
+	x1 = min((rect.X+max(rect.Width, 1)-1)/g.cellWidth, freeGridCells-1)
+	y1 = min((rect.Y+max(rect.Height, 1)-1)/g.cellHeight, freeGridCells-1)
+	return x0, y0, x1, y1
+}
+
+// add 把编号为 serial 的剩余矩形加入其覆盖的格子
+func (g *freeGrid) add(serial int, rect *Rect2D) {
+	x0, y0, x1, y1 := g.cellRange(rect)
+	if (x1-x0+1)*(y1-y0+1) > freeGridLarge {
+		g.cells[len(g.cells)-1] = append(g.cells[len(g.cells)-1], serial)
+		return
+	}
+	for y := y0; y <= y1; y++ {
+		for x := x0; x <= x1; x++ {
+			g.cells[y*freeGridCells+x] = append(g.cells[y*freeGridCells+x], serial)
+		}
+	}
+}
+
+// cell 返回第 i 个格子中仍存在的剩余矩形编号，顺便清理已移除的编号
+func (p *maxRects) cell(i int) []int {
+	cell := p.grid.cells[i]
+	n := 0
+	for _, serial := range cell {
+		if p.serialPos[serial] >= 0 {
+			cell[n] = serial
+			n++
+		}
+	}
+	cell = cell[:n]
+	p.grid.cells[i] = cell
+	return cell
+}
+
+// overlapping 返回与 node 相交(splitFreeNode 会分割)的剩余矩形下标，从小到大排列
+func (p *maxRects) overlapping(node *Rect2D) []int {
+	g := &p.grid
+	g.query++
+	for len(g.stamp) < len(p.serialPos) {
+		g.stamp = append(g.stamp, 0)
+	}
+	g.hits = g.hits[:0]
+	x0, y0, x1, y1 := g.cellRange(node)
+	for y := y0; y <= y1; y++ {
+		for x := x0; x <= x1; x++ {
+			p.collect(node, y*freeGridCells+x)
+		}
+	}
+	p.collect(node, len(g.cells)-1)
+	slices.Sort(g.hits)
+	return g.hits
+}
+
+// collect 把第 i 个格子中与 node 相交且尚未查询到的剩余矩形下标加入 hits
+func (p *maxRects) collect(node *Rect2D, i int) {
+	g := &p.grid
+	for _, serial := range p.cell(i) {
+		if g.stamp[serial] == g.query {
+			continue
+		}
+		g.stamp[serial] = g.query
+		pos := p.serialPos[serial]
+		if overlaps(&p.freeRects[pos], node) {
+			g.hits = append(g.hits, pos)
+		}
+	}
+}
+
+// overlaps 与 splitFreeNode 使用相同的条件判断 usedNode 是否需要分割 freeNode
+func overlaps(freeNode, usedNode *Rect2D) bool {
+	return !(usedNode.X >= freeNode.X+freeNode.Width || usedNode.X+usedNode.Width <= freeNode.X ||
+		usedNode.Y >= freeNode.Y+freeNode.Height || usedNode.Y+usedNode.Height <= freeNode.Y)
+}
+
+// splitIndexed 用网格索引完成 placeRect 中的分割，结果与逐个遍历剩余矩形一致
+//
+// 逐个遍历时，移除第 i 个剩余矩形会把最后一个移到第 i 个并立即检查它，
+// 因此最后一个也需要分割时在这里同样立即处理。
+func (p *maxRects) splitIndexed(node *Rect2D) {
+	hits := p.overlapping(node)
+	lo, hi := 0, len(hits)
+	for lo < hi {
+		i := hits[lo]
+		lo++
+		for {
+			p.splitFreeNode(&p.freeRects[i], node)
+			last := len(p.freeRects) - 1
+			p.removeFreeRect(i)
+			if i == last || hi == lo || hits[hi-1] != last {
+				break
+			}
+			hi--
+		}
+	}
+}
+
+// firstContainer 返回第 i 个格子中包含 rect 且下标小于 killer 的最小剩余矩形下标，没有则返回 killer
+func (p *maxRects) firstContainer(rect *Rect2D, i, killer int) int {
+	for _, serial := range p.cell(i) {
+		pos := p.serialPos[serial]
+		if (killer < 0 || pos < killer) && p.freeRects[pos].ContainsRect(*rect) {
+			killer = pos
+		}
+	}
+	return killer
+}
+
+// removeFreeRect 用最后一个剩余矩形填补第 i 个的位置
+func (p *maxRects) removeFreeRect(i int) {
+	last := len(p.freeRects) - 1
+	p.serialPos[p.freeSerials[i]] = -1
+	if i != last {
+		p.freeSerials[i] = p.freeSerials[last]
+		p.serialPos[p.freeSerials[i]] = i
+	}
+	p.freeRects[i] = p.freeRects[last]
+	p.freeRects = p.freeRects[:last]
+	p.freeSerials = p.freeSerials[:last]
+}
+
+// pruneIndexed 用网格索引移除被旧剩余矩形包含的新剩余矩形，结果与逐个比较一致
+//
+// 逐个比较时，新剩余矩形在遍历到第一个包含它的旧剩余矩形时被移除，
+// 因此先求出每个新剩余矩形被移除的时机，再按时机依次模拟移除过程。
+func (p *maxRects) pruneIndexed() {
+	g := &p.grid
+	g.killers = g.killers[:0]
+	for j := range p.newFreeRects {
+		newRect := &p.newFreeRects[j]
+		x0, y0, _, _ := g.cellRange(newRect)
+		killer := p.firstContainer(newRect, y0*freeGridCells+x0, -1)
+		killer = p.firstContainer(newRect, len(g.cells)-1, killer)
+		g.killers = append(g.killers, killer)
+	}
+	for {
+		next := -1
+		for _, killer := range g.killers {
+			if killer >= 0 && (next < 0 || killer < next) {
+				next = killer
+			}
+		}
+		if next < 0 {
+			return
+		}
+		for j := 0; j < len(p.newFreeRects); {
+			if g.killers[j] == next {
+				last := len(p.newFreeRects) - 1
+				p.newFreeRects[j] = p.newFreeRects[last]
+				p.newFreeRects = p.newFreeRects[:last]
+				g.killers[j] = g.killers[last]
+				g.killers = g.killers[:last]
+				continue
+			}
+			j++
+		}
+	}
+}
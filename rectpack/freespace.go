@@ -0,0 +1,58 @@
+package rectpack
+
+import "slices"
+
+// FreeRects 返回当前剩余空间的矩形列表(副本)
+//
+// MaxRects 的剩余矩形是互相重叠的最大矩形，Guillotine 的剩余矩形互不重叠(MergeMaximal 时与 MaxRects 相同)。
+// 算法不记录剩余空间时返回 nil。
+func (p *Packer) FreeRects() []Rect2D {
+	return slices.Clone(p.algo.getFreeRects())
+}
+
+// LargestFreeRect 返回面积最大的剩余矩形，没有剩余空间时返回空矩形
+func (p *Packer) LargestFreeRect() Rect2D {
+	var largest Rect2D
+	for _, rect := range p.algo.getFreeRects() {
+		if rect.Area() > largest.Area() {
+			largest = rect
+		}
+	}
+	return largest
+}
+
+// CanFit 判断指定尺寸在当前剩余空间中能否放下，考虑间距和旋转设置，不会改变包装器状态
+func (p *Packer) CanFit(size Size2D) bool {
+	rotate := p.allowRotate && !size.NoRotate
+	padSize(&size, p.padding)
+	for _, rect := range p.algo.getFreeRects() {
+		if fitsIn(size, rect.Size2D, rotate) {
+			return true
+		}
+	}
+	return false
+}
+
+// FreeArea 返回剩余空间的总面积，重叠的剩余矩形只计算一次
+//
+// 算法不记录剩余空间时返回容器面积减去已使用面积。
+func (p *Packer) FreeArea() int {
+	freeRects := p.algo.getFreeRects()
+	if freeRects == nil {
+		size := p.algo.MaxSize()
+		return size.Area() - p.algo.GetUsedArea()
+	}
+	return NewRegion(freeRects...).Area()
+}
+
+// Fragmentation 返回剩余空间的碎片化指数，即 1 - 最大剩余矩形面积/剩余总面积
+//
+// 0.0 表示剩余空间是一整块矩形，越接近 1.0 表示剩余空间越零碎。没有剩余空间时返回 0。
+func (p *Packer) Fragmentation() float64 {
+	freeArea := p.FreeArea()
+	if freeArea == 0 {
+		return 0
+	}
+	largest := p.LargestFreeRect()
+	return 1 - float64(largest.Area())/float64(freeArea)
+}
@@ -0,0 +1,130 @@
+package rectpack
+
+import "fmt"
+
+// GridOptions 是按顺序排列的网格和条带布局的设置
+type GridOptions struct {
+	// CellWidth 和 CellHeight 是单元格尺寸，为0时取最大矩形的宽和高
+	CellWidth  int
+	CellHeight int
+	// Columns 是每行的单元格数，为0时按容器宽度放下尽量多的列；条带布局中忽略
+	Columns int
+	// Spacing 是相邻单元格之间的间距，Margin 是网格与容器边缘的留白
+	Spacing int
+	Margin  int
+	// RowPerGroup 为 true 时 Group 不同的相邻矩形从新的一行开始，例如每行一个动画
+	RowPerGroup bool
+}
+
+// Grid 是网格布局的结果，第 row 行第 column 列单元格的左上角为
+// (Margin + column*(CellWidth+Spacing), Margin + row*(CellHeight+Spacing))
+type Grid struct {
+	CellWidth  int
+	CellHeight int
+	Columns    int
+	Rows       int
+	Spacing    int
+	Margin     int
+}
+
+// Size 返回包含整个网格(含留白)的尺寸
+func (g Grid) Size() Size2D {
+	if g.Columns <= 0 || g.Rows <= 0 {
+		return Size2D{}
+	}
+	return NewSize2D(2*g.Margin+g.Columns*g.CellWidth+(g.Columns-1)*g.Spacing,
+		2*g.Margin+g.Rows*g.CellHeight+(g.Rows-1)*g.Spacing)
+}
+
+// Cell 返回矩形所在单元格的列和行
+func (g Grid) Cell(rect Rect2D) (column, row int) {
+	return (rect.X - g.Margin) / (g.CellWidth + g.Spacing), (rect.Y - g.Margin) / (g.CellHeight + g.Spacing)
+}
+
+// PackGrid 按插入顺序把暂存的矩形逐行放入大小相同的单元格中，例如动画序列帧
+//
+// 每个矩形放在单元格的左上角，不旋转也不使用包装器的间距；容器高度放不下的行及之后的矩形
+// 保持顺序留在 GetUnpackedRects 中，可以在新的页面上继续排列。
+// 网格布局只能用于空的包装器，算法的剩余空间按放置的矩形(不含间距)重建。
+// 返回:
+//
+//	网格；error: 包装器中已有矩形(需要先 Reset)，单元格比某个矩形小，或一行单元格放不进容器
+func (p *Packer) PackGrid(options GridOptions) (Grid, error) {
+	return p.packGrid(options, false)
+}
+
+// PackStrip 按插入顺序把暂存的矩形排成一行大小相同的单元格，RowPerGroup 时每组一行
+//
+// 列数为最长一行的矩形数，其余与 PackGrid 相同。
+func (p *Packer) PackStrip(options GridOptions) (Grid, error) {
+	return p.packGrid(options, true)
+}
+
+// packGrid 实现 PackGrid 和 PackStrip，strip 为 true 时不按列数换行
+func (p *Packer) packGrid(options GridOptions, strip bool) (Grid, error) {
+	if len(p.algo.GetPackedRects()) > 0 {
+		return Grid{}, fmt.Errorf("grid layout needs an empty packer, %d rects are already placed", len(p.algo.GetPackedRects()))
+	}
+	sizes := p.unpackedSize2Ds
+	g := Grid{
+		CellWidth:  options.CellWidth,
+		CellHeight: options.CellHeight,
+		Spacing:    max(options.Spacing, 0),
+		Margin:     max(options.Margin, 0),
+	}
+	if !strip {
+		g.Columns = options.Columns
+	}
+	run := 0 // 条带布局中当前一行的矩形数
+	for i, size := range sizes {
+		if options.CellWidth <= 0 {
+			g.CellWidth = max(g.CellWidth, size.Width)
+		}
+		if options.CellHeight <= 0 {
+			g.CellHeight = max(g.CellHeight, size.Height)
+		}
+		if size.Width > g.CellWidth || size.Height > g.CellHeight {
+			return Grid{}, fmt.Errorf("size %d (%dx%d) is larger than the cell %dx%d", size.ID, size.Width, size.Height, g.CellWidth, g.CellHeight)
+		}
+		if i > 0 && options.RowPerGroup && size.Group != sizes[i-1].Group {
+			run = 0
+		}
+		run++
+		if strip {
+			g.Columns = max(g.Columns, run)
+		}
+	}
+	if len(sizes) == 0 {
+		return Grid{}, nil
+	}
+
+	bin := p.algo.MaxSize()
+	fit := (bin.Width - 2*g.Margin + g.Spacing) / (g.CellWidth + g.Spacing)
+	if g.Columns <= 0 {
+		g.Columns = fit
+	}
+	if g.Columns <= 0 || g.Columns > fit {
+		return Grid{}, fmt.Errorf("%d columns of %dx%d cells do not fit in bin width %d", max(g.Columns, 1), g.CellWidth, g.CellHeight, bin.Width)
+	}
+	if 2*g.Margin+g.CellHeight > bin.Height {
+		return Grid{}, fmt.Errorf("%dx%d cells do not fit in bin height %d", g.CellWidth, g.CellHeight, bin.Height)
+	}
+
+	rects := make([]Rect2D, 0, len(sizes))
+	column, row := 0, 0
+	for i, size := range sizes {
+		if column == g.Columns || column > 0 && options.RowPerGroup && size.Group != sizes[i-1].Group {
+			column, row = 0, row+1
+		}
+		top := g.Margin + row*(g.CellHeight+g.Spacing)
+		if top+g.CellHeight+g.Margin > bin.Height {
+			break
+		}
+		rects = append(rects, Rect2D{Point2D: NewPoint(g.Margin+column*(g.CellWidth+g.Spacing), top), Size2D: size})
+		g.Rows = row + 1
+		column++
+	}
+	p.algo.relayout(rects, nil, 0)
+	p.unpackedSize2Ds = append(p.unpackedSize2Ds[:0], sizes[len(rects):]...)
+	return g, nil
+}
@@ -0,0 +1,104 @@
+package rectpack
+
+import "slices"
+
+// packedGroup 保存一个分组在其区块内的打包结果
+type packedGroup struct {
+	sizes   []Size2D     // 组内原始尺寸
+	rects   []Rect2D     // 组内布局，坐标相对于区块左上角
+	rotated map[int]bool // 组内矩形的旋转状态
+}
+
+// packHierarchical 分层打包
+//
+// 先把每个分组用相同的算法和配置打包到各自的最小矩形区块中，
+// 再把这些区块与未分组的矩形一起作为普通矩形打包到页面上，
+// 最后把组内布局平移到页面坐标。区块本身不会被旋转。
+//
+// 区块在打包过程中使用负数ID，因此用户ID不应为负数。
+// 返回:
+//
+//	true: 全部打包成功 false: 部分失败(可通过GetUnpackedRects获取失败尺寸)
+func (p *Packer) packHierarchical() bool {
+	var items []Size2D
+	var order []int
+	groups := make(map[int][]Size2D)
+	for _, size := range p.unpackedSize2Ds {
+		if size.Group == 0 {
+			items = append(items, size)
+			continue
+		}
+		if _, ok := groups[size.Group]; !ok {
+			order = append(order, size.Group)
+		}
+		groups[size.Group] = append(groups[size.Group], size)
+	}
+	if len(groups) == 0 {
+		return p.packSorted()
+	}
+
+	// 把每个分组打包成一个区块
+	maxSize := p.algo.MaxSize()
+	blocks := make(map[int]*packedGroup, len(groups))
+	var failed []Size2D
+	for i, group := range order {
+		sizes := groups[group]
+		sub := p.newSubPacker(maxSize.Width, maxSize.Height)
+		sub.Insert(slices.Clone(sizes)...)
+		if !sub.Pack() {
+			// 分组在整页中都放不下，整组作为失败
+			failed = append(failed, sizes...)
+			continue
+		}
+		sub.Shrink()
+		// 区块不含右下边缘的间距，外层打包会再加上间距
+		block := sub.MinSize()
+		block.Width -= p.padding
+		block.Height -= p.padding
+		id := -(i + 1)
+		blocks[id] = &packedGroup{
+			sizes:   sizes,
+			rects:   slices.Clone(sub.GetPackedRects()),
+			rotated: sub.GetIdMapRotated(),
+		}
+		items = append(items, Size2D{ID: id, Width: block.Width, Height: block.Height, Group: group, NoRotate: true})
+	}
+
+	// 把区块和未分组的矩形打包到页面
+	p.unpackedSize2Ds = items
+	p.packSorted()
+
+	// 将区块展开为组内矩形，平移到页面坐标
+	rotated := p.algo.GetIdMapRotated()
+	rects := make([]Rect2D, 0, len(p.algo.GetPackedRects()))
+	for _, rect := range p.algo.GetPackedRects() {
+		group, ok := blocks[rect.ID]
+		if !ok {
+			rects = append(rects, rect)
+			continue
+		}
+		delete(blocks, rect.ID)
+		delete(rotated, rect.ID)
+		// 组内矩形已经相对区块左上角偏移了 padding，区块本身也偏移了 padding，只需计算一次
+		for _, child := range group.rects {
+			child.Offset(rect.X-p.padding, rect.Y-p.padding)
+			rects = append(rects, child)
+			if rotated != nil {
+				rotated[child.ID] = group.rotated[child.ID]
+			}
+		}
+	}
+	p.algo.setPackedRects(rects)
+
+	// 未放下的区块还原为组内的原始尺寸
+	unpacked := make([]Size2D, 0, len(p.unpackedSize2Ds)+len(failed))
+	for _, size := range p.unpackedSize2Ds {
+		if group, ok := blocks[size.ID]; ok {
+			unpacked = append(unpacked, group.sizes...)
+		} else {
+			unpacked = append(unpacked, size)
+		}
+	}
+	p.unpackedSize2Ds = append(unpacked, failed...)
+	return len(p.unpackedSize2Ds) == 0
+}
@@ -3,23 +3,56 @@ package rectpack
 import (
 	"math"
 	"slices"
+	"strconv"
 )
 
 type scoreFunc func(width, height int, freeRect *Rect2D) int
 
+// MergePolicy 决定 Guillotine 算法在每次放置后如何合并相邻的剩余矩形
+type MergePolicy int
+
+const (
+	// MergeNone 不合并剩余矩形，切割树保持有效(默认)
+	MergeNone MergePolicy = iota
+	// MergeAdjacent 扫描一遍剩余矩形，合并共享一整条边的相邻矩形
+	MergeAdjacent
+	// MergeMaximal 与 MaxRects 一样把剩余空间记录为全部最大矩形(可以互相重叠)，不再按分割启发式切分，
+	// 部分相接的剩余空间也能一起使用，放置结果不再保证能一刀切
+	MergeMaximal
+)
+
+// String 返回合并策略的名称
+func (m MergePolicy) String() string {
+	switch m {
+	case MergeNone:
+		return "MergeNone"
+	case MergeAdjacent:
+		return "MergeAdjacent"
+	case MergeMaximal:
+		return "MergeMaximal"
+	}
+	return "MergePolicy(" + strconv.Itoa(int(m)) + ")"
+}
+
 type guillotinePack struct {
 	algorithmBase
-	Merge       bool
+	mergePolicy MergePolicy
 	splitMethod Heuristic
 	scoreRect   scoreFunc
 	freeRects   []Rect2D
+	freeNodes   []*CutNode  // 与 freeRects 一一对应的切割树叶子节点
+	cutTree     *CutNode    // 切割树的根节点
+	cutNodes    cutNodePool // 切割树节点池，Reset 后复用
+	cutBroken   bool        // 合并剩余矩形后切割树不再有效
+	stages      int         // 切割阶段数上限，0 表示不限制
+	pieces      []Rect2D    // placeMaximal 复用的缓冲区
 }
 
 func newGuillotine(width, height int, heuristic Heuristic) *guillotinePack {
 	var packer guillotinePack
 	packer.idMapRotated = make(map[int]bool)
 
-	packer.Merge = true
+	packer.mergePolicy = MergeNone
 	packer.splitMethod = SplitMinimizeArea
 	switch heuristic & fitMask {
 	case BestShortSideFit:
@@ -44,78 +77,126 @@ func (p *guillotinePack) Reset(width, height int) {
 	p.algorithmBase.Reset(width, height)
 	p.freeRects = p.freeRects[:0]
 	p.freeRects = append(p.freeRects, NewRect(0, 0, p.maxWidth, p.maxHeight))
+	p.cutNodes.reset()
+	p.cutTree = p.cutNodes.root(p.maxWidth, p.maxHeight)
+	p.cutBroken = false
+	p.freeNodes = append(p.freeNodes[:0], p.cutTree)
+}
+
+// CutTree 返回记录了所有切割的切割树，合并过剩余矩形时切割树无效，返回 nil
+func (p *guillotinePack) CutTree() *CutNode {
+	if p.cutBroken {
+		return nil
+	}
+	return p.cutTree
+}
+
+// canCut 判断在第 i 个剩余矩形中放置 width x height 的矩形是否满足切割阶段数的限制
+// 切割树无效时剩余矩形没有对应的节点，不检查阶段数
+func (p *guillotinePack) canCut(i, width, height int) bool {
+	node := p.freeNodes[i]
+	if p.stages == 0 || node == nil {
+		return true
+	}
+	return node.stagesFor(width, height, true) <= p.stages || node.stagesFor(width, height, false) <= p.stages
+}
+
+func (p *guillotinePack) getFreeRects() []Rect2D {
+	return p.freeRects
 }
 
 func (p *guillotinePack) Insert(padding int, sizes ...Size2D) []Size2D {
+	return p.insertClasses(padding, sizes, p.insertBest, true)
+}
+
+// insertBest 从 sizes 中选出评分最优的一个矩形并放置，返回其索引，无法放置时返回 -1
+func (p *guillotinePack) insertBest(padding int, sizes []Size2D) int {
+	var step *TraceStep
+	if p.tracer != nil {
+		step = p.traceBegin(p.freeRects)
+		step.Candidates = p.traceCandidates(padding, sizes)
+	}
 	bestFreeRect := 0
 	bestRect := 0
 	bestFlipped := false
-	for len(sizes) > 0 {
-		bestScore := math.MaxInt
-		for i, freeRect := range p.freeRects {
-			for j, size := range sizes {
-				padSize(&size, padding)
-				if size.Width == freeRect.Width && size.Height == freeRect.Height {
+	bestScore := math.MaxInt
+	for i := range p.freeRects {
+		freeRect := &p.freeRects[i]
+		for j, size := range sizes {
+			rotate := p.allowRotate && !size.NoRotate
+			padSize(&size, padding)
+			if size.Width == freeRect.Width && size.Height == freeRect.Height {
+				bestFreeRect = i
+				bestRect = j
+				bestFlipped = false
+				bestScore = math.MinInt
+				i = len(p.freeRects)
+				break
+			} else if rotate && size.Height == freeRect.Width && size.Width == freeRect.Height {
+				bestFreeRect = i
+				bestRect = j
+				bestFlipped = true
+				bestScore = math.MinInt
+				i = len(p.freeRects)
+				break
+			} else if size.Width <= freeRect.Width && size.Height <= freeRect.Height && p.canCut(i, size.Width, size.Height) {
+				score := p.scoreRect(size.Width, size.Height, freeRect)
+				if score < bestScore {
 					bestFreeRect = i
 					bestRect = j
 					bestFlipped = false
-					bestScore = math.MinInt
-					i = len(p.freeRects)
-					break
-				} else if p.allowRotate && size.Height == freeRect.Width && size.Width == freeRect.Height {
+					bestScore = score
+				}
+			} else if rotate && size.Height <= freeRect.Width && size.Width <= freeRect.Height && p.canCut(i, size.Height, size.Width) {
+				score := p.scoreRect(size.Height, size.Width, freeRect)
+				if score < bestScore {
 					bestFreeRect = i
 					bestRect = j
 					bestFlipped = true
-					bestScore = math.MinInt
-					i = len(p.freeRects)
-					break
-				} else if size.Width <= freeRect.Width && size.Height <= freeRect.Height {
-					score := p.scoreRect(size.Width, size.Height, &freeRect)
-					if score < bestScore {
-						bestFreeRect = i
-						bestRect = j
-						bestFlipped = false
-						bestScore = score
-					}
-				} else if p.allowRotate && size.Height <= freeRect.Width && size.Width <= freeRect.Height {
-					score := p.scoreRect(size.Height, size.Width, &freeRect)
-					if score < bestScore {
-						bestFreeRect = i
-						bestRect = j
-						bestFlipped = true
-						bestScore = score
-					}
+					bestScore = score
 				}
 			}
 		}
-		if bestScore == math.MaxInt {
-			break
+	}
+	if bestScore == math.MaxInt {
+		if step != nil {
+			p.traceEnd(step, -1, Rect2D{}, false, p.freeRects)
 		}
-		newNode := Rect2D{
-			Point2D: p.freeRects[bestFreeRect].Point2D,
-			Size2D:  sizes[bestRect],
+		return -1
+	}
+	newNode := Rect2D{
+		Point2D: p.freeRects[bestFreeRect].Point2D,
+		Size2D:  sizes[bestRect],
+	}
+	padSize(&newNode.Size2D, padding)
+	if bestFlipped {
+		newNode.Width, newNode.Height = newNode.Height, newNode.Width
+		if !p.idMapRotated[newNode.ID] {
+			p.idMapRotated[newNode.ID] = true
 		}
-		if bestFlipped {
-			newNode.Width, newNode.Height = newNode.Height, newNode.Width
-			if !p.idMapRotated[newNode.ID] {
-				p.idMapRotated[newNode.ID] = true
-			}
-		} else {
-			if p.idMapRotated[newNode.ID] {
-				p.idMapRotated[newNode.ID] = false
-			}
+	} else {
+		if p.idMapRotated[newNode.ID] {
+			p.idMapRotated[newNode.ID] = false
 		}
-		p.splitByHeuristic(&p.freeRects[bestFreeRect], &newNode)
+	}
+	// 限制切割阶段时需要保持切割树有效，不合并剩余矩形
+	if p.stages == 0 && p.mergePolicy == MergeMaximal {
+		p.placeMaximal(&newNode)
+	} else {
+		p.splitByHeuristic(bestFreeRect, &newNode)
 		p.freeRects = slices.Delete(p.freeRects, bestFreeRect, bestFreeRect+1)
-		sizes = slices.Delete(sizes, bestRect, bestRect+1)
-		if p.Merge {
+		p.freeNodes = slices.Delete(p.freeNodes, bestFreeRect, bestFreeRect+1)
+		if p.stages == 0 && p.mergePolicy == MergeAdjacent {
 			p.mergeFreeList()
 		}
-		p.usedArea += newNode.Area()
-		unpadRect(&newNode, padding)
-		p.packed = append(p.packed, newNode)
 	}
-	return sizes
+	p.usedArea += newNode.Area()
+	unpadRect(&newNode, padding)
+	p.packed = append(p.packed, newNode)
+	if step != nil {
+		p.traceEnd(step, bestRect, newNode, bestFlipped, p.freeRects)
+	}
+	return bestRect
 }
 
 func scoreBestArea(width, height int, freeRect *Rect2D) int {
@@ -134,7 +215,9 @@ func scoreBestLong(width, height int, freeRect *Rect2D) int {
 	return max(leftoverHoriz, leftoverVert)
 }
 
-func (p *guillotinePack) splitAlongAxis(freeRect, placedRect *Rect2D, splitHorizontal bool) {
+// splitAlongAxis 把第 i 个剩余矩形在放置矩形的下方和右侧切分为新的剩余矩形，并在切割树上记录切割
+func (p *guillotinePack) splitAlongAxis(i int, placedRect *Rect2D, splitHorizontal bool) {
+	freeRect := p.freeRects[i]
 	var bottom Rect2D
 	bottom.X = freeRect.X
 	bottom.Y = freeRect.Y + placedRect.Height
@@ -150,15 +233,28 @@ func (p *guillotinePack) splitAlongAxis(freeRect, placedRect *Rect2D, splitHoriz
 		bottom.Width = placedRect.Width
 		right.Height = freeRect.Height
 	}
+	// 合并过剩余矩形后切割树不再与剩余矩形对应，只维护占位的空节点
+	var bottomNode, rightNode *CutNode
+	if !p.cutBroken {
+		var item *CutNode
+		item, bottomNode, rightNode = p.freeNodes[i].split(&p.cutNodes, placedRect.Width, placedRect.Height, splitHorizontal)
+		item.Placed = true
+		item.ID = placedRect.ID
+	}
 	if bottom.Width > 0 && bottom.Height > 0 {
 		p.freeRects = append(p.freeRects, bottom)
+		p.freeNodes = append(p.freeNodes, bottomNode)
 	}
 	if right.Width > 0 && right.Height > 0 {
 		p.freeRects = append(p.freeRects, right)
+		p.freeNodes = append(p.freeNodes, rightNode)
 	}
 }
 
-func (p *guillotinePack) splitByHeuristic(freeRect, placedRect *Rect2D) {
+// splitByHeuristic 按分割启发式选择先水平还是先垂直切分第 i 个剩余矩形，
+// 限制切割阶段时若首选方向超出限制则改用另一方向
+func (p *guillotinePack) splitByHeuristic(i int, placedRect *Rect2D) {
+	freeRect := &p.freeRects[i]
 	w := freeRect.Width - placedRect.Width
 	h := freeRect.Height - placedRect.Height
 	var splitHorizontal bool
@@ -178,36 +274,90 @@ func (p *guillotinePack) splitByHeuristic(freeRect, placedRect *Rect2D) {
 	default:
 		splitHorizontal = true
 	}
+	if p.stages > 0 && p.freeNodes[i] != nil && p.freeNodes[i].stagesFor(placedRect.Width, placedRect.Height, splitHorizontal) > p.stages {
+		splitHorizontal = !splitHorizontal
+	}
+
+	p.splitAlongAxis(i, placedRect, splitHorizontal)
+}
 
-	p.splitAlongAxis(freeRect, placedRect, splitHorizontal)
+// placeMaximal 从所有与 placed 重叠的剩余矩形中去掉 placed，使剩余矩形仍是剩余区域的全部最大矩形
+//
+// 每个重叠的剩余矩形分为 placed 上下左右四侧的最大矩形，再去掉被其他剩余矩形包含的部分，
+// 做法与 MaxRects 相同。
+func (p *guillotinePack) placeMaximal(placed *Rect2D) {
+	pieces := p.pieces[:0]
+	kept := p.freeRects[:0]
+	for _, free := range p.freeRects {
+		if !overlaps(&free, placed) {
+			kept = append(kept, free)
+			continue
+		}
+		if placed.Y > free.Y {
+			pieces = append(pieces, NewRect(free.X, free.Y, free.Width, placed.Y-free.Y))
+		}
+		if bottom := placed.Y + placed.Height; bottom < free.Y+free.Height {
+			pieces = append(pieces, NewRect(free.X, bottom, free.Width, free.Y+free.Height-bottom))
+		}
+		if placed.X > free.X {
+			pieces = append(pieces, NewRect(free.X, free.Y, placed.X-free.X, free.Height))
+		}
+		if right := placed.X + placed.Width; right < free.X+free.Width {
+			pieces = append(pieces, NewRect(right, free.Y, free.X+free.Width-right, free.Height))
+		}
+	}
+	p.freeRects = kept
+	p.pieces = pieces
+	for i, piece := range pieces {
+		contained := slices.ContainsFunc(kept, func(free Rect2D) bool { return free.ContainsRect(piece) })
+		// 相同的新矩形只保留第一个
+		for j := 0; j < len(pieces) && !contained; j++ {
+			contained = j != i && pieces[j].ContainsRect(piece) && (pieces[j] != piece || j < i)
+		}
+		if !contained {
+			p.freeRects = append(p.freeRects, piece)
+		}
+	}
+	p.freeNodes = p.freeNodes[:0]
+	for range p.freeRects {
+		p.freeNodes = append(p.freeNodes, nil)
+	}
+	p.cutBroken = true
 }
 
-func (p *guillotinePack) mergeFreeList() {
+// mergeFreeList 合并共享一整条边的相邻剩余矩形，返回是否发生了合并
+func (p *guillotinePack) mergeFreeList() bool {
+	merged := false
 	for i := 0; i < len(p.freeRects); i++ {
 		for j := i + 1; j < len(p.freeRects); j++ {
-			if p.freeRects[i].Width == p.freeRects[i].Width && p.freeRects[i].X == p.freeRects[i].X {
-				if p.freeRects[i].Y == p.freeRects[i].Y+p.freeRects[i].Height {
-					p.freeRects[i].Y -= p.freeRects[i].Height
-					p.freeRects[i].Height += p.freeRects[i].Height
-					p.freeRects = slices.Delete(p.freeRects, j, j+1)
-					j--
-				} else if p.freeRects[i].Y+p.freeRects[i].Height == p.freeRects[i].Y {
-					p.freeRects[i].Height += p.freeRects[i].Height
-					p.freeRects = slices.Delete(p.freeRects, j, j+1)
-					j--
+			a, b := &p.freeRects[i], &p.freeRects[j]
+			if a.Width == b.Width && a.X == b.X {
+				if a.Y == b.Y+b.Height {
+					a.Y -= b.Height
+					a.Height += b.Height
+				} else if a.Y+a.Height == b.Y {
+					a.Height += b.Height
+				} else {
+					continue
 				}
-			} else if p.freeRects[i].Height == p.freeRects[i].Height && p.freeRects[i].Y == p.freeRects[i].Y {
-				if p.freeRects[i].X == p.freeRects[i].X+p.freeRects[i].Width {
-					p.freeRects[i].X -= p.freeRects[i].Width
-					p.freeRects[i].Width += p.freeRects[i].Width
-					p.freeRects = slices.Delete(p.freeRects, j, j+1)
-					j--
-				} else if p.freeRects[i].X+p.freeRects[i].Width == p.freeRects[i].X {
-					p.freeRects[i].Width += p.freeRects[i].Width
-					p.freeRects = slices.Delete(p.freeRects, j, j+1)
-					j--
+			} else if a.Height == b.Height && a.Y == b.Y {
+				if a.X == b.X+b.Width {
+					a.X -= b.Width
+					a.Width += b.Width
+				} else if a.X+a.Width == b.X {
+					a.Width += b.Width
+				} else {
+					continue
 				}
+			} else {
+				continue
 			}
+			p.freeRects = slices.Delete(p.freeRects, j, j+1)
+			p.freeNodes = slices.Delete(p.freeNodes, j, j+1)
+			j--
+			merged = true
+			p.cutBroken = true
 		}
 	}
+	return merged
 }
@@ -2,6 +2,7 @@ package rectpack
 
 import (
 	"errors"
+	"fmt"
 )
 
 type Heuristic uint16
@@ -65,6 +66,44 @@ var (
 	binErr   = errors.New("bin method heuristic is invalid for algorithm type")
 )
 
+// Heuristics 列出所有预设的启发式组合
+var Heuristics = []Heuristic{
+	MaxRectsBSSF, MaxRectsBL, MaxRectsCP, MaxRectsBLSF, MaxRectsBAF,
+	GuillotineBAF, GuillotineBSSF, GuillotineBLSF, GuillotineWAF, GuillotineWSSF, GuillotineWLSF,
+}
+
+// String 返回 "算法-变体" 形式的名称，与 ResolveAlgorithm 接受的名称一致
+func (e Heuristic) String() string {
+	var algo string
+	switch e.Algorithm() {
+	case MaxRects:
+		algo = "MaxRects"
+	case Guillotine:
+		algo = "Guillotine"
+	default:
+		return fmt.Sprintf("Heuristic(%#x)", uint16(e))
+	}
+	var variant string
+	switch e.Bin() {
+	case BestShortSideFit:
+		variant = "BestShortSideFit"
+	case BestLongSideFit:
+		variant = "BestLongSideFit"
+	case BestAreaFit:
+		variant = "BestAreaFit"
+	case BottomLeft:
+		variant = "BottomLeft"
+	case ContactPoint:
+		variant = "ContactPoint"
+	case WorstAreaFit:
+		variant = "WorstAreaFit"
+	case WorstShortSideFit:
+		variant = "WorstShortSideFit"
+	case WorstLongSideFit:
+		variant = "WorstLongSideFit"
+	}
+	return algo + "-" + variant
+}
 
 func ResolveAlgorithm(algo, variant string) Heuristic {
 	switch algo {
@@ -0,0 +1,470 @@
+package rectpack
+
+import (
+	"cmp"
+	"context"
+	"slices"
+)
+
+// Improve 在打包之后进行局部搜索，缩小包含所有矩形的最小尺寸(MinSize)并尽量放下未打包的矩形
+//
+// 每次迭代依次尝试以下移动，接受第一类中最好的一个改进：
+//  1. 把未打包的矩形放入剩余空间；
+//  2. 把决定右边界或下边界的矩形(可旋转时也尝试旋转)移动到其他位置；
+//  3. 把决定边界的矩形与另一个矩形交换位置。
+//
+// 只接受使未打包矩形减少，或使最小尺寸的面积(相同时周长)减小的移动。
+// 没有可以接受的移动、达到 maxIter 次迭代(maxIter 小于等于0表示不限)或 ctx 结束时停止，
+// ctx 结束时返回 ctx.Err()，已接受的移动仍然保留。
+// 移动后算法的剩余空间按新的布局重建，之后可以继续插入；Guillotine 的切割树不再有效。
+// 设置了 Tracer 时，布局改变后从新的布局开始一次新的运行。
+// 分层打包和限制了切割阶段的 Guillotine 需要保持布局结构，不做改动。
+// 返回:
+//
+//	布局是否发生了改变
+func (p *Packer) Improve(ctx context.Context, maxIter int) (bool, error) {
+	return p.improve(ctx, maxIter, true)
+}
+
+// improve 执行 Improve 描述的局部搜索，placeUnpacked 为 false 时不放入未打包的矩形
+func (p *Packer) improve(ctx context.Context, maxIter int, placeUnpacked bool) (bool, error) {
+	if !p.relayoutable() {
+		return false, nil
+	}
+	l := p.newLayout()
+	if placeUnpacked {
+		l.unpacked = slices.Clone(p.unpackedSize2Ds)
+	}
+	improved := false
+	var err error
+	for iter := 0; maxIter <= 0 || iter < maxIter; iter++ {
+		if err = ctx.Err(); err != nil {
+			break
+		}
+		if !l.placeUnpacked() && !l.relocate() && !l.swap() {
+			break
+		}
+		improved = true
+	}
+	if improved {
+		p.applyLayout(l)
+		if placeUnpacked {
+			p.unpackedSize2Ds = l.unpacked
+		}
+	}
+	return improved, err
+}
+
+// ImprovePages 减少按容器目录打包得到的页数，并对每一页调用 Improve
+//
+// 依次尝试把已用面积最小的页面中的矩形全部移到其他页面的剩余空间中，成功则删除该页，
+// 直到无法再删除页面。maxIter 是每一页 Improve 的迭代次数上限。
+// 页面包装器中记录的未打包尺寸已放入之后的页面(或属于 PackCatalog 返回的失败尺寸)，不会再被放入。
+// 返回剩余的页面，ctx 结束时同时返回 ctx.Err()。
+func ImprovePages(ctx context.Context, pages []Page, maxIter int) ([]Page, error) {
+	pages = slices.Clone(pages)
+	for len(pages) > 1 {
+		if err := ctx.Err(); err != nil {
+			return pages, err
+		}
+		lightest := 0
+		for i, page := range pages {
+			if page.Packer.algo.GetUsedArea() < pages[lightest].Packer.algo.GetUsedArea() {
+				lightest = i
+			}
+		}
+		if !emptyPage(pages, lightest) {
+			break
+		}
+		pages = slices.Delete(pages, lightest, lightest+1)
+	}
+	for _, page := range pages {
+		if _, err := page.Packer.improve(ctx, maxIter, false); err != nil {
+			return pages, err
+		}
+	}
+	return pages, nil
+}
+
+// emptyPage 尝试把第 i 页的矩形全部移到其他页面，全部成功时才修改其他页面并返回 true
+func emptyPage(pages []Page, i int) bool {
+	source := pages[i].Packer
+	if source.Hierarchical {
+		return false
+	}
+	layouts := make([]*layout, len(pages))
+	for j, page := range pages {
+		if j != i && !page.Packer.Hierarchical {
+			layouts[j] = page.Packer.newLayout()
+		}
+	}
+	rotated := source.GetIdMapRotated()
+	moved := make([]bool, len(pages))
+	for _, rect := range source.GetPackedRects() {
+		// 按原始方向还原尺寸，目标页面按自己的设置决定是否旋转
+		size := rect.Size2D
+		if rotated[rect.ID] {
+			size.Width, size.Height = size.Height, size.Width
+		}
+		placed := false
+		for j, l := range layouts {
+			if l != nil && l.place(size) {
+				placed, moved[j] = true, true
+				break
+			}
+		}
+		if !placed {
+			return false
+		}
+	}
+	for j, l := range layouts {
+		if moved[j] {
+			pages[j].Packer.applyLayout(l)
+		}
+	}
+	return true
+}
+
+// layout 是局部搜索使用的布局，节点为包含间距的矩形
+type layout struct {
+	bin         Size2D
+	padding     int
+	allowRotate bool
+	nodes       []Rect2D     // 含间距的放置节点，Size2D 保留原尺寸的 ID 等信息
+	rotated     map[int]bool // 每个 ID 是否相对原尺寸旋转
+	unpacked    []Size2D
+	space       *maxRects // 计算剩余空间的辅助算法
+}
+
+// relayoutable 判断已放置的矩形能否任意移动，分层打包和限制了切割阶段的 Guillotine 需要保持布局结构
+func (p *Packer) relayoutable() bool {
+	if algo, ok := p.algo.(*guillotinePack); ok && algo.stages > 0 {
+		return false
+	}
+	return !p.Hierarchical
+}
+
+// newLayout 返回包装器当前布局的副本
+func (p *Packer) newLayout() *layout {
+	bin := p.algo.MaxSize()
+	l := &layout{
+		bin:         bin,
+		padding:     p.padding,
+		allowRotate: p.allowRotate,
+		rotated:     make(map[int]bool),
+		space:       newMaxRects(bin.Width, bin.Height, MaxRectsBL),
+	}
+	rotated := p.algo.GetIdMapRotated()
+	for _, rect := range p.algo.GetPackedRects() {
+		padRect(&rect, p.padding)
+		l.nodes = append(l.nodes, rect)
+		if rotated[rect.ID] {
+			l.rotated[rect.ID] = true
+		}
+	}
+	return l
+}
+
+// applyLayout 用局部搜索的结果替换包装器的布局，并让算法按新布局重建剩余空间
+func (p *Packer) applyLayout(l *layout) {
+	rects := make([]Rect2D, len(l.nodes))
+	for i, node := range l.nodes {
+		unpadRect(&node, l.padding)
+		rects[i] = node
+	}
+	p.algo.relayout(rects, l.rotated, l.padding)
+}
+
+// canRotate 判断节点或尺寸是否允许旋转，可伸缩矩形的伸缩范围与方向有关，不旋转
+func (l *layout) canRotate(size *Size2D) bool {
+	return l.allowRotate && !size.NoRotate && size.Elastic == nil
+}
+
+// cost 返回节点范围为 right x bottom 时的最小尺寸评分，越小越好
+func (l *layout) cost(right, bottom int) (int, int) {
+	w, h := right+l.padding, bottom+l.padding
+	return w * h, w + h
+}
+
+// better 判断评分 a 是否优于 b
+func better(a1, a2, b1, b2 int) bool {
+	return a1 < b1 || a1 == b1 && a2 < b2
+}
+
+// extent 返回除 skip 之外所有节点的右边界和下边界
+func (l *layout) extent(skip ...int) (right, bottom int) {
+	for i, node := range l.nodes {
+		if !slices.Contains(skip, i) {
+			right = max(right, node.X+node.Width)
+			bottom = max(bottom, node.Y+node.Height)
+		}
+	}
+	return right, bottom
+}
+
+// freeRects 返回除 skip 之外所有节点占用后剩余的最大矩形
+func (l *layout) freeRects(skip int) []Rect2D {
+	l.space.Reset(l.bin.Width, l.bin.Height)
+	for i, node := range l.nodes {
+		if i != skip {
+			l.space.placeRect(node)
+		}
+	}
+	return l.space.freeRects
+}
+
+// bestPosition 在剩余空间中为 width x height 的节点寻找使最小尺寸最小的位置，right 和 bottom 为其他节点的范围
+func (l *layout) bestPosition(free []Rect2D, width, height int, rotate bool, right, bottom int) (node Rect2D, flipped bool, score1, score2 int, ok bool) {
+	for _, freeRect := range free {
+		for _, flip := range orientations(rotate) {
+			w, h := width, height
+			if flip {
+				w, h = h, w
+			}
+			if freeRect.Width < w || freeRect.Height < h {
+				continue
+			}
+			s1, s2 := l.cost(max(right, freeRect.X+w), max(bottom, freeRect.Y+h))
+			if !ok || better(s1, s2, score1, score2) ||
+				s1 == score1 && s2 == score2 && cmp.Or(cmp.Compare(freeRect.Y, node.Y), cmp.Compare(freeRect.X, node.X)) < 0 {
+				node, flipped, score1, score2, ok = NewRect(freeRect.X, freeRect.Y, w, h), flip, s1, s2, true
+			}
+		}
+	}
+	return node, flipped, score1, score2, ok
+}
+
+// place 把尺寸放入剩余空间中使最小尺寸最小的位置，size 为原始方向的尺寸
+func (l *layout) place(size Size2D) bool {
+	padSize(&size, l.padding)
+	right, bottom := l.extent()
+	node, flipped, _, _, ok := l.bestPosition(l.freeRects(-1), size.Width, size.Height, l.canRotate(&size), right, bottom)
+	if !ok {
+		return false
+	}
+	width, height := node.Width, node.Height
+	node.Size2D = size
+	node.Width, node.Height = width, height
+	l.nodes = append(l.nodes, node)
+	if flipped {
+		l.rotated[node.ID] = true
+	}
+	return true
+}
+
+// placeUnpacked 把第一个能放下的未打包矩形放入剩余空间
+func (l *layout) placeUnpacked() bool {
+	for i, size := range l.unpacked {
+		if l.place(size) {
+			l.unpacked = slices.Delete(l.unpacked, i, i+1)
+			return true
+		}
+	}
+	return false
+}
+
+// isBoundary 判断节点是否决定了右边界或下边界
+func isBoundary(node Rect2D, right, bottom int) bool {
+	return node.X+node.Width == right || node.Y+node.Height == bottom
+}
+
+// flip 旋转节点并更新旋转记录
+func (l *layout) flip(i int) {
+	node := &l.nodes[i]
+	node.Width, node.Height = node.Height, node.Width
+	l.rotated[node.ID] = !l.rotated[node.ID]
+	if !l.rotated[node.ID] {
+		delete(l.rotated, node.ID)
+	}
+}
+
+// relocate 把决定边界的节点移动到使最小尺寸减小最多的位置
+func (l *layout) relocate() bool {
+	right, bottom := l.extent()
+	best1, best2 := l.cost(right, bottom)
+	bestIndex := -1
+	var bestNode Rect2D
+	var bestFlipped bool
+	for i, node := range l.nodes {
+		if !isBoundary(node, right, bottom) {
+			continue
+		}
+		otherRight, otherBottom := l.extent(i)
+		moved, flipped, s1, s2, ok := l.bestPosition(l.freeRects(i), node.Width, node.Height, l.canRotate(&node.Size2D), otherRight, otherBottom)
+		if ok && better(s1, s2, best1, best2) {
+			best1, best2, bestIndex, bestNode, bestFlipped = s1, s2, i, moved, flipped
+		}
+	}
+	if bestIndex < 0 {
+		return false
+	}
+	node := &l.nodes[bestIndex]
+	node.X, node.Y = bestNode.X, bestNode.Y
+	if bestFlipped {
+		l.flip(bestIndex)
+	}
+	return true
+}
+
+// swap 把决定边界的节点与另一个节点交换位置(可旋转时两者都可以旋转)，选择使最小尺寸减小最多的交换
+func (l *layout) swap() bool {
+	right, bottom := l.extent()
+	best1, best2 := l.cost(right, bottom)
+	bestA, bestB := -1, -1
+	var bestFlipA, bestFlipB bool
+	rights, bottoms := l.topExtents()
+	for a, nodeA := range l.nodes {
+		if !isBoundary(nodeA, right, bottom) {
+			continue
+		}
+		for b, nodeB := range l.nodes {
+			if b == a || isBoundary(nodeB, right, bottom) && b < a {
+				continue
+			}
+			otherRight, otherBottom := rights.without(a, b), bottoms.without(a, b)
+			for _, flipA := range orientations(l.canRotate(&nodeA.Size2D)) {
+				for _, flipB := range orientations(l.canRotate(&nodeB.Size2D)) {
+					movedA, movedB := swapped(nodeA, nodeB, flipA, flipB)
+					s1, s2 := l.cost(max(otherRight, movedA.X+movedA.Width, movedB.X+movedB.Width),
+						max(otherBottom, movedA.Y+movedA.Height, movedB.Y+movedB.Height))
+					if better(s1, s2, best1, best2) && l.fits(movedA, a, b) && l.fits(movedB, a, b) && !overlaps(&movedA, &movedB) {
+						best1, best2, bestA, bestB, bestFlipA, bestFlipB = s1, s2, a, b, flipA, flipB
+					}
+				}
+			}
+		}
+	}
+	if bestA < 0 {
+		return false
+	}
+	movedA, movedB := swapped(l.nodes[bestA], l.nodes[bestB], bestFlipA, bestFlipB)
+	l.nodes[bestA].X, l.nodes[bestA].Y = movedA.X, movedA.Y
+	l.nodes[bestB].X, l.nodes[bestB].Y = movedB.X, movedB.Y
+	if bestFlipA {
+		l.flip(bestA)
+	}
+	if bestFlipB {
+		l.flip(bestB)
+	}
+	return true
+}
+
+// topExtent 是节点右边界(或下边界)中最大的三个值及其节点下标，去掉任意两个节点后的最大值都可以直接得到
+type topExtent [3]struct{ value, index int }
+
+// add 记录第 index 个节点的边界
+func (t *topExtent) add(value, index int) {
+	for i := range t {
+		if value > t[i].value {
+			copy(t[i+1:], t[i:])
+			t[i].value, t[i].index = value, index
+			return
+		}
+	}
+}
+
+// without 返回去掉节点 a 和 b 后的最大值
+func (t *topExtent) without(a, b int) int {
+	for _, e := range t {
+		if e.index != a && e.index != b {
+			return e.value
+		}
+	}
+	return 0
+}
+
+// topExtents 返回所有节点右边界和下边界中最大的三个
+func (l *layout) topExtents() (rights, bottoms topExtent) {
+	for i := range rights {
+		rights[i].index, bottoms[i].index = -1, -1
+	}
+	for i, node := range l.nodes {
+		rights.add(node.X+node.Width, i)
+		bottoms.add(node.Y+node.Height, i)
+	}
+	return rights, bottoms
+}
+
+// swapped 返回两个节点互换左上角位置(并按需旋转)后的矩形
+func swapped(a, b Rect2D, flipA, flipB bool) (Rect2D, Rect2D) {
+	movedA := NewRect(b.X, b.Y, a.Width, a.Height)
+	if flipA {
+		movedA.Width, movedA.Height = a.Height, a.Width
+	}
+	movedB := NewRect(a.X, a.Y, b.Width, b.Height)
+	if flipB {
+		movedB.Width, movedB.Height = b.Height, b.Width
+	}
+	return movedA, movedB
+}
+
+// fits 判断节点位于容器内且不与 a、b 之外的节点重叠
+func (l *layout) fits(node Rect2D, a, b int) bool {
+	if node.X < 0 || node.Y < 0 || node.X+node.Width > l.bin.Width || node.Y+node.Height > l.bin.Height {
+		return false
+	}
+	for i := range l.nodes {
+		if i != a && i != b && overlaps(&l.nodes[i], &node) {
+			return false
+		}
+	}
+	return true
+}
+
+// relayout 用新的布局替换已放置的矩形，按顺序放置的基础算法不记录剩余空间，之后从所有矩形下方继续放置
+func (p *algorithmBase) relayout(rects []Rect2D, rotated map[int]bool, padding int) {
+	p.Reset(p.maxWidth, p.maxHeight)
+	for _, rect := range rects {
+		p.packed = append(p.packed, rect)
+		p.usedArea += rect.Area()
+	}
+	p.setRotated(rotated)
+	p.moveCursorBelow()
+}
+
+// setRotated 按新的布局设置旋转记录
+func (p *algorithmBase) setRotated(rotated map[int]bool) {
+	if p.idMapRotated == nil {
+		p.idMapRotated = make(map[int]bool, len(rotated))
+	}
+	for _, rect := range p.packed {
+		p.idMapRotated[rect.ID] = rotated[rect.ID]
+	}
+}
+
+// relayout 用新的布局替换已放置的矩形，并按占用的节点重新分割剩余矩形
+func (p *maxRects) relayout(rects []Rect2D, rotated map[int]bool, padding int) {
+	p.Reset(p.maxWidth, p.maxHeight)
+	for _, rect := range rects {
+		node := rect
+		padRect(&node, padding)
+		p.placeRect(node)
+		p.packed = append(p.packed, rect)
+	}
+	p.setRotated(rotated)
+}
+
+// relayout 用新的布局替换已放置的矩形，剩余空间分解为互不重叠的矩形(MergeMaximal 时为最大矩形)，
+// 切割树不再有效
+func (p *guillotinePack) relayout(rects []Rect2D, rotated map[int]bool, padding int) {
+	p.Reset(p.maxWidth, p.maxHeight)
+	nodes := make([]Rect2D, len(rects))
+	for i, rect := range rects {
+		nodes[i] = rect
+		padRect(&nodes[i], padding)
+		p.packed = append(p.packed, rect)
+		p.usedArea += nodes[i].Area()
+	}
+	free := NewRegion(NewRect(0, 0, p.maxWidth, p.maxHeight)).SubtractRects(nodes...)
+	if p.mergePolicy == MergeMaximal && p.stages == 0 {
+		p.freeRects = append(p.freeRects[:0], free.MaximalRects()...)
+	} else {
+		p.freeRects = append(p.freeRects[:0], free.Rects()...)
+	}
+	p.freeNodes = p.freeNodes[:0]
+	for range p.freeRects {
+		p.freeNodes = append(p.freeNodes, nil)
+	}
+	p.cutBroken = true
+	p.setRotated(rotated)
+}
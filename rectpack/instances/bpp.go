@@ -0,0 +1,92 @@
+package instances
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+
+	"rectpack2d/rectpack"
+)
+
+// ReadBPP 读取 Berkey & Wang / Martello & Vigo 的 2BP 格式，一个文件可包含多个实例
+//
+// 每个实例依次为问题类别、矩形数量 n、实例的相对和绝对序号、容器的 "H W"，
+// 之后 n 行每行为矩形的 "H W"，每行数字之后的文字说明被忽略。
+// 这些实例不允许旋转，矩形的 ID 按行的顺序从0开始编号。
+func ReadBPP(r io.Reader) ([]*Instance, error) {
+	lines := newLineReader(r)
+	var insts []*Instance
+	for {
+		header, err := lines.next()
+		if err == io.EOF {
+			if len(insts) == 0 {
+				return nil, fmt.Errorf("no instances: %w", io.ErrUnexpectedEOF)
+			}
+			return insts, nil
+		}
+		if err != nil {
+			return nil, err
+		}
+		class := header[0]
+		count, err := lines.expect(1, "item count")
+		if err != nil {
+			return nil, err
+		}
+		n := count[0]
+		if n < 0 {
+			return nil, lines.errorf("invalid item count %d", n)
+		}
+		if _, err := lines.expect(1, "instance number"); err != nil {
+			return nil, err
+		}
+		bin, err := lines.expect(2, "bin size")
+		if err != nil {
+			return nil, err
+		}
+		if err := lines.checkSize("bin", bin[1], bin[0]); err != nil {
+			return nil, err
+		}
+		inst := &Instance{
+			Class: class,
+			Bin:   rectpack.NewSize2D(bin[1], bin[0]),
+			Items: make([]rectpack.Size2D, 0, n),
+		}
+		for i := range n {
+			ints, err := lines.expect(2, "item")
+			if err != nil {
+				return nil, err
+			}
+			if err := lines.checkSize("item", ints[1], ints[0]); err != nil {
+				return nil, err
+			}
+			inst.Items = append(inst.Items, rectpack.NewSize2DByID(i, ints[1], ints[0]))
+		}
+		insts = append(insts, inst)
+	}
+}
+
+// WriteBPP 以 2BP 格式依次写出多个实例，Quantity 大于1的尺寸写为多行
+func WriteBPP(w io.Writer, insts ...*Instance) error {
+	bw := bufio.NewWriter(w)
+	for i, inst := range insts {
+		if i > 0 {
+			bw.WriteString("\n")
+		}
+		fmt.Fprintf(bw, "%5d PROBLEM CLASS\n", inst.Class)
+		fmt.Fprintf(bw, "%5d N. OF ITEMS\n", inst.Count())
+		fmt.Fprintf(bw, "%5d %5d RELATIVE AND ABSOLUTE N. OF INSTANCE\n", i+1, i+1)
+		fmt.Fprintf(bw, "%5d %5d HBIN,WBIN\n", inst.Bin.Height, inst.Bin.Width)
+		first := true
+		for _, item := range inst.Items {
+			for range max(item.Quantity, 1) {
+				fmt.Fprintf(bw, "%5d %5d", item.Height, item.Width)
+				if first {
+					bw.WriteString(" H(I),W(I),I=1,...,N")
+					first = false
+				}
+				bw.WriteString("\n")
+			}
+		}
+	}
+	return bw.Flush()
+}
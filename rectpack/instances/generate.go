@@ -0,0 +1,77 @@
+package instances
+
+import (
+	"fmt"
+	"math/rand/v2"
+
+	"rectpack2d/rectpack"
+)
+
+// Random 生成随机实例：宽高在 [minSize, maxSize) 之间均匀分布，直到总面积达到容器面积的 fill 倍，
+// 相同的 seed 生成相同的实例
+func Random(seed uint64, bin, minSize, maxSize rectpack.Size2D, fill float64) *Instance {
+	r := rand.New(rand.NewPCG(seed, seed))
+	inst := &Instance{Name: fmt.Sprintf("random-%d", seed), Bin: bin}
+	target := int(float64(bin.Area()) * fill)
+	for area := 0; area < target; {
+		size := rectpack.NewSize2DByID(len(inst.Items),
+			minSize.Width+r.IntN(max(maxSize.Width-minSize.Width, 1)),
+			minSize.Height+r.IntN(max(maxSize.Height-minSize.Height, 1)))
+		area += size.Area()
+		inst.Items = append(inst.Items, size)
+	}
+	return inst
+}
+
+// bppClasses 是 Berkey & Wang 第 I-VI 类实例的容器边长和矩形边长上限，矩形边长下限均为1
+var bppClasses = [...]struct{ bin, side int }{
+	{10, 10}, {30, 10}, {40, 35}, {100, 35}, {100, 100}, {300, 100},
+}
+
+// Class 生成经典 2BP 基准的第 class 类实例，包含 n 个矩形，相同的参数生成相同的实例
+//
+// 第 I-VI 类为 Berkey & Wang 的实例，宽高在 [1, 边长上限] 之间均匀分布；
+// 第 VII-X 类为 Martello & Vigo 的实例，容器为 100x100，矩形分为四种：
+// 宽而矮、窄而高、大、小，每类中对应的一种占70%，其余三种各占10%。
+// 生成的实例与文献中的实例同分布，但不是同一组数据。
+func Class(class, n int, seed uint64) (*Instance, error) {
+	if class < 1 || class > 10 {
+		return nil, fmt.Errorf("unknown instance class %d", class)
+	}
+	if n < 0 {
+		return nil, fmt.Errorf("invalid item count %d", n)
+	}
+	r := rand.New(rand.NewPCG(seed, uint64(class)))
+	between := func(lo, hi int) int { return lo + r.IntN(hi-lo+1) }
+	inst := &Instance{Name: fmt.Sprintf("class%02d-n%d-%d", class, n, seed), Class: class, Items: make([]rectpack.Size2D, 0, n)}
+	if class <= len(bppClasses) {
+		c := bppClasses[class-1]
+		inst.Bin = rectpack.NewSize2D(c.bin, c.bin)
+		for i := range n {
+			inst.Items = append(inst.Items, rectpack.NewSize2DByID(i, between(1, c.side), between(1, c.side)))
+		}
+		return inst, nil
+	}
+	const side = 100
+	inst.Bin = rectpack.NewSize2D(side, side)
+	for i := range n {
+		kind := class - 7
+		if p := r.IntN(10); p < 3 {
+			// 其余三种各占10%
+			kind = (kind + 1 + p) % 4
+		}
+		var w, h int
+		switch kind {
+		case 0:
+			w, h = between(side*2/3, side), between(1, side/2)
+		case 1:
+			w, h = between(1, side/2), between(side*2/3, side)
+		case 2:
+			w, h = between(side/2, side), between(side/2, side)
+		default:
+			w, h = between(1, side/2), between(1, side/2)
+		}
+		inst.Items = append(inst.Items, rectpack.NewSize2DByID(i, w, h))
+	}
+	return inst, nil
+}
@@ -0,0 +1,143 @@
+package instances
+
+import (
+	"bufio"
+	"encoding/csv"
+	"fmt"
+	"io"
+	"strconv"
+	"time"
+
+	"rectpack2d/rectpack"
+)
+
+// Result 是一种启发式打包一个实例的结果
+type Result struct {
+	Instance  string
+	Heuristic rectpack.Heuristic
+	// Bins 是使用的容器数量
+	Bins int
+	// LowerBound 是容器数量的面积下界
+	LowerBound int
+	// Fill 是已放置矩形的面积占所用容器总面积的比例
+	Fill float64
+	// Unpacked 是任何容器都放不下的矩形数量
+	Unpacked int
+	// Time 是打包所用的时间
+	Time time.Duration
+}
+
+// Run 用每种启发式打包语料库中的每个实例，heuristics 为空时使用 rectpack.Heuristics
+//
+// 每个实例按需要打开多个相同的容器(见 rectpack.Packer.PackCatalog)，其余配置为包装器的默认值。
+// 结果按实例、启发式的顺序排列。
+func Run(corpus []*Instance, heuristics ...rectpack.Heuristic) ([]Result, error) {
+	if len(heuristics) == 0 {
+		heuristics = rectpack.Heuristics
+	}
+	results := make([]Result, 0, len(corpus)*len(heuristics))
+	for _, inst := range corpus {
+		for _, heuristic := range heuristics {
+			result, err := runOne(inst, heuristic)
+			if err != nil {
+				return nil, fmt.Errorf("%s: %v: %w", inst.Name, heuristic, err)
+			}
+			results = append(results, result)
+		}
+	}
+	return results, nil
+}
+
+// runOne 用一种启发式打包一个实例
+func runOne(inst *Instance, heuristic rectpack.Heuristic) (Result, error) {
+	packer, err := rectpack.NewPacker(inst.Bin.Width, inst.Bin.Height, heuristic)
+	if err != nil {
+		return Result{}, err
+	}
+	packer.AllowRotate(inst.Rotate)
+	packer.Insert(inst.Items...)
+	start := time.Now()
+	pages, _ := packer.PackCatalog([]rectpack.BinType{{Width: inst.Bin.Width, Height: inst.Bin.Height, Cost: 1}})
+	elapsed := time.Since(start)
+
+	result := Result{
+		Instance:   inst.Name,
+		Heuristic:  heuristic,
+		Bins:       len(pages),
+		LowerBound: inst.LowerBound(),
+		Unpacked:   len(packer.GetUnpackedRects()),
+		Time:       elapsed,
+	}
+	used := 0
+	for _, page := range pages {
+		for _, rect := range page.Packer.GetPackedRects() {
+			used += rect.Area()
+		}
+	}
+	if len(pages) > 0 {
+		result.Fill = float64(used) / float64(len(pages)*inst.Bin.Area())
+	}
+	return result, nil
+}
+
+// Summarize 按启发式汇总结果：容器数量、下界、未放置数量和时间求和，填充率取平均值，
+// Instance 为 "total"，顺序与启发式首次出现的顺序相同
+func Summarize(results []Result) []Result {
+	var summary []Result
+	index := make(map[rectpack.Heuristic]int)
+	var counts []int
+	for _, result := range results {
+		i, ok := index[result.Heuristic]
+		if !ok {
+			i = len(summary)
+			index[result.Heuristic] = i
+			summary = append(summary, Result{Instance: "total", Heuristic: result.Heuristic})
+			counts = append(counts, 0)
+		}
+		s := &summary[i]
+		s.Bins += result.Bins
+		s.LowerBound += result.LowerBound
+		s.Fill += result.Fill
+		s.Unpacked += result.Unpacked
+		s.Time += result.Time
+		counts[i]++
+	}
+	for i := range summary {
+		summary[i].Fill /= float64(counts[i])
+	}
+	return summary
+}
+
+// resultHeader 是结果表格的列名
+var resultHeader = []string{"instance", "heuristic", "bins", "lower_bound", "fill", "unpacked", "time_ms"}
+
+// WriteCSV 以 CSV 格式写出结果，填充率为0-1之间的小数，时间以毫秒为单位
+func WriteCSV(w io.Writer, results []Result) error {
+	cw := csv.NewWriter(w)
+	cw.Write(resultHeader)
+	for _, r := range results {
+		cw.Write([]string{
+			r.Instance,
+			r.Heuristic.String(),
+			strconv.Itoa(r.Bins),
+			strconv.Itoa(r.LowerBound),
+			strconv.FormatFloat(r.Fill, 'f', 6, 64),
+			strconv.Itoa(r.Unpacked),
+			strconv.FormatFloat(r.Time.Seconds()*1000, 'f', 3, 64),
+		})
+	}
+	cw.Flush()
+	return cw.Error()
+}
+
+// WriteMarkdown 以 Markdown 表格写出结果，填充率以百分比表示
+func WriteMarkdown(w io.Writer, results []Result) error {
+	bw := bufio.NewWriter(w)
+	bw.WriteString("| Instance | Heuristic | Bins | LB | Fill | Unpacked | Time (ms) |\n")
+	bw.WriteString("|---|---|---:|---:|---:|---:|---:|\n")
+	for _, r := range results {
+		fmt.Fprintf(bw, "| %s | %v | %d | %d | %.2f%% | %d | %.3f |\n",
+			r.Instance, r.Heuristic, r.Bins, r.LowerBound, r.Fill*100, r.Unpacked, r.Time.Seconds()*1000)
+	}
+	return bw.Flush()
+}
@@ -0,0 +1,271 @@
+// Package instances 读写二维装箱问题的基准实例，生成随机实例，并用所有启发式打包实例语料库进行比较
+//
+// 支持的格式:
+//   - FormatText: data.txt 格式，首行为 "W H rotate"，其余每行为 "w h"
+//   - Format2DPackLib: 2DPackLib 格式，首行为尺寸种类数，第二行为 "W H"，其余每行为 "id w h demand"
+//   - FormatBPP: Berkey & Wang / Martello & Vigo 的 2BP 格式，一个文件包含多个实例，尺寸按 "H W" 排列
+package instances
+
+import (
+	"bufio"
+	"bytes"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+
+	"rectpack2d/rectpack"
+)
+
+// Instance 是一个二维装箱问题实例，所有容器尺寸相同
+type Instance struct {
+	// Name 是实例名称，用于结果表格
+	Name string
+	// Class 是 BPP 格式实例的问题类别，其他格式为0
+	Class int
+	// Bin 是容器尺寸
+	Bin rectpack.Size2D
+	// Rotate 为 true 时允许矩形旋转90度
+	Rotate bool
+	// Items 是待打包的尺寸，Quantity 大于1的尺寸表示多个相同的矩形
+	Items []rectpack.Size2D
+}
+
+// Count 返回矩形的数量，Quantity 大于1的尺寸按数量计算
+func (inst *Instance) Count() int {
+	n := 0
+	for _, item := range inst.Items {
+		n += max(item.Quantity, 1)
+	}
+	return n
+}
+
+// Area 返回所有矩形的总面积
+func (inst *Instance) Area() int {
+	area := 0
+	for _, item := range inst.Items {
+		area += item.Area() * max(item.Quantity, 1)
+	}
+	return area
+}
+
+// LowerBound 返回所需容器数量的面积下界
+func (inst *Instance) LowerBound() int {
+	return (inst.Area() + inst.Bin.Area() - 1) / inst.Bin.Area()
+}
+
+// Format 是实例文件的格式
+type Format int
+
+const (
+	// FormatText 是 data.txt 格式
+	FormatText Format = iota
+	// Format2DPackLib 是 2DPackLib 格式
+	Format2DPackLib
+	// FormatBPP 是 Berkey & Wang / Martello & Vigo 的 2BP 格式
+	FormatBPP
+)
+
+// String 返回格式的名称
+func (f Format) String() string {
+	switch f {
+	case FormatText:
+		return "text"
+	case Format2DPackLib:
+		return "2dpacklib"
+	case FormatBPP:
+		return "bpp"
+	}
+	return "Format(" + strconv.Itoa(int(f)) + ")"
+}
+
+// Detect 根据内容的第一个非空行判断实例的格式：
+// 含有文字说明的为 BPP 格式，只有一个数的为 2DPackLib 格式，否则为 data.txt 格式
+func Detect(data []byte) Format {
+	scanner := bufio.NewScanner(bytes.NewReader(data))
+	for scanner.Scan() {
+		fields := strings.Fields(stripComment(scanner.Text()))
+		if len(fields) == 0 {
+			continue
+		}
+		for _, field := range fields {
+			if _, err := strconv.Atoi(field); err != nil {
+				return FormatBPP
+			}
+		}
+		if len(fields) == 1 {
+			return Format2DPackLib
+		}
+		return FormatText
+	}
+	return FormatText
+}
+
+// Read 按指定格式读取实例，BPP 格式可能包含多个实例，其他格式只有一个
+func Read(r io.Reader, format Format) ([]*Instance, error) {
+	switch format {
+	case FormatText:
+		inst, err := ReadText(r)
+		if err != nil {
+			return nil, err
+		}
+		return []*Instance{inst}, nil
+	case Format2DPackLib:
+		inst, err := Read2DPackLib(r)
+		if err != nil {
+			return nil, err
+		}
+		return []*Instance{inst}, nil
+	case FormatBPP:
+		return ReadBPP(r)
+	}
+	return nil, fmt.Errorf("unknown instance format %v", format)
+}
+
+// Write 按指定格式写出实例，除 BPP 格式外只能写出一个实例
+func Write(w io.Writer, format Format, insts ...*Instance) error {
+	if format != FormatBPP && len(insts) != 1 {
+		return fmt.Errorf("%v format holds exactly one instance (given %d)", format, len(insts))
+	}
+	switch format {
+	case FormatText:
+		return WriteText(w, insts[0])
+	case Format2DPackLib:
+		return Write2DPackLib(w, insts[0])
+	case FormatBPP:
+		return WriteBPP(w, insts...)
+	}
+	return fmt.Errorf("unknown instance format %v", format)
+}
+
+// ReadFile 读取实例文件，格式由 Detect 判断，未命名的实例以文件名(不含扩展名)命名，
+// 文件包含多个实例时在文件名后加上序号
+func ReadFile(path string) ([]*Instance, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	insts, err := Read(bytes.NewReader(data), Detect(data))
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", path, err)
+	}
+	name := strings.TrimSuffix(filepath.Base(path), filepath.Ext(path))
+	for i, inst := range insts {
+		if inst.Name != "" {
+			continue
+		}
+		inst.Name = name
+		if len(insts) > 1 {
+			inst.Name = fmt.Sprintf("%s/%d", name, i+1)
+		}
+	}
+	return insts, nil
+}
+
+// WriteFile 按指定格式把实例写入文件
+func WriteFile(path string, format Format, insts ...*Instance) error {
+	var buf bytes.Buffer
+	if err := Write(&buf, format, insts...); err != nil {
+		return err
+	}
+	return os.WriteFile(path, buf.Bytes(), 0o644)
+}
+
+// ReadCorpus 读取与 patterns (filepath.Glob 模式)匹配的所有实例文件，组成实例语料库
+func ReadCorpus(patterns ...string) ([]*Instance, error) {
+	var corpus []*Instance
+	for _, pattern := range patterns {
+		paths, err := filepath.Glob(pattern)
+		if err != nil {
+			return nil, err
+		}
+		if len(paths) == 0 {
+			return nil, fmt.Errorf("no instance files match %q", pattern)
+		}
+		for _, path := range paths {
+			insts, err := ReadFile(path)
+			if err != nil {
+				return nil, err
+			}
+			corpus = append(corpus, insts...)
+		}
+	}
+	return corpus, nil
+}
+
+// lineReader 逐行读取实例文件中每行开头的整数，跳过空行和 # 之后的注释
+type lineReader struct {
+	scanner *bufio.Scanner
+	line    int
+	ints    []int
+}
+
+func newLineReader(r io.Reader) *lineReader {
+	return &lineReader{scanner: bufio.NewScanner(r)}
+}
+
+// next 返回下一个非空行开头的整数(遇到第一个非整数时停止)，文件结束时返回 io.EOF，
+// 返回的切片在下一次读取前有效
+func (r *lineReader) next() ([]int, error) {
+	for r.scanner.Scan() {
+		r.line++
+		fields := strings.Fields(stripComment(r.scanner.Text()))
+		if len(fields) == 0 {
+			continue
+		}
+		r.ints = r.ints[:0]
+		for _, field := range fields {
+			v, err := strconv.Atoi(field)
+			if err != nil {
+				break
+			}
+			r.ints = append(r.ints, v)
+		}
+		if len(r.ints) == 0 {
+			return nil, r.errorf("expected a number, got %q", fields[0])
+		}
+		return r.ints, nil
+	}
+	if err := r.scanner.Err(); err != nil {
+		return nil, err
+	}
+	return nil, io.EOF
+}
+
+// expect 读取下一行，要求开头至少有 n 个整数，文件提前结束时返回 io.ErrUnexpectedEOF
+func (r *lineReader) expect(n int, what string) ([]int, error) {
+	ints, err := r.next()
+	if err == io.EOF {
+		return nil, fmt.Errorf("line %d: missing %s: %w", r.line, what, io.ErrUnexpectedEOF)
+	}
+	if err != nil {
+		return nil, err
+	}
+	if len(ints) < n {
+		return nil, r.errorf("invalid %s: expected %d numbers, got %d", what, n, len(ints))
+	}
+	return ints, nil
+}
+
+// errorf 返回带有当前行号的错误
+func (r *lineReader) errorf(format string, args ...any) error {
+	return fmt.Errorf("line %d: %s", r.line, fmt.Sprintf(format, args...))
+}
+
+// stripComment 去掉 # 之后的注释
+func stripComment(line string) string {
+	if i := strings.IndexByte(line, '#'); i >= 0 {
+		return line[:i]
+	}
+	return line
+}
+
+// checkSize 检查宽高是否为正数
+func (r *lineReader) checkSize(what string, width, height int) error {
+	if width <= 0 || height <= 0 {
+		return r.errorf("invalid %s size %dx%d", what, width, height)
+	}
+	return nil
+}
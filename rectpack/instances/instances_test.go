@@ -0,0 +1,136 @@
+package instances
+
+import (
+	"bytes"
+	"reflect"
+	"strings"
+	"testing"
+
+	"rectpack2d/rectpack"
+)
+
+func TestReadDataText(t *testing.T) {
+	insts, err := ReadFile("../../data.txt")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(insts) != 1 {
+		t.Fatalf("got %d instances, want 1", len(insts))
+	}
+	inst := insts[0]
+	if inst.Name != "data" || inst.Bin != rectpack.NewSize2D(400, 400) || !inst.Rotate || len(inst.Items) != 100 {
+		t.Fatalf("unexpected instance %s %v rotate=%v items=%d", inst.Name, inst.Bin, inst.Rotate, len(inst.Items))
+	}
+}
+
+func TestRoundTrip(t *testing.T) {
+	inst := Random(7, rectpack.NewSize2D(200, 150), rectpack.NewSize2D(5, 5), rectpack.NewSize2D(60, 40), 1.5)
+	inst.Class = 3
+	inst.Rotate = true
+	for _, format := range []Format{FormatText, Format2DPackLib, FormatBPP} {
+		insts := []*Instance{inst}
+		if format == FormatBPP {
+			insts = append(insts, inst)
+		} else if err := Write(&bytes.Buffer{}, format, inst, inst); err == nil {
+			t.Fatalf("%v: writing two instances should fail", format)
+		}
+		var buf bytes.Buffer
+		if err := Write(&buf, format, insts...); err != nil {
+			t.Fatal(err)
+		}
+		if got := Detect(buf.Bytes()); got != format {
+			t.Fatalf("detected %v, want %v", got, format)
+		}
+		got, err := Read(&buf, format)
+		if err != nil {
+			t.Fatalf("%v: %v", format, err)
+		}
+		if len(got) != len(insts) {
+			t.Fatalf("%v: read %d instances, want %d", format, len(got), len(insts))
+		}
+		for _, got := range got {
+			if got.Bin != inst.Bin || !reflect.DeepEqual(got.Items, inst.Items) {
+				t.Fatalf("%v: round trip changed the instance", format)
+			}
+			if format == FormatText && !got.Rotate || format == FormatBPP && got.Class != inst.Class {
+				t.Fatalf("%v: round trip lost the header", format)
+			}
+		}
+	}
+}
+
+func TestRead2DPackLibDemand(t *testing.T) {
+	inst, err := Read2DPackLib(strings.NewReader("2\n10 8\n1 3 2 4 9\n2 5 5\n"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := []rectpack.Size2D{{ID: 0, Width: 3, Height: 2, Quantity: 4}, {ID: 4, Width: 5, Height: 5}}
+	if !reflect.DeepEqual(inst.Items, want) || inst.Count() != 5 {
+		t.Fatalf("got %+v", inst.Items)
+	}
+	for _, bad := range []string{"2\n10 8\n1 3 2\n", "1\n10 8\n1 3 2\n2 1 1\n", "1\n10 0\n1 3 2\n", "1\n10 8\n1 3 2 0\n"} {
+		if _, err := Read2DPackLib(strings.NewReader(bad)); err == nil {
+			t.Errorf("%q: expected an error", bad)
+		}
+	}
+}
+
+func TestClass(t *testing.T) {
+	for class := 1; class <= 10; class++ {
+		inst, err := Class(class, 50, 1)
+		if err != nil {
+			t.Fatal(err)
+		}
+		again, _ := Class(class, 50, 1)
+		if !reflect.DeepEqual(inst, again) {
+			t.Fatalf("class %d is not deterministic", class)
+		}
+		for _, item := range inst.Items {
+			if item.Width < 1 || item.Height < 1 || item.Width > inst.Bin.Width || item.Height > inst.Bin.Height {
+				t.Fatalf("class %d: item %v does not fit bin %v", class, item, inst.Bin)
+			}
+		}
+	}
+	if _, err := Class(11, 10, 1); err == nil {
+		t.Fatal("expected an error for class 11")
+	}
+}
+
+func TestRun(t *testing.T) {
+	var corpus []*Instance
+	for class := 1; class <= 10; class += 3 {
+		inst, _ := Class(class, 40, 2)
+		corpus = append(corpus, inst)
+	}
+	heuristics := []rectpack.Heuristic{rectpack.MaxRectsBSSF, rectpack.GuillotineBAF}
+	results, err := Run(corpus, heuristics...)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(results) != len(corpus)*len(heuristics) {
+		t.Fatalf("got %d results", len(results))
+	}
+	for _, r := range results {
+		if r.Bins < r.LowerBound || r.Unpacked != 0 || r.Fill <= 0 || r.Fill > 1 {
+			t.Fatalf("unexpected result %+v", r)
+		}
+	}
+	summary := Summarize(results)
+	if len(summary) != len(heuristics) || summary[0].Heuristic != heuristics[0] {
+		t.Fatalf("unexpected summary %+v", summary)
+	}
+
+	var csv, md bytes.Buffer
+	if err := WriteCSV(&csv, results); err != nil {
+		t.Fatal(err)
+	}
+	if err := WriteMarkdown(&md, append(results, summary...)); err != nil {
+		t.Fatal(err)
+	}
+	if n := strings.Count(csv.String(), "\n"); n != len(results)+1 {
+		t.Fatalf("csv has %d lines", n)
+	}
+	if n := strings.Count(md.String(), "\n"); n != len(results)+len(summary)+2 {
+		t.Fatalf("markdown has %d lines", n)
+	}
+}
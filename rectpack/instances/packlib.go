@@ -0,0 +1,75 @@
+package instances
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+
+	"rectpack2d/rectpack"
+)
+
+// Read2DPackLib 读取 2DPackLib 格式的实例
+//
+// 首行为尺寸种类数 m，第二行为容器的 "W H"，之后 m 行每行为 "id w h demand"，
+// 其后的价值等字段被忽略，省略 demand 时数量为1。
+// 2DPackLib 的装箱实例不允许旋转；数量大于1的尺寸读取为 Quantity，
+// 矩形的 ID 按展开后的顺序从0开始连续编号，原文件中的 id 不保留。
+func Read2DPackLib(r io.Reader) (*Instance, error) {
+	lines := newLineReader(r)
+	header, err := lines.expect(1, "item count")
+	if err != nil {
+		return nil, err
+	}
+	m := header[0]
+	if m < 0 {
+		return nil, lines.errorf("invalid item count %d", m)
+	}
+	bin, err := lines.expect(2, "bin size")
+	if err != nil {
+		return nil, err
+	}
+	if err := lines.checkSize("bin", bin[0], bin[1]); err != nil {
+		return nil, err
+	}
+	inst := &Instance{Bin: rectpack.NewSize2D(bin[0], bin[1])}
+	id := 0
+	for range m {
+		ints, err := lines.expect(3, "item")
+		if err != nil {
+			return nil, err
+		}
+		if err := lines.checkSize("item", ints[1], ints[2]); err != nil {
+			return nil, err
+		}
+		demand := 1
+		if len(ints) > 3 {
+			demand = ints[3]
+		}
+		if demand < 1 {
+			return nil, lines.errorf("invalid demand %d", demand)
+		}
+		item := rectpack.NewSize2DByID(id, ints[1], ints[2])
+		if demand > 1 {
+			item.Quantity = demand
+		}
+		inst.Items = append(inst.Items, item)
+		id += demand
+	}
+	if _, err := lines.next(); err != io.EOF {
+		if err != nil {
+			return nil, err
+		}
+		return nil, lines.errorf("more than %d items", m)
+	}
+	return inst, nil
+}
+
+// Write2DPackLib 以 2DPackLib 格式写出实例，每个尺寸写为一行，id 从1开始编号
+func Write2DPackLib(w io.Writer, inst *Instance) error {
+	bw := bufio.NewWriter(w)
+	fmt.Fprintf(bw, "%d\n%d %d\n", len(inst.Items), inst.Bin.Width, inst.Bin.Height)
+	for i, item := range inst.Items {
+		fmt.Fprintf(bw, "%d %d %d %d\n", i+1, item.Width, item.Height, max(item.Quantity, 1))
+	}
+	return bw.Flush()
+}
@@ -0,0 +1,56 @@
+package instances
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+
+	"rectpack2d/rectpack"
+)
+
+// ReadText 读取 data.txt 格式的实例：首行为 "W H rotate"(rotate 为1时允许旋转，可省略)，其余每行为 "w h"，
+// 矩形的 ID 按行的顺序从0开始编号
+func ReadText(r io.Reader) (*Instance, error) {
+	lines := newLineReader(r)
+	header, err := lines.expect(2, "bin size")
+	if err != nil {
+		return nil, err
+	}
+	inst := &Instance{Bin: rectpack.NewSize2D(header[0], header[1])}
+	if err := lines.checkSize("bin", header[0], header[1]); err != nil {
+		return nil, err
+	}
+	inst.Rotate = len(header) > 2 && header[2] == 1
+	for {
+		ints, err := lines.next()
+		if err == io.EOF {
+			return inst, nil
+		}
+		if err != nil {
+			return nil, err
+		}
+		if len(ints) < 2 {
+			return nil, lines.errorf("invalid item: expected 2 numbers, got %d", len(ints))
+		}
+		if err := lines.checkSize("item", ints[0], ints[1]); err != nil {
+			return nil, err
+		}
+		inst.Items = append(inst.Items, rectpack.NewSize2DByID(len(inst.Items), ints[0], ints[1]))
+	}
+}
+
+// WriteText 以 data.txt 格式写出实例，Quantity 大于1的尺寸写为多行
+func WriteText(w io.Writer, inst *Instance) error {
+	bw := bufio.NewWriter(w)
+	rotate := 0
+	if inst.Rotate {
+		rotate = 1
+	}
+	fmt.Fprintf(bw, "%d %d %d\n", inst.Bin.Width, inst.Bin.Height, rotate)
+	for _, item := range inst.Items {
+		for range max(item.Quantity, 1) {
+			fmt.Fprintf(bw, "%d %d\n", item.Width, item.Height)
+		}
+	}
+	return bw.Flush()
+}
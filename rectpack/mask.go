@@ -0,0 +1,265 @@
+package rectpack
+
+import (
+	"image"
+	"math/bits"
+)
+
+// Mask 是矩形内逐像素的占用位图，用于按形状嵌套打包(PackMasks)
+//
+// 例如由精灵图的 alpha 通道得到：不透明的像素为占用。L 形或斜向的精灵只有占用的像素
+// 不能与其他矩形重叠，外接矩形可以互相交错。
+type Mask struct {
+	Width  int
+	Height int
+	stride int      // 每行的字数
+	bits   []uint64 // 按行存储，每行从最低位开始
+}
+
+// NewMask 创建 width x height 的空位图
+func NewMask(width, height int) *Mask {
+	stride := (width + 63) / 64
+	return &Mask{Width: width, Height: height, stride: stride, bits: make([]uint64, stride*height)}
+}
+
+// MaskFromImage 由图像中 rect 区域的 alpha 通道创建位图，alpha 大于 threshold 的像素为占用
+func MaskFromImage(img image.Image, rect image.Rectangle, threshold uint8) *Mask {
+	rect = rect.Intersect(img.Bounds())
+	m := NewMask(rect.Dx(), rect.Dy())
+	for y := rect.Min.Y; y < rect.Max.Y; y++ {
+		for x := rect.Min.X; x < rect.Max.X; x++ {
+			if _, _, _, a := img.At(x, y).RGBA(); a>>8 > uint32(threshold) {
+				m.Set(x-rect.Min.X, y-rect.Min.Y, true)
+			}
+		}
+	}
+	return m
+}
+
+// Set 设置像素 (x, y) 是否占用，超出范围时忽略
+func (m *Mask) Set(x, y int, occupied bool) {
+	if x < 0 || y < 0 || x >= m.Width || y >= m.Height {
+		return
+	}
+	word, bit := &m.bits[y*m.stride+x/64], uint64(1)<<(x%64)
+	if occupied {
+		*word |= bit
+	} else {
+		*word &^= bit
+	}
+}
+
+// Get 返回像素 (x, y) 是否占用，超出范围时为 false
+func (m *Mask) Get(x, y int) bool {
+	if x < 0 || y < 0 || x >= m.Width || y >= m.Height {
+		return false
+	}
+	return m.bits[y*m.stride+x/64]&(1<<(x%64)) != 0
+}
+
+// Count 返回占用的像素数
+func (m *Mask) Count() int {
+	n := 0
+	for _, word := range m.bits {
+		n += bits.OnesCount64(word)
+	}
+	return n
+}
+
+// Rotated 返回顺时针旋转90度后的位图，与包装器旋转矩形时图片的旋转方向相同
+func (m *Mask) Rotated() *Mask {
+	r := NewMask(m.Height, m.Width)
+	for y := range m.Height {
+		for x := range m.Width {
+			if m.Get(x, y) {
+				r.Set(m.Height-1-y, x, true)
+			}
+		}
+	}
+	return r
+}
+
+// cellMask 是按单元格缩小后的位图，一个单元格中有任意像素占用即为占用
+type cellMask struct {
+	width, height int
+	stride        int
+	bits          []uint64
+}
+
+// newCellMask 创建 width x height 个单元格的空位图
+func newCellMask(width, height int) *cellMask {
+	stride := (width + 63) / 64
+	return &cellMask{width: width, height: height, stride: stride, bits: make([]uint64, stride*height)}
+}
+
+func (c *cellMask) set(x, y int) {
+	c.bits[y*c.stride+x/64] |= 1 << (x % 64)
+}
+
+func (c *cellMask) get(x, y int) bool {
+	return c.bits[y*c.stride+x/64]&(1<<(x%64)) != 0
+}
+
+func (c *cellMask) row(y int) []uint64 {
+	return c.bits[y*c.stride : (y+1)*c.stride]
+}
+
+// downsample 把位图缩小为 cell x cell 像素的单元格，并向四周扩展 dilate 个单元格
+//
+// 扩展后的位图左上角相对原位图偏移 (-dilate, -dilate)。m 为 nil 时整个 width x height 都占用。
+func downsample(m *Mask, width, height, cell, dilate int) *cellMask {
+	w, h := (width+cell-1)/cell, (height+cell-1)/cell
+	c := newCellMask(w+2*dilate, h+2*dilate)
+	for y := range height {
+		for x := range width {
+			if m == nil || m.Get(x, y) {
+				cx, cy := x/cell, y/cell
+				for dy := range 2*dilate + 1 {
+					for dx := range 2*dilate + 1 {
+						c.set(cx+dx, cy+dy)
+					}
+				}
+			}
+		}
+	}
+	return c
+}
+
+// collides 判断 item 以左上角 (x, y) 放入 grid 时是否与已占用的单元格重叠
+func (c *cellMask) collides(item *cellMask, x, y int) bool {
+	word, shift := x/64, uint(x%64)
+	for r := range item.height {
+		gridRow := c.row(y + r)[word:]
+		for i, m := range item.row(r) {
+			if m == 0 {
+				continue
+			}
+			if gridRow[i]&(m<<shift) != 0 {
+				return true
+			}
+			if shift > 0 && i+1 < len(gridRow) && gridRow[i+1]&(m>>(64-shift)) != 0 {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// occupy 把 item 以左上角 (x, y) 标记到 grid 中
+func (c *cellMask) occupy(item *cellMask, x, y int) {
+	for r := range item.height {
+		for cx := range item.width {
+			if item.get(cx, r) {
+				c.set(x+cx, y+r)
+			}
+		}
+	}
+}
+
+// PackMasks 按形状嵌套打包暂存的矩形：矩形的外接框可以互相交错，只要求占用的像素不重叠
+//
+// masks 以ID给出每个矩形的位图，尺寸必须与矩形相同，缺少或尺寸不符时按整个矩形占用。
+// 位图按 cell x cell 像素的单元格缩小后检测碰撞，cell 越大越快但嵌套得越松，小于1时为1；
+// 间距按单元格向上取整。矩形按排序规则依次放在最靠上、其次最靠左的位置(允许旋转时取底边较靠上的方向)。
+//
+// 打包后 GetPackedRects 返回的仍是外接矩形，它们可能互相重叠，Validate 会报告这些重叠；
+// 算法的剩余空间按外接矩形重建，之后可以继续按矩形插入。
+// 分层打包和限制了切割阶段的 Guillotine 需要保持布局结构，此时按矩形打包(Pack)。
+// 返回:
+//
+//	true: 全部打包成功 false: 部分失败(可通过GetUnpackedRects获取失败尺寸)
+func (p *Packer) PackMasks(masks map[int]*Mask, cell int) bool {
+	if !p.relayoutable() {
+		return p.Pack()
+	}
+	cell = max(cell, 1)
+	padding := max(p.padding, 0)
+	dilate := (padding + cell - 1) / cell
+	bin := p.algo.MaxSize()
+	// 矩形从 (padding, padding) 开始放置，与矩形打包时容器左上边缘的间距相同
+	gridW, gridH := (bin.Width-padding)/cell, (bin.Height-padding)/cell
+	if gridW <= 0 || gridH <= 0 {
+		return len(p.unpackedSize2Ds) == 0
+	}
+	// 网格四周留出 dilate 个单元格，扩展后的位图不会越界
+	grid := newCellMask(gridW+2*dilate, gridH+2*dilate)
+	rects := append([]Rect2D(nil), p.algo.GetPackedRects()...)
+	rotated := make(map[int]bool, len(rects))
+	for id, r := range p.algo.GetIdMapRotated() {
+		rotated[id] = r
+	}
+	for _, rect := range rects {
+		// 已放置的矩形按外接框占用
+		x0, y0 := max(rect.X-padding, 0)/cell, max(rect.Y-padding, 0)/cell
+		x1, y1 := min((rect.Right()-padding+cell-1)/cell, gridW), min((rect.Bottom()-padding+cell-1)/cell, gridH)
+		for y := y0; y < y1; y++ {
+			for x := x0; x < x1; x++ {
+				grid.set(x+dilate, y+dilate)
+			}
+		}
+	}
+
+	p.sortSizes(p.unpackedSize2Ds)
+	var failed []Size2D
+	for _, size := range p.unpackedSize2Ds {
+		mask := masks[size.ID]
+		if mask != nil && (mask.Width != size.Width || mask.Height != size.Height) {
+			mask = nil
+		}
+		bestX, bestY, bestBottom, bestRotated, found := 0, 0, 0, false, false
+		var bestShape *cellMask
+		for _, rotate := range []bool{false, true} {
+			if rotate && (!p.allowRotate || size.NoRotate || size.Width == size.Height) {
+				continue
+			}
+			width, height, m := size.Width, size.Height, mask
+			if rotate {
+				width, height = height, width
+				if m != nil {
+					m = m.Rotated()
+				}
+			}
+			if (width+cell-1)/cell > gridW || (height+cell-1)/cell > gridH {
+				continue
+			}
+			shape := downsample(m, width, height, cell, 0)
+			probe := downsample(m, width, height, cell, dilate)
+			x, y, ok := grid.firstFit(probe, shape, gridW, gridH, bestBottom, found)
+			if ok && (!found || y+shape.height < bestBottom || y+shape.height == bestBottom && x < bestX) {
+				bestX, bestY, bestBottom, bestRotated, bestShape, found = x, y, y+shape.height, rotate, shape, true
+			}
+		}
+		if !found {
+			failed = append(failed, size)
+			continue
+		}
+		grid.occupy(bestShape, bestX+dilate, bestY+dilate)
+		rect := Rect2D{Point2D: NewPoint(padding+bestX*cell, padding+bestY*cell), Size2D: size}
+		if bestRotated {
+			rect.Width, rect.Height = rect.Height, rect.Width
+		}
+		rects = append(rects, rect)
+		rotated[size.ID] = bestRotated
+	}
+	p.algo.relayout(rects, rotated, p.padding)
+	p.unpackedSize2Ds = append(p.unpackedSize2Ds[:0], failed...)
+	return len(p.unpackedSize2Ds) == 0
+}
+
+// firstFit 返回 shape 可以放下的最靠上、其次最靠左的位置，碰撞用扩展后的 probe 检测
+//
+// limited 为 true 时只搜索底边不超过 maxBottom 的位置，用于另一个方向已找到位置时提前结束。
+func (c *cellMask) firstFit(probe, shape *cellMask, gridW, gridH, maxBottom int, limited bool) (int, int, bool) {
+	lastY := gridH - shape.height
+	if limited {
+		lastY = min(lastY, maxBottom-shape.height)
+	}
+	for y := 0; y <= lastY; y++ {
+		for x := 0; x+shape.width <= gridW; x++ {
+			if !c.collides(probe, x, y) {
+				return x, y, true
+			}
+		}
+	}
+	return 0, 0, false
+}
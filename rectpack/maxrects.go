@@ -2,14 +2,26 @@ package rectpack
 
 import "math"
 
-type heuristicFunc func(pack *maxRects, width, height, id int) (Rect2D, int, int)
+// fitFunc 返回在剩余矩形中放置 width x height 的矩形时用于比较位置的评分，越小越好，
+// 调用前已确认剩余矩形足够大
+type fitFunc func(freeRect *Rect2D, width, height int) (int, int)
 
 type maxRects struct {
 	algorithmBase
-	findNode     heuristicFunc
+	fit          fitFunc
+	swapScores   bool // BestLongSideFit 按(长边,短边)选择位置，但按(短边,长边)报告评分
+	contact      bool // ContactPoint 的评分依赖已放置的矩形，不能缓存
 	newLastSize  int
 	newFreeRects []Rect2D
 	freeRects    []Rect2D
+
+	// 评分缓存，见 fitcache.go
+	freeSerials []int      // 与 freeRects 一一对应的编号
+	serialPos   []int      // 每个编号的剩余矩形在 freeRects 中的下标，已移除为 -1
+	firstNew    int        // 上次更新缓存后新增的剩余矩形编号从此开始
+	cache       []fitCache // 与 insertBest 的 sizes 一一对应
+
+	grid freeGrid // 剩余矩形较多时的网格索引，见 freegrid.go
 }
 
 func newMaxRects(width, height int, heuristic Heuristic) *maxRects {
@@ -17,17 +29,18 @@ func newMaxRects(width, height int, heuristic Heuristic) *maxRects {
 	p.idMapRotated = make(map[int]bool)
 	switch heuristic & fitMask {
 	case BestAreaFit:
-		p.findNode = findPositionBestAreaFit
+		p.fit = fitBestAreaFit
 	case BottomLeft:
-		p.findNode = findPositionBottomLeft
+		p.fit = fitBottomLeft
 	case ContactPoint:
-		p.findNode = findPositionContactPoint
+		p.contact = true
 	case BestLongSideFit:
-		p.findNode = findPositionBestLongSideFit
+		p.fit = fitBestLongSideFit
+		p.swapScores = true
 	case BestShortSideFit:
-		p.findNode = findPositionBestShortSideFit
+		p.fit = fitBestShortSideFit
 	default: // BestShortSideFit
-		p.findNode = findPositionBestShortSideFit
+		p.fit = fitBestShortSideFit
 	}
 
 	p.Reset(width, height)
@@ -39,248 +52,132 @@ func (p *maxRects) Reset(width, height int) {
 	p.newFreeRects = p.newFreeRects[:0]
 	p.freeRects = p.freeRects[:0]
 	p.freeRects = append(p.freeRects, NewRect(0, 0, p.maxWidth, p.maxHeight))
+	p.freeSerials = append(p.freeSerials[:0], 0)
+	p.serialPos = append(p.serialPos[:0], 0)
+	p.firstNew = 1
+	p.cache = p.cache[:0]
+	p.grid.reset()
 }
 
-func (p *maxRects) Insert(padding int, sizes ...Size2D) []Size2D {
-	for len(sizes) > 0 {
-
-		var bestNode Rect2D
-		bestScore1 := math.MaxInt
-		bestScore2 := math.MaxInt
-		bestRectIndex := -1
+func (p *maxRects) getFreeRects() []Rect2D {
+	return p.freeRects
+}
 
-		for i, size := range sizes {
+func (p *maxRects) Insert(padding int, sizes ...Size2D) []Size2D {
+	return p.insertClasses(padding, sizes, p.insertBest, false)
+}
 
+// insertBest 从 sizes 中选出评分最优的一个矩形并放置，返回其索引，无法放置时返回 -1
+func (p *maxRects) insertBest(padding int, sizes []Size2D) int {
+	var step *TraceStep
+	if p.tracer != nil {
+		step = p.traceBegin(p.freeRects)
+		step.Candidates = p.traceCandidates(padding, sizes)
+	}
+	if !p.contact {
+		p.updateCache(padding, sizes)
+	}
+	var bestNode Rect2D
+	var bestRotated bool
+	bestScore1 := math.MaxInt
+	bestScore2 := math.MaxInt
+	bestRectIndex := -1
+
+	for i, size := range sizes {
+		var newNode Rect2D
+		var score1, score2 int
+		var rotated bool
+		if p.contact {
 			padSize(&size, padding)
-			newNode, score1, score2 := p.scoreRect(size.Width, size.Height, size.ID)
-			if score1 < bestScore1 || (score1 == bestScore1 && score2 < bestScore2) {
-				bestScore1 = score1
-				bestScore2 = score2
-				bestNode = newNode
-				bestNode.ID = size.ID
-				bestRectIndex = i
-			}
+			newNode, score1, score2, rotated = p.findPositionContactPoint(size.Width, size.Height, p.allowRotate && !size.NoRotate)
+		} else {
+			newNode, score1, score2, rotated = p.cache[i].result(p)
 		}
-
-		if bestRectIndex == -1 {
-			break
+		if score1 < bestScore1 || (score1 == bestScore1 && score2 < bestScore2) {
+			bestScore1 = score1
+			bestScore2 = score2
+			bestNode = newNode
+			bestRotated = rotated
+			bestRectIndex = i
 		}
+	}
 
-		p.placeRect(bestNode)
-		unpadRect(&bestNode, padding)
-		p.packed = append(p.packed, bestNode)
-
-		last := len(sizes) - 1
-		sizes[bestRectIndex] = sizes[last]
-		sizes = sizes[:last]
+	if bestRectIndex == -1 {
+		if step != nil {
+			p.traceEnd(step, -1, Rect2D{}, false, p.freeRects)
+		}
+		return -1
+	}
+	// 放置结果保留原尺寸的ID、分组等信息，宽高取实际放置的(可能旋转的)尺寸
+	width, height := bestNode.Width, bestNode.Height
+	bestNode.Size2D = sizes[bestRectIndex]
+	bestNode.Width, bestNode.Height = width, height
+	p.idMapRotated[bestNode.ID] = bestRotated
+
+	p.placeRect(bestNode)
+	unpadRect(&bestNode, padding)
+	p.packed = append(p.packed, bestNode)
+	if step != nil {
+		p.traceEnd(step, bestRectIndex, bestNode, bestRotated, p.freeRects)
 	}
-	return sizes
+	return bestRectIndex
 }
 
-func (p *maxRects) scoreRect(width, height, id int) (Rect2D, int, int) {
-	newNode, score1, score2 := p.findNode(p, width, height, id)
-	if newNode.Height == 0 {
-		score1 = math.MaxInt
-		score2 = math.MaxInt
+// fitRect 计算在剩余矩形中放置 width x height 的矩形的评分，rotate 为 true 时也尝试旋转，
+// 两个方向评分相同时优先不旋转
+func (p *maxRects) fitRect(freeRect *Rect2D, width, height int, rotate bool) (score1, score2 int, rotated, ok bool) {
+	score1, score2 = math.MaxInt, math.MaxInt
+	if freeRect.Width >= width && freeRect.Height >= height {
+		score1, score2 = p.fit(freeRect, width, height)
+		ok = true
+	}
+	if rotate && freeRect.Width >= height && freeRect.Height >= width {
+		s1, s2 := p.fit(freeRect, height, width)
+		if s1 < score1 || (s1 == score1 && s2 < score2) {
+			score1, score2, rotated, ok = s1, s2, true, true
+		}
 	}
-	return newNode, score1, score2
+	return score1, score2, rotated, ok
 }
 
 func (p *maxRects) placeRect(node Rect2D) {
-	for i := 0; i < len(p.freeRects); {
-		if p.splitFreeNode(&p.freeRects[i], &node) {
-			last := len(p.freeRects) - 1
-			p.freeRects[i] = p.freeRects[last]
-			p.freeRects = p.freeRects[:last]
-		} else {
-			i++
+	if p.grid.enabled {
+		p.splitIndexed(&node)
+	} else {
+		for i := 0; i < len(p.freeRects); {
+			if p.splitFreeNode(&p.freeRects[i], &node) {
+				p.removeFreeRect(i)
+			} else {
+				i++
+			}
 		}
 	}
 	p.pruneFreeList()
 	p.usedArea += node.Area()
 }
 
-func findPositionBottomLeft(p *maxRects, width, height, id int) (Rect2D, int, int) {
-	var bestNode Rect2D
-
-	bestY := math.MaxInt
-	bestX := math.MaxInt
-
-	for _, freeRect := range p.freeRects {
-
-		// Try to place the rectangle in upright (non-flipped) orientation.
-		if freeRect.Width >= width && freeRect.Height >= height {
-			topSideY := freeRect.Y + height
-			if topSideY < bestY || (topSideY == bestY && freeRect.X < bestX) {
-				bestNode.X = freeRect.X
-				bestNode.Y = freeRect.Y
-				bestNode.Width = width
-				bestNode.Height = height
-				bestY = topSideY
-				bestX = freeRect.X
-				if p.idMapRotated[id]  {
-					p.idMapRotated[id] = false
-				}
-			}
-		}
-
-		if p.allowRotate && freeRect.Width >= height && freeRect.Height >= width {
-			topSideY := freeRect.Y + width
-			if topSideY < bestY || (topSideY == bestY && freeRect.X < bestX) {
-				bestNode.X = freeRect.X
-				bestNode.Y = freeRect.Y
-				bestNode.Width = height
-				bestNode.Height = width
-				bestY = topSideY
-				bestX = freeRect.X
-				if !p.idMapRotated[id]  {
-					p.idMapRotated[id] = true
-				}
-			}
-		}
-	}
-	return bestNode, bestY, bestX
+func fitBottomLeft(freeRect *Rect2D, width, height int) (int, int) {
+	topSideY := freeRect.Y + height
+	return topSideY, freeRect.X
 }
 
-func findPositionBestShortSideFit(p *maxRects, width, height, id int) (Rect2D, int, int) {
-	var bestNode Rect2D
-	bestShortSideFit := math.MaxInt
-	bestLongSideFit := math.MaxInt
-
-	for _, freeRect := range p.freeRects {
-
-		// Try to place the rectangle in upright (non-flipped) orientation.
-		if freeRect.Width >= width && freeRect.Height >= height {
-
-			leftoverHoriz := abs(freeRect.Width - width)
-			leftoverVert := abs(freeRect.Height - height)
-			shortSideFit := min(leftoverHoriz, leftoverVert)
-			longSideFit := max(leftoverHoriz, leftoverVert)
-
-			if shortSideFit < bestShortSideFit || (shortSideFit == bestShortSideFit && longSideFit < bestLongSideFit) {
-				bestNode.X = freeRect.X
-				bestNode.Y = freeRect.Y
-				bestNode.Width = width
-				bestNode.Height = height
-				bestShortSideFit = shortSideFit
-				bestLongSideFit = longSideFit
-				if p.idMapRotated[id]  {
-					p.idMapRotated[id] = false
-				}
-			}
-		}
-
-		if p.allowRotate && freeRect.Width >= height && freeRect.Height >= width {
-			flippedLeftoverHoriz := abs(freeRect.Width - height)
-			flippedLeftoverVert := abs(freeRect.Height - width)
-			flippedShortSideFit := min(flippedLeftoverHoriz, flippedLeftoverVert)
-			flippedLongSideFit := max(flippedLeftoverHoriz, flippedLeftoverVert)
-
-			if flippedShortSideFit < bestShortSideFit || (flippedShortSideFit == bestShortSideFit && flippedLongSideFit < bestLongSideFit) {
-				bestNode.X = freeRect.X
-				bestNode.Y = freeRect.Y
-				bestNode.Width = height
-				bestNode.Height = width
-				bestShortSideFit = flippedShortSideFit
-				bestLongSideFit = flippedLongSideFit
-				if !p.idMapRotated[id]  {
-					p.idMapRotated[id] = true
-				}
-			}
-		}
-	}
-
-	return bestNode, bestShortSideFit, bestLongSideFit
+func fitBestShortSideFit(freeRect *Rect2D, width, height int) (int, int) {
+	leftoverHoriz := freeRect.Width - width
+	leftoverVert := freeRect.Height - height
+	return min(leftoverHoriz, leftoverVert), max(leftoverHoriz, leftoverVert)
 }
 
-func findPositionBestLongSideFit(p *maxRects, width, height, id int) (Rect2D, int, int) {
-	var bestNode Rect2D
-	bestShortSideFit := math.MaxInt
-	bestLongSideFit := math.MaxInt
-
-	for _, freeRect := range p.freeRects {
-
-		// Try to place the rectangle in upright (non-flipped) orientation.
-		if freeRect.Width >= width && freeRect.Height >= height {
-
-			leftoverHoriz := abs(freeRect.Width - width)
-			leftoverVert := abs(freeRect.Height - height)
-			shortSideFit := min(leftoverHoriz, leftoverVert)
-			longSideFit := max(leftoverHoriz, leftoverVert)
-
-			if longSideFit < bestLongSideFit || (longSideFit == bestLongSideFit && shortSideFit < bestShortSideFit) {
-				bestNode.X = freeRect.X
-				bestNode.Y = freeRect.Y
-				bestNode.Width = width
-				bestNode.Height = height
-				if p.idMapRotated[id]  {
-					p.idMapRotated[id] = false
-				}
-				bestShortSideFit = shortSideFit
-				bestLongSideFit = longSideFit
-			}
-		}
-
-		if p.allowRotate && freeRect.Width >= height && freeRect.Height >= width {
-			leftoverHoriz := abs(freeRect.Width - height)
-			leftoverVert := abs(freeRect.Height - width)
-			shortSideFit := min(leftoverHoriz, leftoverVert)
-			longSideFit := max(leftoverHoriz, leftoverVert)
-
-			if longSideFit < bestLongSideFit || (longSideFit == bestLongSideFit && shortSideFit < bestShortSideFit) {
-				bestNode.X = freeRect.X
-				bestNode.Y = freeRect.Y
-				bestNode.Width = height
-				bestNode.Height = width
-				bestShortSideFit = shortSideFit
-				bestLongSideFit = longSideFit
-				if !p.idMapRotated[id]  {
-					p.idMapRotated[id] = true
-				}
-			}
-		}
-	}
-	return bestNode, bestShortSideFit, bestLongSideFit
+func fitBestLongSideFit(freeRect *Rect2D, width, height int) (int, int) {
+	leftoverHoriz := freeRect.Width - width
+	leftoverVert := freeRect.Height - height
+	return max(leftoverHoriz, leftoverVert), min(leftoverHoriz, leftoverVert)
 }
 
-func findPositionBestAreaFit(p *maxRects, width, height, id int) (Rect2D, int, int) {
-	var bestNode Rect2D
-	bestAreaFit := math.MaxInt
-	bestShortSideFit := math.MaxInt
-	for _, freeRect := range p.freeRects {
-		areaFit := freeRect.Width*freeRect.Height - width*height
-		// Try to place the rectangle in upright (non-flipped) orientation.
-		if freeRect.Width >= width && freeRect.Height >= height {
-			leftoverHoriz := abs(freeRect.Width - width)
-			leftoverVert := abs(freeRect.Height - height)
-			shortSideFit := min(leftoverHoriz, leftoverVert)
-			if areaFit < bestAreaFit || (areaFit == bestAreaFit && shortSideFit < bestShortSideFit) {
-				bestNode.X = freeRect.X
-				bestNode.Y = freeRect.Y
-				bestNode.Width = width
-				bestNode.Height = height
-				p.idMapRotated[id] = false
-				bestShortSideFit = shortSideFit
-				bestAreaFit = areaFit
-			}
-		}
-
-		if p.allowRotate && freeRect.Width >= height && freeRect.Height >= width {
-			leftoverHoriz := abs(freeRect.Width - height)
-			leftoverVert := abs(freeRect.Height - width)
-			shortSideFit := min(leftoverHoriz, leftoverVert)
-			if areaFit < bestAreaFit || (areaFit == bestAreaFit && shortSideFit < bestShortSideFit) {
-				bestNode.X = freeRect.X
-				bestNode.Y = freeRect.Y
-				bestNode.Width = height
-				bestNode.Height = width
-				p.idMapRotated[id] = true
-				bestShortSideFit = shortSideFit
-				bestAreaFit = areaFit
-			}
-		}
-	}
-	return bestNode, bestAreaFit, bestShortSideFit
+func fitBestAreaFit(freeRect *Rect2D, width, height int) (int, int) {
+	areaFit := freeRect.Width*freeRect.Height - width*height
+	leftoverHoriz := freeRect.Width - width
+	leftoverVert := freeRect.Height - height
+	return areaFit, min(leftoverHoriz, leftoverVert)
 }
 
 // Returns 0 if the two intervals i1 and i2 are disjoint, or the length of their overlap otherwise
@@ -313,8 +210,9 @@ func (p *maxRects) contactPointScoreNode(x, y, width, height int) int {
 	return score
 }
 
-func findPositionContactPoint(p *maxRects, width, height, id int) (Rect2D, int, int) {
+func (p *maxRects) findPositionContactPoint(width, height int, rotate bool) (Rect2D, int, int, bool) {
 	var bestNode Rect2D
+	var bestRotated bool
 	bestContactScore := -1
 
 	for _, freeRect := range p.freeRects {
@@ -322,31 +220,24 @@ func findPositionContactPoint(p *maxRects, width, height, id int) (Rect2D, int,
 		if freeRect.Width >= width && freeRect.Height >= height {
 			score := p.contactPointScoreNode(freeRect.X, freeRect.Y, width, height)
 			if score > bestContactScore {
-				bestNode.X = freeRect.X
-				bestNode.Y = freeRect.Y
-				bestNode.Width = width
-				bestNode.Height = height
-				if p.idMapRotated[id]  {
-					p.idMapRotated[id] = false
-				}
+				bestNode = NewRect(freeRect.X, freeRect.Y, width, height)
+				bestRotated = false
 				bestContactScore = score
 			}
 		}
-		if p.allowRotate && freeRect.Width >= height && freeRect.Height >= width {
+		if rotate && freeRect.Width >= height && freeRect.Height >= width {
 			score := p.contactPointScoreNode(freeRect.X, freeRect.Y, height, width)
 			if score > bestContactScore {
-				bestNode.X = freeRect.X
-				bestNode.Y = freeRect.Y
-				bestNode.Width = height
-				bestNode.Height = width
-				if !p.idMapRotated[id]  {
-					p.idMapRotated[id] = true
-				}
+				bestNode = NewRect(freeRect.X, freeRect.Y, height, width)
+				bestRotated = true
 				bestContactScore = score
 			}
 		}
 	}
-	return bestNode, bestContactScore, math.MaxInt
+	if bestContactScore < 0 {
+		return bestNode, math.MaxInt, math.MaxInt, false
+	}
+	return bestNode, bestContactScore, math.MaxInt, bestRotated
 }
 
 func (p *maxRects) insertNewFreeRectangle(newFreeRect Rect2D) {
@@ -422,7 +313,10 @@ func (p *maxRects) splitFreeNode(freeNode, usedNode *Rect2D) bool {
 
 func (p *maxRects) pruneFreeList() {
 	// Test all newly introduced free rectangles against old free rectangles.
-	for i := 0; i < len(p.freeRects); i++ {
+	if p.grid.enabled {
+		p.pruneIndexed()
+	}
+	for i := 0; i < len(p.freeRects) && !p.grid.enabled; i++ {
 		for j := 0; j < len(p.newFreeRects); {
 
 			if p.freeRects[i].ContainsRect(p.newFreeRects[j]) {
@@ -437,6 +331,17 @@ func (p *maxRects) pruneFreeList() {
 	}
 
 	// Merge new and old free rectangles to the group of old free rectangles.
+	for i := range p.newFreeRects {
+		serial := len(p.serialPos)
+		p.serialPos = append(p.serialPos, len(p.freeSerials))
+		p.freeSerials = append(p.freeSerials, serial)
+		if p.grid.enabled {
+			p.grid.add(serial, &p.newFreeRects[i])
+		}
+	}
 	p.freeRects = append(p.freeRects, p.newFreeRects...)
 	p.newFreeRects = p.newFreeRects[:0]
+	if !p.grid.enabled && len(p.freeRects) > freeGridThreshold {
+		p.buildGrid()
+	}
 }
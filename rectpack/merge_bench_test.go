@@ -0,0 +1,54 @@
+package rectpack_test
+
+import (
+	"fmt"
+	"os"
+	"testing"
+
+	"rectpack2d/rectpack"
+	"rectpack2d/rectpack/instances"
+)
+
+// readInstance 读取 data.txt 格式的实例，文件不存在时跳过
+func readInstance(tb testing.TB, path string) *instances.Instance {
+	file, err := os.Open(path)
+	if err != nil {
+		tb.Skipf("instance %s not available: %v", path, err)
+	}
+	defer file.Close()
+	inst, err := instances.ReadText(file)
+	if err != nil {
+		tb.Fatalf("%s: %v", path, err)
+	}
+	return inst
+}
+
+// BenchmarkGuillotineMerge 比较各合并策略在 data.txt 和同类随机实例上的填充率
+func BenchmarkGuillotineMerge(b *testing.B) {
+	data := readInstance(b, "../data.txt")
+	// 随机实例的尺寸在 [20, 120) 之间，总面积约为容器的1.2倍
+	corpus := []*instances.Instance{data}
+	for seed := uint64(1); seed <= 4; seed++ {
+		corpus = append(corpus, instances.Random(seed, data.Bin, rectpack.NewSize2D(20, 20), rectpack.NewSize2D(120, 120), 1.2))
+	}
+
+	for _, policy := range []rectpack.MergePolicy{rectpack.MergeNone, rectpack.MergeAdjacent, rectpack.MergeMaximal} {
+		for _, heuristic := range []rectpack.Heuristic{rectpack.GuillotineBAF, rectpack.GuillotineBSSF, rectpack.GuillotineBLSF} {
+			b.Run(fmt.Sprintf("%v/%v", heuristic, policy), func(b *testing.B) {
+				var rate float64
+				for i := 0; i < b.N; i++ {
+					rate = 0
+					for _, inst := range corpus {
+						packer, _ := rectpack.NewPacker(inst.Bin.Width, inst.Bin.Height, heuristic)
+						packer.AllowRotate(data.Rotate)
+						packer.SetMergePolicy(policy)
+						packer.Insert(inst.Items...)
+						packer.Pack()
+						rate += packer.GetAreaUsedRate(false)
+					}
+				}
+				b.ReportMetric(rate/float64(len(corpus))*100, "fill%")
+			})
+		}
+	}
+}
@@ -1,7 +1,9 @@
 package rectpack
 
 import (
+	"errors"
 	"fmt"
+	"maps"
 	"math"
 	"slices"
 )
@@ -11,10 +13,22 @@ const DefaultSize = 4096
 type Packer struct {
 	unpackedSize2Ds []Size2D
 	algo            packAlgorithm
+	heuristic       Heuristic
 	sortFunc        SortFunc
 	padding         int
 	sortRev         bool
+	allowRotate     bool
 	Online          bool
+	// Hierarchical 为 true 时 Pack 使用分层打包：
+	// 同一 Group 的矩形先被打包到各自的区块中，再把区块作为整体打包到页面上
+	Hierarchical bool
+	// Lookahead 大于0时启用半在线模式：最多暂存 Lookahead 个矩形，
+	// 缓冲区满时放置其中评分最优的一个，剩余矩形需调用 Flush 放置
+	Lookahead int
+	pending   []Size2D
+	failed    []Size2D // 前瞻模式下返回的无法包装的尺寸，下次插入时复用
+	scratch   []Size2D // 传给算法的尺寸缓冲区，在线插入和搜索尺寸时的每次尝试都复用它
+	tracer    Tracer   // 记录打包过程，见 SetTracer
 }
 
 func (p *Packer) MaxSize() Size2D {
@@ -35,13 +49,22 @@ func (p *Packer) MinSize() Size2D {
 	return size
 }
 
-// Insert 向包装器中插入多个尺寸
-// 在线模式下会立即尝试包装，离线模式下只是暂存尺寸
+// Insert 向包装器中插入多个尺寸，Quantity 大于1的尺寸会展开为多个实例
+// 在线模式下会立即尝试包装，前瞻模式下放入缓冲区，离线模式下只是暂存尺寸
+// 返回:
+//
+//	在线和前瞻模式下为无法包装的尺寸，离线模式下为所有暂存的尺寸(由内部管理，下次插入前有效)
 func (p *Packer) Insert(sizes ...Size2D) []Size2D {
+	sizes = expandQuantities(sizes)
+	if p.Lookahead > 0 {
+		return p.insertLookahead(sizes)
+	}
 	// 如果启用了在线打包（Online 模式）
 	if p.Online {
 		// 调用具体算法的 Insert 方法，传入 Padding 和尺寸列表，返回插入结果
-		return p.algo.Insert(p.padding, sizes...)
+		// 先复制到内部缓冲区，使调用方的可变参数切片不必分配在堆上
+		p.scratch = append(p.scratch[:0], sizes...)
+		return p.algo.Insert(p.padding, p.scratch...)
 	}
 	// 否则，将尺寸追加到未打包的列表中（用于离线打包）
 	p.unpackedSize2Ds = append(p.unpackedSize2Ds, sizes...)
@@ -53,12 +76,53 @@ func (p *Packer) Insert(sizes ...Size2D) []Size2D {
 // 返回是否插入/包装成功
 func (p *Packer) InsertNewSize2D(id, width, height int) bool {
 	result := p.Insert(NewSize2DByID(id, width, height))
-	if p.Online && len(result) != 0 {
+	if (p.Online || p.Lookahead > 0) && len(result) != 0 {
 		return false
 	}
 	return true
 }
 
+// insertLookahead 将尺寸依次放入前瞻缓冲区，缓冲区满时放置其中评分最优的矩形
+// 返回无法包装的尺寸
+func (p *Packer) insertLookahead(sizes []Size2D) []Size2D {
+	p.failed = p.failed[:0]
+	for _, size := range sizes {
+		p.pending = append(p.pending, size)
+		if len(p.pending) >= p.Lookahead {
+			p.placePending()
+		}
+	}
+	return p.failed
+}
+
+// placePending 放置缓冲区中评分最优的一个矩形
+// 缓冲区中没有任何矩形能放下时，最早进入缓冲区的矩形追加到 p.failed，其余矩形留在缓冲区中
+func (p *Packer) placePending() {
+	i := p.algo.insertBest(p.padding, p.pending)
+	if i == -1 {
+		p.failed = append(p.failed, p.pending[0])
+		i = 0
+	}
+	p.pending = slices.Delete(p.pending, i, i+1)
+}
+
+// Flush 放置前瞻缓冲区中剩余的所有矩形
+// 返回:
+//
+//	无法包装的尺寸(由内部管理，下次插入前有效)
+func (p *Packer) Flush() []Size2D {
+	p.failed = p.failed[:0]
+	for len(p.pending) > 0 {
+		p.placePending()
+	}
+	return p.failed
+}
+
+// GetPendingRects 获取前瞻缓冲区中尚未放置的矩形尺寸
+func (p *Packer) GetPendingRects() []Size2D {
+	return p.pending
+}
+
 // SetSorter 设置用于packing的排序函数和排序顺序
 // 参数:
 //
@@ -74,6 +138,11 @@ func (p *Packer) SetPadding(padding int) {
 	p.padding = padding
 }
 
+// Padding 返回矩形之间的间距
+func (p *Packer) Padding() int {
+	return p.padding
+}
+
 // GetPackedRects 获取所有已成功包装的矩形
 // 返回:
 //
@@ -112,6 +181,7 @@ func (p *Packer) Reset() {
 	size := p.algo.MaxSize()
 	p.algo.Reset(size.Width, size.Height)
 	p.unpackedSize2Ds = p.unpackedSize2Ds[:0]
+	p.pending = p.pending[:0]
 }
 
 // ResetMaxSize 重置包最大尺寸
@@ -130,6 +200,30 @@ func (p *Packer) ResetMaxSize(maxWidth, maxHeight int) bool {
 	}
 	p.algo.Reset(maxWidth, maxHeight)
 	p.unpackedSize2Ds = p.unpackedSize2Ds[:0]
+	p.pending = p.pending[:0]
+	return true
+}
+
+// Remove 移除已放置的矩形并释放它占用的空间，之后插入的矩形可以使用这块空间
+//
+// 剩余空间按其余矩形重建，Guillotine 的切割树不再有效。
+// 分层打包和限制了切割阶段的 Guillotine 需要保持布局结构，不支持移除。
+// 返回:
+//
+//	true: 移除成功 false: 没有该ID的矩形或不支持移除
+func (p *Packer) Remove(id int) bool {
+	if !p.relayoutable() {
+		return false
+	}
+	rects := p.algo.GetPackedRects()
+	i := slices.IndexFunc(rects, func(rect Rect2D) bool { return rect.ID == id })
+	if i < 0 {
+		return false
+	}
+	rects = slices.Delete(slices.Clone(rects), i, i+1)
+	rotated := maps.Clone(p.algo.GetIdMapRotated())
+	delete(rotated, id)
+	p.algo.relayout(rects, rotated, p.padding)
 	return true
 }
 
@@ -141,25 +235,34 @@ func (p *Packer) Pack() bool {
 	if len(p.unpackedSize2Ds) == 0 {
 		return true
 	}
+	if p.Hierarchical {
+		return p.packHierarchical()
+	}
+	return p.packSorted()
+}
+
+// sortSizes 按包装器的排序函数和排序顺序原地排列尺寸
+func (p *Packer) sortSizes(sizes []Size2D) {
 	if p.sortFunc != nil {
 		if p.sortRev {
-			slices.SortFunc(p.unpackedSize2Ds, func(a, b Size2D) int {
+			slices.SortFunc(sizes, func(a, b Size2D) int {
 				return p.sortFunc(b, a)
 			})
 		} else {
-			slices.SortFunc(p.unpackedSize2Ds, p.sortFunc)
+			slices.SortFunc(sizes, p.sortFunc)
 		}
 	} else if p.sortRev {
-		slices.Reverse(p.unpackedSize2Ds)
+		slices.Reverse(sizes)
 	}
-	failedPackedSize2Ds := p.algo.Insert(p.padding, p.unpackedSize2Ds...)
+}
 
-	if len(failedPackedSize2Ds) == 0 {
-		p.unpackedSize2Ds = p.unpackedSize2Ds[:0]
-		return true
-	}
-	p.unpackedSize2Ds = failedPackedSize2Ds
-	return false
+// packSorted 按排序规则排列暂存的矩形并一次性打包
+func (p *Packer) packSorted() bool {
+	p.sortSizes(p.unpackedSize2Ds)
+	failedPackedSize2Ds := p.algo.Insert(p.padding, p.unpackedSize2Ds...)
+	// 失败的尺寸由算法内部管理，复制到暂存列表中
+	p.unpackedSize2Ds = append(p.unpackedSize2Ds[:0], failedPackedSize2Ds...)
+	return len(p.unpackedSize2Ds) == 0
 }
 
 // Shrink 自动布局收缩
@@ -210,10 +313,12 @@ func (p *Packer) Shrink() bool {
 	initialSize = max(initialSize, maxWidth)
 	initialSize = max(initialSize, maxHeight)
 
-	sizes := make([]Size2D, 0, len(rects))
+	// 每次尝试都从同一份尺寸重新插入，复用缓冲区避免分配
+	sizes := p.scratch[:0]
 	for _, rect := range rects {
 		sizes = append(sizes, rect.Size2D)
 	}
+	p.scratch = sizes
 
 	// 设置搜索范围
 	minSize := initialSize
@@ -221,7 +326,7 @@ func (p *Packer) Shrink() bool {
 
 	// 先测试最小尺寸是否可行
 	p.algo.Reset(minSize, minSize)
-	failed := p.algo.Insert(p.padding, slices.Clone(sizes)...)
+	failed := p.algo.Insert(p.padding, sizes...)
 	successful := len(failed) == 0
 
 	// 如果最小尺寸就能打包成功，直接返回
@@ -234,7 +339,7 @@ func (p *Packer) Shrink() bool {
 	for !successful && maxSize < 10000 { // 设置一个合理的上限
 		maxSize *= 2
 		p.algo.Reset(maxSize, maxSize)
-		failed = p.algo.Insert(p.padding, slices.Clone(sizes)...)
+		failed = p.algo.Insert(p.padding, sizes...)
 		successful = len(failed) == 0
 	}
 	if !successful {
@@ -248,7 +353,7 @@ func (p *Packer) Shrink() bool {
 	for minSize < maxSize-1 {
 		midSize := (minSize + maxSize) / 2
 		p.algo.Reset(midSize, midSize)
-		failed = p.algo.Insert(p.padding, slices.Clone(sizes)...)
+		failed = p.algo.Insert(p.padding, sizes...)
 		successful = len(failed) == 0
 		if successful {
 			maxSize = midSize
@@ -265,7 +370,7 @@ func (p *Packer) Shrink() bool {
 	// 尝试减小高度
 	for h := bestSize - 1; h >= bestSize/2; h-- {
 		p.algo.Reset(optimalWidth, h)
-		failed = p.algo.Insert(p.padding, slices.Clone(sizes)...)
+		failed = p.algo.Insert(p.padding, sizes...)
 		successful = len(failed) == 0
 
 		if successful {
@@ -278,7 +383,7 @@ func (p *Packer) Shrink() bool {
 	// 尝试减小宽度
 	for w := bestSize - 1; w >= bestSize/2; w-- {
 		p.algo.Reset(w, optimalHeight)
-		failed = p.algo.Insert(p.padding, slices.Clone(sizes)...)
+		failed = p.algo.Insert(p.padding, sizes...)
 		successful = len(failed) == 0
 		if successful {
 			optimalWidth = w
@@ -289,7 +394,7 @@ func (p *Packer) Shrink() bool {
 
 	// 最后一次尝试使用最佳尺寸
 	p.algo.Reset(optimalWidth, optimalHeight)
-	failed = p.algo.Insert(p.padding, slices.Clone(sizes)...)
+	failed = p.algo.Insert(p.padding, sizes...)
 	successful = len(failed) == 0
 
 	if successful {
@@ -299,7 +404,7 @@ func (p *Packer) Shrink() bool {
 		// 恢复原始尺寸
 		fmt.Println("优化空间失败")
 		p.algo.Reset(origSize.Width, origSize.Height)
-		p.algo.Insert(p.padding, slices.Clone(sizes)...)
+		p.algo.Insert(p.padding, sizes...)
 		return false
 	}
 }
@@ -311,9 +416,65 @@ func (p *Packer) Shrink() bool {
 //
 // 默认值: false
 func (p *Packer) AllowRotate(enabled bool) {
+	p.allowRotate = enabled
 	p.algo.AllowRotate(enabled)
 }
 
+// SetMergePolicy 设置 Guillotine 算法合并剩余矩形的策略，其他算法忽略此设置
+//
+// 默认值: MergeNone，不合并剩余矩形以保持切割树有效。合并可以提高填充率，但之后 CutTree 返回 nil。
+func (p *Packer) SetMergePolicy(policy MergePolicy) {
+	if algo, ok := p.algo.(*guillotinePack); ok {
+		algo.mergePolicy = policy
+	}
+}
+
+// SetGuillotineStages 限制 Guillotine 算法的切割阶段数，使布局可以由 N 阶段的裁板锯执行
+// 参数:
+//
+//	stages - 切割方向最多交替的次数，例如2表示先整版切成条再切成块，0 表示不限制
+//
+// 限制阶段数时不会合并剩余矩形，其他算法忽略此设置。
+// 返回:
+//
+//	error: 包装器中已有矩形，或者切割树已经无效(合并过剩余矩形、局部搜索重排过布局)，需要先 Reset
+func (p *Packer) SetGuillotineStages(stages int) error {
+	algo, ok := p.algo.(*guillotinePack)
+	if !ok {
+		return nil
+	}
+	if len(algo.packed) > 0 || algo.cutBroken {
+		return errors.New("guillotine stages must be set before any rects are placed")
+	}
+	algo.stages = max(stages, 0)
+	return nil
+}
+
+// CutTree 返回 Guillotine 算法记录的切割树
+// 返回:
+//
+//	切割树的根节点(由内部管理，如需修改请复制)；不是 Guillotine 算法，
+//	或者合并过剩余矩形(合并策略不是 MergeNone 且未限制阶段数)时返回 nil
+func (p *Packer) CutTree() *CutNode {
+	if algo, ok := p.algo.(*guillotinePack); ok {
+		return algo.CutTree()
+	}
+	return nil
+}
+
+// newSubPacker 创建一个与当前包装器配置相同（算法、旋转、间距、排序）的新包装器
+func (p *Packer) newSubPacker(width, height int) *Packer {
+	sub, _ := NewPacker(width, height, p.heuristic)
+	sub.AllowRotate(p.allowRotate)
+	sub.SetPadding(p.padding)
+	sub.SetSorter(p.sortFunc, p.sortRev)
+	if algo, ok := p.algo.(*guillotinePack); ok {
+		sub.SetMergePolicy(algo.mergePolicy)
+		sub.SetGuillotineStages(algo.stages)
+	}
+	return sub
+}
+
 // NewPacker 创建并初始化一个新的矩形包装器
 // 参数:
 //
@@ -334,21 +495,29 @@ func NewPacker(maxWidth, maxHeight int, heuristic Heuristic) (*Packer, error) {
 		return nil, fmt.Errorf("width and height must be greater than 0 (given %vx%x)", maxWidth, maxHeight)
 	}
 	p := &Packer{
-		sortFunc: SortArea,
+		heuristic: heuristic,
+		sortFunc:  SortArea,
+		algo:      newPackAlgorithm(maxWidth, maxHeight, heuristic),
+	}
+	if _, ok := p.algo.(*algorithmBase); ok {
+		p.sortFunc = nil
 	}
+	return p, nil
+}
+
+// newPackAlgorithm 创建 heuristic 对应的包装算法
+func newPackAlgorithm(maxWidth, maxHeight int, heuristic Heuristic) packAlgorithm {
 	switch heuristic & typeMask {
 	case MaxRects:
-		p.algo = newMaxRects(maxWidth, maxHeight, heuristic)
+		return newMaxRects(maxWidth, maxHeight, heuristic)
 	case Guillotine:
-		p.algo = newGuillotine(maxWidth, maxHeight, heuristic)
+		return newGuillotine(maxWidth, maxHeight, heuristic)
 	default:
 		var a algorithmBase
 		a.maxHeight = maxHeight
 		a.maxWidth = maxWidth
-		p.sortFunc = nil
-		p.algo = &a
+		return &a
 	}
-	return p, nil
 }
 
 // NewDefaultPacker 创建使用默认配置的包装器
@@ -1,16 +1,20 @@
 package rectpack
 
 import (
+	"context"
+	"encoding/json"
 	"fmt"
 	"image"
 	"image/color"
 	"image/draw"
 	"image/png"
 	"log"
+	"maps"
 	"math/rand"
 	"os"
 	"path/filepath"
 	"slices"
+	"sync"
 	"testing"
 	"time"
 )
@@ -262,3 +266,796 @@ func Test_alog(t *testing.T) {
 		}
 	}
 }
+
+func TestLookahead(t *testing.T) {
+	const count = 256
+	minSize := NewSize2D(16, 16)
+	maxSize := NewSize2D(64, 64)
+
+	// Heuristic(99) 为按顺序放置的基础算法，每次放置都要从上次的位置继续
+	for _, heuristic := range []Heuristic{MaxRectsBSSF, GuillotineBAF, Heuristic(99)} {
+		packer, _ := NewPacker(512, 512, heuristic)
+		packer.AllowRotate(true)
+		packer.SetPadding(1)
+		packer.Lookahead = 8
+
+		var items, failed []Size2D
+		for i := 0; i < count; i++ {
+			size := randomSize(i, minSize, maxSize)
+			items = append(items, size)
+			failed = append(failed, packer.Insert(size)...)
+			if len(packer.GetPendingRects()) >= packer.Lookahead {
+				t.Fatalf("buffer holds %d items, lookahead is %d", len(packer.GetPendingRects()), packer.Lookahead)
+			}
+		}
+		failed = append(failed, packer.Flush()...)
+
+		rects := packer.GetPackedRects()
+		if len(rects) == 0 || len(rects)+len(failed) != count {
+			t.Errorf("%v: %d packed + %d failed, want %d", heuristic, len(rects), len(failed), count)
+		}
+		layout := Layout{Items: items, Rects: rects, Unpacked: failed, Rotated: packer.GetIdMapRotated()}
+		for _, v := range Validate(layout, NewSize2D(512, 512), 1) {
+			t.Errorf("%v: %s", heuristic, v.String())
+		}
+	}
+
+	// 局部搜索重排基础算法的布局后，继续插入的矩形不能与之前的重叠
+	packer, _ := NewPacker(256, 256, Heuristic(99))
+	var items []Size2D
+	for i := 0; i < 20; i++ {
+		items = append(items, randomSize(i, minSize, maxSize))
+	}
+	packer.Insert(items[:10]...)
+	packer.Pack()
+	if _, err := packer.Improve(context.Background(), 20); err != nil {
+		t.Fatal(err)
+	}
+	packer.Lookahead = 4
+	failed := packer.Insert(items[10:]...)
+	failed = append(failed, packer.Flush()...)
+	layout := Layout{Items: items, Rects: packer.GetPackedRects(), Unpacked: append(failed, packer.GetUnpackedRects()...)}
+	for _, v := range Validate(layout, NewSize2D(256, 256), 0) {
+		t.Errorf("after Improve: %s", v.String())
+	}
+
+	// 缓冲区中放不下的矩形不能连累其余能放下的矩形
+	packer, _ = NewPacker(100, 100, Heuristic(99))
+	packer.Lookahead = 3
+	failed = packer.Insert(NewSize2DByID(0, 200, 10), NewSize2DByID(1, 10, 10), NewSize2DByID(2, 10, 10), NewSize2DByID(3, 10, 10))
+	failed = append(slices.Clone(failed), packer.Flush()...)
+	if len(failed) != 1 || failed[0].ID != 0 || len(packer.GetPackedRects()) != 3 {
+		t.Errorf("sequential lookahead failed %v and packed %d rects, want only id 0 failed", failed, len(packer.GetPackedRects()))
+	}
+}
+
+func TestHierarchical(t *testing.T) {
+	minSize := NewSize2D(8, 8)
+	maxSize := NewSize2D(48, 48)
+
+	packer, _ := NewPacker(1024, 1024, MaxRectsBSSF)
+	packer.AllowRotate(true)
+	packer.SetPadding(1)
+	packer.Hierarchical = true
+	for i := 0; i < 120; i++ {
+		size := randomSize(i, minSize, maxSize)
+		size.Group = i % 4 // 组0为未分组的矩形
+		packer.Insert(size)
+	}
+	if !packer.Pack() {
+		t.Fatalf("%d items left unpacked", len(packer.GetUnpackedRects()))
+	}
+
+	rects := packer.GetPackedRects()
+	if len(rects) != 120 {
+		t.Fatalf("got %d packed rects, want 120", len(rects))
+	}
+	bounds := make(map[int]Rect2D)
+	for _, rect := range rects {
+		if rect.Group == 0 {
+			continue
+		}
+		if b, ok := bounds[rect.Group]; ok {
+			bounds[rect.Group] = b.Union(rect)
+		} else {
+			bounds[rect.Group] = NewRect(rect.X, rect.Y, rect.Width, rect.Height)
+		}
+	}
+	if len(bounds) != 3 {
+		t.Fatalf("got %d groups, want 3", len(bounds))
+	}
+	// 每个分组的包围盒内不应出现其他分组的矩形
+	for group, b := range bounds {
+		for _, rect := range rects {
+			if rect.Group != group && b.Intersects(rect) {
+				t.Errorf("group %d block %s overlaps %s of group %d", group, b.String(), rect.String(), rect.Group)
+			}
+		}
+	}
+}
+
+func TestElastic(t *testing.T) {
+	newSizes := func() []Size2D {
+		sizes := make([]Size2D, 0, 40)
+		for i := 0; i < 40; i++ {
+			size := NewSize2DByID(i, 24, 24)
+			switch i % 3 {
+			case 0:
+				size.Elastic = &Elastic{MinWidth: 16, MaxWidth: 128, MinHeight: 16, MaxHeight: 96}
+			case 1:
+				size.Elastic = &Elastic{Scales: []float64{0.5, 1, 2}}
+			}
+			sizes = append(sizes, size)
+		}
+		return sizes
+	}
+
+	rates := make(map[ElasticGoal]float64)
+	for _, goal := range []ElasticGoal{ElasticFit, ElasticFill} {
+		packer, _ := NewPacker(320, 320, MaxRectsBSSF)
+		packer.SetPadding(1)
+		packer.Insert(newSizes()...)
+		if !packer.PackElastic(goal) {
+			t.Fatalf("goal %d: %d items left unpacked", goal, len(packer.GetUnpackedRects()))
+		}
+		rects := packer.GetPackedRects()
+		if len(rects) != 40 {
+			t.Fatalf("goal %d: got %d packed rects, want 40", goal, len(rects))
+		}
+		for i := 0; i < len(rects)-1; i++ {
+			for j := i + 1; j < len(rects); j++ {
+				if rects[i].Intersects(rects[j]) {
+					t.Errorf("goal %d: %s and %s intersect", goal, rects[i].String(), rects[j].String())
+				}
+			}
+		}
+		for id, size := range packer.GetElasticSizes() {
+			if e := size.Elastic; len(e.Scales) == 0 &&
+				(size.Width < e.MinWidth || size.Width > e.MaxWidth || size.Height < e.MinHeight || size.Height > e.MaxHeight) {
+				t.Errorf("goal %d: item %d chose %s outside its range", goal, id, size.ToString())
+			}
+		}
+		rates[goal] = packer.GetAreaUsedRate(false)
+	}
+	if rates[ElasticFill] < rates[ElasticFit] {
+		t.Errorf("ElasticFill rate %.3f is below ElasticFit rate %.3f", rates[ElasticFill], rates[ElasticFit])
+	}
+
+	// 已放置的矩形保持原位，可伸缩矩形放在它们周围
+	for _, heuristic := range []Heuristic{MaxRectsBSSF, GuillotineBAF} {
+		packer, _ := NewPacker(320, 320, heuristic)
+		packer.SetPadding(1)
+		packer.Insert(NewSize2DByID(100, 200, 100), NewSize2DByID(101, 60, 150))
+		packer.Pack()
+		fixed := slices.Clone(packer.GetPackedRects())
+		packer.Insert(newSizes()...)
+		if !packer.PackElastic(ElasticFill) {
+			t.Fatalf("%v: %d items left unpacked", heuristic, len(packer.GetUnpackedRects()))
+		}
+		rects := packer.GetPackedRects()
+		if len(rects) != 42 {
+			t.Fatalf("%v: got %d packed rects, want 42", heuristic, len(rects))
+		}
+		for _, rect := range fixed {
+			if !slices.Contains(rects, rect) {
+				t.Errorf("%v: placed rect %s was moved or dropped", heuristic, rect.String())
+			}
+		}
+		for i := 0; i < len(rects)-1; i++ {
+			for j := i + 1; j < len(rects); j++ {
+				if rects[i].Intersects(rects[j]) {
+					t.Errorf("%v: %s and %s intersect", heuristic, rects[i].String(), rects[j].String())
+				}
+			}
+		}
+	}
+}
+
+func TestDiagnose(t *testing.T) {
+	packer, _ := NewPacker(100, 50, MaxRectsBSSF)
+	packer.SetPadding(2)
+	packer.Insert(
+		NewSize2DByID(0, 120, 120), // 旋转后也放不下
+		NewSize2DByID(1, 40, 90),   // 旋转后才能放下
+		NewSize2DByID(2, 100, 10),  // 加上间距后放不下
+		NewSize2DByID(3, 40, 45),
+		NewSize2DByID(4, 40, 45),
+	)
+	packer.Pack()
+	// 剩余空间为右侧 16x50 和底部 84x3，总面积足够但放不下 22x22
+	packer.Insert(NewSize2DByID(5, 20, 20))
+	packer.Pack()
+
+	want := map[int]FailReason{
+		0: ReasonTooLarge,
+		1: ReasonRotationDisabled,
+		2: ReasonPadding,
+		5: ReasonFragmentation,
+	}
+	diagnoses := packer.Diagnose()
+	if len(diagnoses) != len(want) {
+		t.Fatalf("got %d diagnoses, want %d", len(diagnoses), len(want))
+	}
+	for _, d := range diagnoses {
+		if d.Reason != want[d.Size.ID] {
+			t.Errorf("item %d: got reason %v, want %v", d.Size.ID, d.Reason, want[d.Size.ID])
+		}
+	}
+
+	// 按顺序放置时第二行放不下，但剩余面积足够，不是碎片化
+	sequential, _ := NewPacker(100, 50, Heuristic(99))
+	sequential.Insert(NewSize2DByID(0, 60, 30), NewSize2DByID(1, 60, 30))
+	sequential.Pack()
+	if diagnoses := sequential.Diagnose(); len(diagnoses) != 1 || diagnoses[0].Reason != ReasonPlacement {
+		t.Errorf("sequential diagnoses = %+v, want one ReasonPlacement", diagnoses)
+	}
+	// 暂存但还没有打包的矩形能放入剩余矩形
+	packer.Insert(NewSize2DByID(6, 10, 10))
+	if d := packer.Diagnose(); d[len(d)-1].Reason != ReasonPlacement {
+		t.Errorf("item 6: got reason %v, want %v", d[len(d)-1].Reason, ReasonPlacement)
+	}
+}
+
+func TestFreeSpace(t *testing.T) {
+	packer, _ := NewPacker(100, 100, MaxRectsBSSF)
+	packer.Online = true
+	if f := packer.Fragmentation(); f != 0 {
+		t.Errorf("empty bin fragmentation = %v, want 0", f)
+	}
+	packer.Insert(NewSize2DByID(0, 60, 60))
+
+	if area := packer.FreeArea(); area != 100*100-60*60 {
+		t.Errorf("FreeArea() = %d, want %d", area, 100*100-60*60)
+	}
+	if largest := packer.LargestFreeRect(); largest.Area() != 100*40 {
+		t.Errorf("LargestFreeRect() = %s, want area %d", largest.String(), 100*40)
+	}
+	if !packer.CanFit(NewSize2D(40, 100)) || packer.CanFit(NewSize2D(41, 41)) {
+		t.Error("CanFit disagrees with the remaining L-shaped space")
+	}
+	if f := packer.Fragmentation(); f <= 0 || f >= 1 {
+		t.Errorf("Fragmentation() = %v, want between 0 and 1", f)
+	}
+	if len(packer.FreeRects()) != 2 {
+		t.Errorf("got %d free rects, want 2", len(packer.FreeRects()))
+	}
+}
+
+func TestPackCatalog(t *testing.T) {
+	catalog, err := ParseBinCatalog("2048x2048, 1024x1024, 512x512::1")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(catalog) != 3 || catalog[1].Cost != 1024*1024 || catalog[2].Limit != 1 {
+		t.Fatalf("unexpected catalog %+v", catalog)
+	}
+	if _, err := ParseBinCatalog("1024"); err == nil {
+		t.Error("expected an error for a bin without height")
+	}
+
+	packer, _ := NewPacker(2048, 2048, MaxRectsBSSF)
+	for i := range 20 {
+		packer.Insert(NewSize2DByID(i, 256, 256))
+	}
+	pages, ok := packer.PackCatalog(catalog)
+	if !ok {
+		t.Fatalf("%d sizes left unpacked", len(packer.GetUnpackedRects()))
+	}
+	var got []int
+	for _, page := range pages {
+		got = append(got, page.Bin.Width)
+	}
+	if !slices.Equal(got, []int{1024, 512}) {
+		t.Errorf("opened pages %v, want [1024 512]", got)
+	}
+
+	// 小容器放不下的大矩形只能放进大容器，先选便宜的小容器会多占一页
+	catalog, _ = ParseBinCatalog("2048x2048,512x512")
+	packer.Reset()
+	packer.Insert(NewSize2DByID(0, 1000, 1000))
+	for i := 1; i < 20; i++ {
+		packer.Insert(NewSize2DByID(i, 50, 50))
+	}
+	pages, ok = packer.PackCatalog(catalog)
+	got = got[:0]
+	for _, page := range pages {
+		got = append(got, page.Bin.Width)
+	}
+	if !ok || !slices.Equal(got, []int{2048}) {
+		t.Errorf("opened pages %v, want a single 2048 page", got)
+	}
+
+	// 最便宜的容器只有一个，剩下的一页只能用贵的容器，不如直接用一个能放下全部矩形的宽容器
+	catalog = []BinType{{Width: 100, Height: 100, Cost: 1, Limit: 1}, {Width: 100, Height: 100, Cost: 20}, {Width: 200, Height: 100, Cost: 12}}
+	packer.Reset()
+	packer.Insert(NewSize2DByID(0, 100, 100), NewSize2DByID(1, 100, 100))
+	pages, ok = packer.PackCatalog(catalog)
+	var cost float64
+	for _, page := range pages {
+		cost += page.Bin.Cost
+	}
+	if !ok || len(pages) != 1 || cost != 12 {
+		t.Errorf("opened %d pages costing %v, want one page costing 12", len(pages), cost)
+	}
+}
+
+func TestQuantity(t *testing.T) {
+	items := []Size2D{{ID: 0, Width: 30, Height: 20, Quantity: 50}, NewSize2DByID(50, 64, 64), {ID: 100, Width: 10, Height: 10, Quantity: 3}}
+	for _, heuristic := range []Heuristic{MaxRectsBSSF, GuillotineBAF} {
+		packer, _ := NewPacker(256, 256, heuristic)
+		packer.AllowRotate(true)
+		packer.Insert(items...)
+		if !packer.Pack() {
+			t.Fatalf("%v: %d sizes left unpacked", heuristic, len(packer.GetUnpackedRects()))
+		}
+		if n := len(packer.GetPackedRects()); n != 54 {
+			t.Errorf("%v: packed %d rects, want 54", heuristic, n)
+		}
+		for _, v := range Validate(packer.Layout(items), NewSize2D(256, 256), 0) {
+			t.Errorf("%v: %s", heuristic, v.String())
+		}
+	}
+}
+
+func TestPackerState(t *testing.T) {
+	sizes := make([]Size2D, 300)
+	for i := range sizes {
+		sizes[i] = randomSize(i, NewSize2D(8, 8), NewSize2D(48, 48))
+	}
+	configs := []func(p *Packer){
+		func(p *Packer) {},
+		func(p *Packer) { p.SetMergePolicy(MergeAdjacent) },
+		func(p *Packer) { p.SetMergePolicy(MergeMaximal) },
+		func(p *Packer) { p.SetGuillotineStages(2) },
+		func(p *Packer) { p.Lookahead = 6 },
+	}
+	// Heuristic(99) 为按顺序放置的基础算法，恢复后应从中断时的行内位置继续
+	for _, heuristic := range []Heuristic{MaxRectsBSSF, MaxRectsCP, GuillotineBAF, GuillotineBSSF | SplitShorterAxis, Heuristic(99)} {
+		for i, config := range configs {
+			newPacker := func() *Packer {
+				p, _ := NewPacker(512, 512, heuristic)
+				p.AllowRotate(true)
+				p.SetPadding(1)
+				p.SetSorter(SortMaxSide, false)
+				p.Online = true
+				config(p)
+				return p
+			}
+			whole := newPacker()
+			resumed := newPacker()
+			for _, size := range sizes[:150] {
+				whole.Insert(size)
+				resumed.Insert(size)
+			}
+
+			for _, codec := range []string{"json", "binary"} {
+				var data []byte
+				var err error
+				restored := &Packer{}
+				if codec == "json" {
+					data, err = json.Marshal(resumed)
+					if err == nil {
+						err = json.Unmarshal(data, restored)
+					}
+				} else {
+					data, err = resumed.MarshalBinary()
+					if err == nil {
+						err = restored.UnmarshalBinary(data)
+					}
+				}
+				if err != nil {
+					t.Fatalf("%v config %d %s: %v", heuristic, i, codec, err)
+				}
+				resumed = restored
+			}
+
+			for _, size := range sizes[150:] {
+				whole.Insert(size)
+				resumed.Insert(size)
+			}
+			whole.Flush()
+			resumed.Flush()
+			if !slices.Equal(whole.GetPackedRects(), resumed.GetPackedRects()) {
+				t.Errorf("%v config %d: resumed layout differs", heuristic, i)
+			}
+			if !maps.Equal(whole.GetIdMapRotated(), resumed.GetIdMapRotated()) {
+				t.Errorf("%v config %d: resumed rotation differs", heuristic, i)
+			}
+			if (whole.CutTree() == nil) != (resumed.CutTree() == nil) {
+				t.Errorf("%v config %d: resumed cut tree differs", heuristic, i)
+			}
+		}
+	}
+
+	if err := (&Packer{}).UnmarshalJSON([]byte(`{"version":99}`)); err == nil {
+		t.Error("unsupported version was accepted")
+	}
+}
+
+func TestTracer(t *testing.T) {
+	sizes := randomInstance(5, NewSize2D(256, 256))
+	for _, heuristic := range []Heuristic{MaxRectsBSSF, MaxRectsCP, GuillotineBAF} {
+		plain, _ := NewPacker(256, 256, heuristic)
+		traced, _ := NewPacker(256, 256, heuristic)
+		for _, packer := range []*Packer{plain, traced} {
+			packer.AllowRotate(true)
+			packer.SetPadding(1)
+			packer.Insert(sizes...)
+		}
+		var trace Trace
+		traced.SetTracer(&trace)
+		plain.Pack()
+		traced.Pack()
+		if !slices.Equal(plain.GetPackedRects(), traced.GetPackedRects()) {
+			t.Fatalf("%v: tracing changed the layout", heuristic)
+		}
+		if len(trace.Runs) != 1 || trace.Runs[0].Bin != NewSize2D(256, 256) {
+			t.Fatalf("%v: unexpected runs %d", heuristic, len(trace.Runs))
+		}
+		steps := trace.Runs[0].Steps
+		packed := traced.GetPackedRects()
+		placed := 0
+		for i, step := range steps {
+			if step.Index != i {
+				t.Fatalf("%v: step %d has index %d", heuristic, i, step.Index)
+			}
+			if step.Item < 0 {
+				continue
+			}
+			if step.Placed != packed[placed] || step.Rotated != traced.GetIdMapRotated()[step.Placed.ID] {
+				t.Fatalf("%v: step %d placed %v, want %v", heuristic, i, step.Placed, packed[placed])
+			}
+			placed++
+			// 放置结果必须是某个候选位置
+			found := false
+			for _, c := range step.Candidates {
+				free := step.FreeRects[c.Free]
+				found = found || c.Item == step.Item && c.Rotated == step.Rotated && free.X == step.Placed.X-1 && free.Y == step.Placed.Y-1
+			}
+			if !found {
+				t.Fatalf("%v: step %d placement is not among its candidates", heuristic, i)
+			}
+		}
+		if placed != len(packed) {
+			t.Fatalf("%v: traced %d placements, packed %d", heuristic, placed, len(packed))
+		}
+		if last := steps[len(steps)-1]; !slices.Equal(last.FreeAfter, traced.FreeRects()) {
+			t.Fatalf("%v: last step free rects differ from the packer", heuristic)
+		}
+	}
+}
+
+func TestImprove(t *testing.T) {
+	bin := NewSize2D(512, 512)
+	sizes := randomInstance(7, NewSize2D(256, 256))
+	improved := 0
+	for _, heuristic := range []Heuristic{MaxRectsBSSF, MaxRectsBL, MaxRectsCP, GuillotineBAF, GuillotineBSSF} {
+		packer, _ := NewPacker(bin.Width, bin.Height, heuristic)
+		packer.AllowRotate(true)
+		packer.SetPadding(2)
+		packer.Insert(sizes...)
+		packer.Pack()
+		before := packer.MinSize()
+		ok, err := packer.Improve(context.Background(), 0)
+		if err != nil {
+			t.Fatal(err)
+		}
+		after := packer.MinSize()
+		if after.Area() > before.Area() {
+			t.Errorf("%v: MinSize grew from %v to %v", heuristic, before, after)
+		}
+		if ok && after.Area() < before.Area() {
+			improved++
+		}
+		for _, v := range Validate(packer.Layout(sizes), bin, 2) {
+			t.Errorf("%v: %s", heuristic, v.String())
+		}
+		// 重建的剩余空间必须与新布局一致
+		packer.Online = true
+		extra := NewSize2DByID(1000, 40, 40)
+		packer.Insert(extra)
+		for _, v := range Validate(packer.Layout(append(slices.Clone(sizes), extra)), bin, 2) {
+			t.Errorf("%v after insert: %s", heuristic, v.String())
+		}
+	}
+	if improved == 0 {
+		t.Error("no heuristic was improved")
+	}
+
+	catalog := []BinType{{Width: 256, Height: 256, Cost: 1}}
+	packer, _ := NewPacker(256, 256, MaxRectsBSSF)
+	packer.Insert(sizes...)
+	pages, _ := packer.PackCatalog(catalog)
+	total := 0
+	for _, page := range pages {
+		total += len(page.Packer.GetPackedRects())
+	}
+	fewer, err := ImprovePages(context.Background(), pages, 10)
+	if err != nil || len(fewer) > len(pages) {
+		t.Fatalf("ImprovePages returned %d pages (from %d), %v", len(fewer), len(pages), err)
+	}
+	packed := 0
+	for _, page := range fewer {
+		packed += len(page.Packer.GetPackedRects())
+		for _, v := range Validate(Layout{Rects: page.Packer.GetPackedRects(), Rotated: page.Packer.GetIdMapRotated()}, NewSize2D(page.Bin.Width, page.Bin.Height), 0) {
+			if v.Kind == ViolationOverlap {
+				t.Errorf("page %d: %s", len(fewer), v.String())
+			}
+		}
+	}
+	if packed != total {
+		t.Errorf("ImprovePages kept %d rects, want %d", packed, total)
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	packer, _ = NewPacker(bin.Width, bin.Height, MaxRectsBSSF)
+	packer.Insert(sizes...)
+	packer.Pack()
+	if _, err := packer.Improve(ctx, 0); err != context.Canceled {
+		t.Errorf("Improve with a cancelled context returned %v", err)
+	}
+}
+
+func TestConcurrentPacker(t *testing.T) {
+	template, _ := NewPacker(256, 256, MaxRectsBSSF)
+	template.AllowRotate(true)
+	template.SetPadding(1)
+	sizes := randomInstance(11, NewSize2D(512, 512))
+
+	c, err := NewConcurrentPacker(template)
+	if err != nil {
+		t.Fatal(err)
+	}
+	const workers = 8
+	var wg sync.WaitGroup
+	for w := range workers {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for i := w; i < len(sizes); i += workers {
+				if _, ok := c.Insert(sizes[i]); !ok {
+					t.Errorf("size %d was not placed", sizes[i].ID)
+				}
+				// 移除一部分后空出的位置可以被其他 goroutine 使用
+				if i%5 == 0 && !c.Remove(sizes[i].ID) {
+					t.Errorf("size %d was not removed", sizes[i].ID)
+				}
+			}
+		}()
+	}
+	wg.Wait()
+	removed := (len(sizes) + 4) / 5
+	if c.Len() != len(sizes)-removed {
+		t.Errorf("Len() = %d, want %d", c.Len(), len(sizes)-removed)
+	}
+	total := 0
+	c.View(func(page int, packer *Packer) {
+		total += len(packer.GetPackedRects())
+		for _, v := range Validate(Layout{Rects: packer.GetPackedRects(), Rotated: packer.GetIdMapRotated()}, NewSize2D(256, 256), 1) {
+			if v.Kind == ViolationOverlap || v.Kind == ViolationPadding || v.Kind == ViolationOutOfBounds {
+				t.Errorf("page %d: %s", page, v.String())
+			}
+		}
+	})
+	if total != c.Len() {
+		t.Errorf("pages hold %d rects, want %d", total, c.Len())
+	}
+
+	// 确定模式下相同的调用顺序得到相同的结果
+	var results [2][]Placement
+	for run := range results {
+		c, _ := NewConcurrentPacker(template)
+		c.Deterministic = true
+		c.MaxPages = 2
+		for _, size := range sizes {
+			placement, _ := c.Insert(size)
+			results[run] = append(results[run], placement)
+		}
+		if c.Pages() != 2 {
+			t.Errorf("opened %d pages, want the limit 2", c.Pages())
+		}
+	}
+	if !slices.Equal(results[0], results[1]) {
+		t.Error("deterministic mode gave different placements")
+	}
+}
+
+func TestPackMasks(t *testing.T) {
+	// 成对的三角形可以拼成一个正方形，外接矩形互相重叠
+	const side, pairs = 32, 8
+	masks := make(map[int]*Mask)
+	var sizes []Size2D
+	for id := range 2 * pairs {
+		mask := NewMask(side, side)
+		for y := range side {
+			for x := range side {
+				mask.Set(x, y, (x > y) == (id%2 == 0))
+			}
+		}
+		masks[id] = mask
+		sizes = append(sizes, NewSize2DByID(id, side, side))
+	}
+	if got := masks[0].Rotated(); !got.Get(side-1, side-1) || got.Get(0, side-1) || got.Count() != masks[0].Count() {
+		t.Error("Rotated did not turn the mask clockwise")
+	}
+
+	for _, tc := range []struct{ padding, cell int }{{0, 1}, {0, 4}, {2, 2}} {
+		packer, _ := NewPacker(160, 160, MaxRectsBSSF)
+		packer.AllowRotate(true)
+		packer.SetPadding(tc.padding)
+		packer.Insert(sizes...)
+		if !packer.PackMasks(masks, tc.cell) {
+			t.Errorf("padding %d cell %d: %d sizes not packed", tc.padding, tc.cell, len(packer.GetUnpackedRects()))
+			continue
+		}
+		// 按矩形打包至少需要所有外接矩形的面积
+		if tc.padding == 0 && tc.cell == 1 && packer.MinSize().Width*packer.MinSize().Height >= 2*pairs*side*side {
+			t.Errorf("triangles did not interlock: min size %v", packer.MinSize())
+		}
+		// 占用的像素(按间距扩展后)互不重叠
+		owner := make(map[image.Point]int)
+		for _, rect := range packer.GetPackedRects() {
+			mask := masks[rect.ID]
+			if packer.GetIdMapRotated()[rect.ID] {
+				mask = mask.Rotated()
+			}
+			for y := range rect.Height {
+				for x := range rect.Width {
+					if !mask.Get(x, y) {
+						continue
+					}
+					for dy := 0; dy <= tc.padding; dy++ {
+						for dx := 0; dx <= tc.padding; dx++ {
+							pt := image.Pt(rect.X+x+dx, rect.Y+y+dy)
+							if id, ok := owner[pt]; ok && id != rect.ID {
+								t.Fatalf("padding %d cell %d: rects %d and %d collide at %v", tc.padding, tc.cell, id, rect.ID, pt)
+							}
+							owner[pt] = rect.ID
+						}
+					}
+				}
+			}
+		}
+	}
+}
+
+func TestPackScenes(t *testing.T) {
+	// 每个场景的4个方块正好占满一页，ID 交错排列，按顺序填充会把场景拆到多页
+	const scenesCount = 4
+	var sizes []Size2D
+	scenes := make([]Scene, scenesCount)
+	for id := range 4 * scenesCount {
+		sizes = append(sizes, NewSize2DByID(id, 45+id%3, 45))
+		scenes[id%scenesCount].Name = fmt.Sprintf("scene%d", id%scenesCount)
+		scenes[id%scenesCount].IDs = append(scenes[id%scenesCount].IDs, id)
+	}
+	// 不属于场景的小矩形填充剩余空间
+	for id := 4 * scenesCount; id < 4*scenesCount+6; id++ {
+		sizes = append(sizes, NewSize2DByID(id, 8, 8))
+	}
+	// 同时用到两个整页场景中的矩形的场景至少需要两页
+	scenes = append(scenes, Scene{Name: "shared", IDs: []int{0, 1}, Weight: 0.5})
+
+	packer, _ := NewPacker(100, 100, MaxRectsBSSF)
+	packer.Insert(sizes...)
+	pages, ok := packer.PackScenes(scenes)
+	if !ok {
+		t.Fatalf("%d sizes not packed", len(packer.GetUnpackedRects()))
+	}
+	total := 0
+	for i, page := range pages {
+		total += len(page.Packer.GetPackedRects())
+		for _, v := range Validate(Layout{Rects: page.Packer.GetPackedRects()}, NewSize2D(100, 100), 0) {
+			if v.Kind == ViolationOverlap || v.Kind == ViolationOutOfBounds {
+				t.Errorf("page %d: %s", i, v.String())
+			}
+		}
+	}
+	if total != len(sizes) {
+		t.Errorf("pages hold %d rects, want %d", total, len(sizes))
+	}
+	usages := SceneUsages(scenes, pages)
+	for _, usage := range usages[:scenesCount] {
+		if usage.DrawCalls != 1 {
+			t.Errorf("%s uses pages %v, want a single page", usage.Name, usage.Pages)
+		}
+	}
+	if usages[scenesCount].DrawCalls != 2 {
+		t.Errorf("shared scene uses pages %v, want 2 pages", usages[scenesCount].Pages)
+	}
+	if usages[0].Weight != 1 || usages[scenesCount].Weight != 0.5 {
+		t.Errorf("scene weights %v and %v, want 1 and 0.5", usages[0].Weight, usages[scenesCount].Weight)
+	}
+	if len(pages) != scenesCount {
+		t.Errorf("opened %d pages, want %d", len(pages), scenesCount)
+	}
+}
+
+func TestPackGrid(t *testing.T) {
+	// 两个动画：3帧和7帧，帧的尺寸不同
+	var sizes []Size2D
+	for id := range 10 {
+		size := NewSize2DByID(id, 10+id%3, 12-id%2)
+		size.Group = 1 + min(id/3, 1)
+		sizes = append(sizes, size)
+	}
+	options := GridOptions{Columns: 4, Spacing: 2, Margin: 1, RowPerGroup: true}
+
+	packer, _ := NewPacker(100, 100, MaxRectsBSSF)
+	packer.SetPadding(3) // 网格布局不使用包装器的间距
+	packer.Insert(sizes...)
+	grid, err := packer.PackGrid(options)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := Grid{CellWidth: 12, CellHeight: 12, Columns: 4, Rows: 3, Spacing: 2, Margin: 1}
+	if grid != want {
+		t.Errorf("grid = %+v, want %+v", grid, want)
+	}
+	if size := grid.Size(); size.Width != 2+4*12+3*2 || size.Height != 2+3*12+2*2 {
+		t.Errorf("grid size = %v", size)
+	}
+	cells := [][2]int{{0, 0}, {1, 0}, {2, 0}, {0, 1}, {1, 1}, {2, 1}, {3, 1}, {0, 2}, {1, 2}, {2, 2}}
+	for i, rect := range packer.GetPackedRects() {
+		column, row := grid.Cell(rect)
+		if rect.ID != i || [2]int{column, row} != cells[i] {
+			t.Errorf("rect %d is in cell (%d, %d), want %d in %v", rect.ID, column, row, i, cells[i])
+		}
+	}
+	area := 0
+	for _, size := range sizes {
+		area += size.Area()
+	}
+	if free := packer.FreeArea(); free != 100*100-area {
+		t.Errorf("free area = %d, want %d", free, 100*100-area)
+	}
+
+	// 条带布局每个动画一行，列数为最长的动画
+	packer.Reset()
+	packer.Insert(sizes...)
+	if grid, _ = packer.PackStrip(options); grid.Columns != 7 || grid.Rows != 2 {
+		t.Errorf("strip = %+v, want 7 columns and 2 rows", grid)
+	}
+	// 条带布局忽略 Columns，即使它比最长的一行还多
+	packer.Reset()
+	packer.Insert(sizes...)
+	if grid, err = packer.PackStrip(GridOptions{Columns: 9, Spacing: 2, Margin: 1, RowPerGroup: true}); err != nil || grid.Columns != 7 {
+		t.Errorf("strip with Columns = %+v, %v, want 7 columns", grid, err)
+	}
+
+	// 放不下的行保持顺序留给下一页
+	packer.ResetMaxSize(100, 30)
+	packer.Insert(sizes...)
+	if grid, _ = packer.PackGrid(options); grid.Rows != 2 || len(packer.GetPackedRects()) != 7 {
+		t.Errorf("grid in a short bin = %+v with %d rects", grid, len(packer.GetPackedRects()))
+	}
+	for i, size := range packer.GetUnpackedRects() {
+		if size.ID != 7+i {
+			t.Errorf("unpacked[%d] = %d, want %d", i, size.ID, 7+i)
+		}
+	}
+
+	packer.Reset()
+	packer.Insert(sizes...)
+	if _, err := packer.PackGrid(GridOptions{CellWidth: 10, CellHeight: 10}); err == nil {
+		t.Error("cells smaller than the sizes were accepted")
+	}
+	packer.Reset()
+	packer.Insert(sizes...)
+	if _, err := packer.PackGrid(GridOptions{Columns: 9}); err == nil {
+		t.Error("columns wider than the bin were accepted")
+	}
+
+	// 已经放置的矩形不能被网格布局覆盖，也不能丢失
+	packer.Reset()
+	packer.Insert(NewSize2DByID(0, 20, 20))
+	packer.Pack()
+	packer.Insert(NewSize2DByID(1, 10, 10), NewSize2DByID(2, 10, 10))
+	if _, err := packer.PackGrid(GridOptions{}); err == nil {
+		t.Error("grid layout was accepted over placed rects")
+	}
+	if len(packer.GetPackedRects()) != 1 || len(packer.GetUnpackedRects()) != 2 {
+		t.Errorf("refused grid layout left %d packed and %d unpacked rects, want 1 and 2", len(packer.GetPackedRects()), len(packer.GetUnpackedRects()))
+	}
+}
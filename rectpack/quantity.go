@@ -0,0 +1,119 @@
+package rectpack
+
+import "slices"
+
+// expandQuantities 把 Quantity 大于1的尺寸展开为多个实例，ID 依次为 ID, ID+1, ..., ID+Quantity-1
+func expandQuantities(sizes []Size2D) []Size2D {
+	n := 0
+	for _, size := range sizes {
+		n += max(size.Quantity, 1)
+	}
+	if n == len(sizes) {
+		return sizes
+	}
+	expanded := make([]Size2D, 0, n)
+	for _, size := range sizes {
+		count := max(size.Quantity, 1)
+		size.Quantity = 0
+		for i := range count {
+			instance := size
+			instance.ID += i
+			expanded = append(expanded, instance)
+		}
+	}
+	return expanded
+}
+
+// classKey 是决定评分结果的尺寸属性，这些属性相同的尺寸在同一位置的评分也相同
+type classKey struct {
+	width    int
+	height   int
+	noRotate bool
+}
+
+// sizeClass 是一组评分相同的尺寸，每一步只需对其中第一个实例评分
+type sizeClass struct {
+	start int // 尚未放置的实例为 sizeClasses.items[start:end]，按输入顺序排列
+	end   int
+}
+
+// sizeClasses 是 insertClasses 使用的缓冲区，在多次插入之间复用以避免分配
+type sizeClasses struct {
+	index   map[classKey]int
+	classes []sizeClass
+	reps    []Size2D // 每类的代表(第一个尚未放置的实例)
+	items   []Size2D // 按类排列的所有实例
+	owner   []int    // 每个输入尺寸所属的类
+}
+
+// group 按首次出现的顺序把尺寸分组，并选出每组的代表
+func (s *sizeClasses) group(sizes []Size2D) {
+	if s.index == nil {
+		s.index = make(map[classKey]int)
+	}
+	clear(s.index)
+	s.classes = s.classes[:0]
+	s.owner = s.owner[:0]
+	for _, size := range sizes {
+		key := classKey{size.Width, size.Height, size.NoRotate}
+		i, ok := s.index[key]
+		if !ok {
+			i = len(s.classes)
+			s.index[key] = i
+			s.classes = append(s.classes, sizeClass{})
+		}
+		s.classes[i].end++
+		s.owner = append(s.owner, i)
+	}
+	// 先按数量确定每类在 items 中的区间，再按输入顺序填入
+	offset := 0
+	for i := range s.classes {
+		count := s.classes[i].end
+		s.classes[i] = sizeClass{offset, offset}
+		offset += count
+	}
+	s.items = slices.Grow(s.items[:0], len(sizes))[:len(sizes)]
+	for j, size := range sizes {
+		class := &s.classes[s.owner[j]]
+		s.items[class.end] = size
+		class.end++
+	}
+	s.reps = s.reps[:0]
+	for _, class := range s.classes {
+		s.reps = append(s.reps, s.items[class.start])
+	}
+}
+
+// insertClasses 把相同的尺寸合并为一类后反复调用 insertBest 放置，
+// 每一步只对每类的代表评分，因此大量重复的尺寸不会使评分次数成倍增加。
+// ordered 为 true 时移除放完的类并保持其余类的顺序，否则用最后一类填补空位。
+// 返回无法放置的尺寸(由内部管理，下次插入前有效)
+func (p *algorithmBase) insertClasses(padding int, sizes []Size2D, insertBest func(padding int, sizes []Size2D) int, ordered bool) []Size2D {
+	s := &p.classes
+	s.group(sizes)
+	classes, reps := s.classes, s.reps
+	for len(reps) > 0 {
+		i := insertBest(padding, reps)
+		if i == -1 {
+			break
+		}
+		classes[i].start++
+		if classes[i].start < classes[i].end {
+			reps[i] = s.items[classes[i].start]
+			continue
+		}
+		if ordered {
+			classes = slices.Delete(classes, i, i+1)
+			reps = slices.Delete(reps, i, i+1)
+		} else {
+			last := len(reps) - 1
+			classes[i], reps[i] = classes[last], reps[last]
+			classes, reps = classes[:last], reps[:last]
+		}
+	}
+	p.failed = p.failed[:0]
+	for _, class := range classes {
+		p.failed = append(p.failed, s.items[class.start:class.end]...)
+	}
+	return p.failed
+}
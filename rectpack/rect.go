@@ -43,6 +43,14 @@ type Size2D struct {
 	Height int
 	// ID 是用户定义的标识符，用于区分此实例与其他实例。
 	ID int
+	// Group 是分组标识，分层打包时同组矩形会被放入同一个连续区域，0 表示不分组。
+	Group int
+	// NoRotate 为 true 时禁止旋转此矩形，即使包装器允许旋转。
+	NoRotate bool
+	// Elastic 描述可伸缩矩形允许的尺寸范围，nil 表示尺寸固定。
+	Elastic *Elastic
+	// Quantity 大于1时表示 Quantity 个相同的矩形，插入时展开为 ID 依次为 ID, ID+1, ..., ID+Quantity-1 的实例。
+	Quantity int
 }
 
 // NewSize2D 创建具有指定尺寸的新尺寸对象。
@@ -244,24 +252,34 @@ func padSize(size *Size2D, padding int) {
 	size.Height += padding
 }
 
+// padRect 是 unpadRect 的逆操作，把放置结果还原为包含间距的节点
+//
+//	rect - 要修改的矩形指针
+//	padding - 要添加的间距大小
+func padRect(rect *Rect2D, padding int) {
+	if padding <= 0 {
+		return
+	}
+	rect.X -= padding
+	rect.Y -= padding
+	rect.Width += padding
+	rect.Height += padding
+}
+
 // unpadRect 从矩形中移除内边距
 //
+// 包含间距的节点宽高比原尺寸多 padding，移除后矩形向右下偏移 padding 并恢复原尺寸，
+// 这样相邻矩形之间以及矩形与容器左上边缘之间都保留 padding 的间距，
+// 右下边缘的间距由 MinSize 补足。
+//
 //	rect - 要修改的矩形指针
 //	padding - 要移除的内边距大小
 func unpadRect(rect *Rect2D, padding int) {
 	if padding <= 0 {
 		return
 	}
-	if rect.X == 0 {
-		rect.X += padding
-		rect.Width -= padding * 2
-	} else {
-		rect.Width -= padding
-	}
-	if rect.Y == 0 {
-		rect.Y += padding
-		rect.Height -= padding * 2
-	} else {
-		rect.Height -= padding
-	}
+	rect.X += padding
+	rect.Y += padding
+	rect.Width -= padding
+	rect.Height -= padding
 }
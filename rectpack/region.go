@@ -0,0 +1,403 @@
+package rectpack
+
+import (
+	"cmp"
+	"image"
+	"slices"
+)
+
+// Region 是由任意多个矩形组成的区域，支持并、交、差运算
+//
+// 区域按 y 坐标分为互不重叠的水平条带，每个条带中是按 x 排序、互不相接的区间；
+// 相接且区间完全相同的条带会合并，因此同一块区域的表示唯一，可以用 Equal 比较。
+// Region 创建后不再改变，零值为空区域。
+type Region struct {
+	bands []band
+}
+
+// band 是区域中 [y0, y1) 范围内的一个水平条带
+type band struct {
+	y0, y1 int
+	spans  []span
+}
+
+// span 是条带中 [x0, x1) 的一个区间
+type span struct {
+	x0, x1 int
+}
+
+// NewRegion 创建由 rects 覆盖的区域，矩形可以互相重叠，空矩形被忽略
+func NewRegion(rects ...Rect2D) Region {
+	ys := make([]int, 0, len(rects)*2)
+	for _, rect := range rects {
+		if !rect.IsEmpty() {
+			ys = append(ys, rect.Top(), rect.Bottom())
+		}
+	}
+	slices.Sort(ys)
+	ys = slices.Compact(ys)
+
+	var r Region
+	var spans []span
+	for i := 0; i+1 < len(ys); i++ {
+		y0, y1 := ys[i], ys[i+1]
+		spans = spans[:0]
+		for _, rect := range rects {
+			if !rect.IsEmpty() && rect.Top() <= y0 && y1 <= rect.Bottom() {
+				spans = append(spans, span{rect.Left(), rect.Right()})
+			}
+		}
+		slices.SortFunc(spans, func(a, b span) int { return cmp.Compare(a.x0, b.x0) })
+		var merged []span
+		for _, s := range spans {
+			if n := len(merged); n > 0 && s.x0 <= merged[n-1].x1 {
+				merged[n-1].x1 = max(merged[n-1].x1, s.x1)
+			} else {
+				merged = append(merged, s)
+			}
+		}
+		r.appendBand(y0, y1, merged)
+	}
+	return r
+}
+
+// RegionFromImage 创建由 image.Rectangle 覆盖的区域
+func RegionFromImage(rects ...image.Rectangle) Region {
+	converted := make([]Rect2D, len(rects))
+	for i, rect := range rects {
+		converted[i] = RectFromImage(rect)
+	}
+	return NewRegion(converted...)
+}
+
+// RectFromImage 把 image.Rectangle 转换为矩形，Min 和 Max 顺序颠倒时先规范化
+func RectFromImage(rect image.Rectangle) Rect2D {
+	rect = rect.Canon()
+	return NewRectLTRB(rect.Min.X, rect.Min.Y, rect.Max.X, rect.Max.Y)
+}
+
+// ImageRect 把矩形转换为 image.Rectangle
+func (r *Rect2D) ImageRect() image.Rectangle {
+	return image.Rect(r.Left(), r.Top(), r.Right(), r.Bottom())
+}
+
+// appendBand 在区域末尾追加一个条带，与上一个条带相接且区间相同时合并，空条带被忽略
+func (r *Region) appendBand(y0, y1 int, spans []span) {
+	if len(spans) == 0 || y1 <= y0 {
+		return
+	}
+	if n := len(r.bands); n > 0 && r.bands[n-1].y1 == y0 && slices.Equal(r.bands[n-1].spans, spans) {
+		r.bands[n-1].y1 = y1
+		return
+	}
+	r.bands = append(r.bands, band{y0: y0, y1: y1, spans: slices.Clone(spans)})
+}
+
+// Union 返回两个区域的并集
+func (r Region) Union(other Region) Region {
+	return combine(r, other, func(a, b bool) bool { return a || b })
+}
+
+// Intersect 返回两个区域的交集
+func (r Region) Intersect(other Region) Region {
+	return combine(r, other, func(a, b bool) bool { return a && b })
+}
+
+// Subtract 返回从区域中去掉 other 之后的部分
+func (r Region) Subtract(other Region) Region {
+	return combine(r, other, func(a, b bool) bool { return a && !b })
+}
+
+// UnionRect 返回区域与矩形的并集
+func (r Region) UnionRect(rect Rect2D) Region {
+	return r.Union(NewRegion(rect))
+}
+
+// IntersectRect 返回区域位于矩形内的部分
+func (r Region) IntersectRect(rect Rect2D) Region {
+	return r.Intersect(NewRegion(rect))
+}
+
+// SubtractRects 返回从区域中去掉 rects 之后的部分
+func (r Region) SubtractRects(rects ...Rect2D) Region {
+	return r.Subtract(NewRegion(rects...))
+}
+
+// combine 逐条带按 op 合并两个区域，op 根据一点是否属于 a 和 b 判断它是否属于结果
+func combine(a, b Region, op func(inA, inB bool) bool) Region {
+	ys := make([]int, 0, (len(a.bands)+len(b.bands))*2)
+	for _, bands := range [][]band{a.bands, b.bands} {
+		for _, band := range bands {
+			ys = append(ys, band.y0, band.y1)
+		}
+	}
+	slices.Sort(ys)
+	ys = slices.Compact(ys)
+
+	var r Region
+	var xs []int
+	var spans []span
+	ia, ib := 0, 0
+	for i := 0; i+1 < len(ys); i++ {
+		y0, y1 := ys[i], ys[i+1]
+		spansA, spansB := spansAt(a.bands, &ia, y0), spansAt(b.bands, &ib, y0)
+		xs = xs[:0]
+		for _, s := range spansA {
+			xs = append(xs, s.x0, s.x1)
+		}
+		for _, s := range spansB {
+			xs = append(xs, s.x0, s.x1)
+		}
+		slices.Sort(xs)
+		xs = slices.Compact(xs)
+		spans = spans[:0]
+		ja, jb := 0, 0
+		for j := 0; j+1 < len(xs); j++ {
+			x0, x1 := xs[j], xs[j+1]
+			for ja < len(spansA) && spansA[ja].x1 <= x0 {
+				ja++
+			}
+			for jb < len(spansB) && spansB[jb].x1 <= x0 {
+				jb++
+			}
+			inA := ja < len(spansA) && spansA[ja].x0 <= x0
+			inB := jb < len(spansB) && spansB[jb].x0 <= x0
+			if !op(inA, inB) {
+				continue
+			}
+			if n := len(spans); n > 0 && spans[n-1].x1 == x0 {
+				spans[n-1].x1 = x1
+			} else {
+				spans = append(spans, span{x0, x1})
+			}
+		}
+		r.appendBand(y0, y1, spans)
+	}
+	return r
+}
+
+// spansAt 返回覆盖 y 的条带中的区间，i 是按 y 递增查询时的游标
+func spansAt(bands []band, i *int, y int) []span {
+	for *i < len(bands) && bands[*i].y1 <= y {
+		*i++
+	}
+	if *i < len(bands) && bands[*i].y0 <= y {
+		return bands[*i].spans
+	}
+	return nil
+}
+
+// IsEmpty 判断区域是否为空
+func (r Region) IsEmpty() bool {
+	return len(r.bands) == 0
+}
+
+// Area 返回区域的面积
+func (r Region) Area() int {
+	area := 0
+	for _, band := range r.bands {
+		for _, s := range band.spans {
+			area += (s.x1 - s.x0) * (band.y1 - band.y0)
+		}
+	}
+	return area
+}
+
+// Bounds 返回包含整个区域的最小矩形，空区域返回空矩形
+func (r Region) Bounds() Rect2D {
+	if r.IsEmpty() {
+		return Rect2D{}
+	}
+	left, right := r.bands[0].spans[0].x0, r.bands[0].spans[0].x1
+	for _, band := range r.bands {
+		left = min(left, band.spans[0].x0)
+		right = max(right, band.spans[len(band.spans)-1].x1)
+	}
+	return NewRectLTRB(left, r.bands[0].y0, right, r.bands[len(r.bands)-1].y1)
+}
+
+// Equal 判断两个区域是否覆盖相同的点
+func (r Region) Equal(other Region) bool {
+	return slices.EqualFunc(r.bands, other.bands, func(a, b band) bool {
+		return a.y0 == b.y0 && a.y1 == b.y1 && slices.Equal(a.spans, b.spans)
+	})
+}
+
+// Contains 判断点 (x, y) 是否在区域内
+func (r Region) Contains(x, y int) bool {
+	i, found := slices.BinarySearchFunc(r.bands, y, func(b band, y int) int {
+		if b.y1 <= y {
+			return -1
+		}
+		if b.y0 > y {
+			return 1
+		}
+		return 0
+	})
+	if !found {
+		return false
+	}
+	for _, s := range r.bands[i].spans {
+		if s.x0 <= x && x < s.x1 {
+			return true
+		}
+	}
+	return false
+}
+
+// ContainsRect 判断矩形是否完全在区域内，空矩形总是被包含
+func (r Region) ContainsRect(rect Rect2D) bool {
+	if rect.IsEmpty() {
+		return true
+	}
+	y := rect.Top()
+	for _, band := range r.bands {
+		if band.y1 <= y {
+			continue
+		}
+		if band.y0 > y {
+			return false
+		}
+		covered := false
+		for _, s := range band.spans {
+			if s.x0 <= rect.Left() && rect.Right() <= s.x1 {
+				covered = true
+				break
+			}
+		}
+		if !covered {
+			return false
+		}
+		if y = band.y1; y >= rect.Bottom() {
+			return true
+		}
+	}
+	return false
+}
+
+// Intersects 判断矩形是否与区域有重叠
+func (r Region) Intersects(rect Rect2D) bool {
+	for _, band := range r.bands {
+		if band.y1 <= rect.Top() || band.y0 >= rect.Bottom() {
+			continue
+		}
+		for _, s := range band.spans {
+			if s.x0 < rect.Right() && rect.Left() < s.x1 {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// Translate 返回平移 (dx, dy) 后的区域
+func (r Region) Translate(dx, dy int) Region {
+	bands := make([]band, len(r.bands))
+	for i, b := range r.bands {
+		spans := make([]span, len(b.spans))
+		for j, s := range b.spans {
+			spans[j] = span{s.x0 + dx, s.x1 + dx}
+		}
+		bands[i] = band{y0: b.y0 + dy, y1: b.y1 + dy, spans: spans}
+	}
+	return Region{bands: bands}
+}
+
+// Rects 把区域分解为互不重叠的矩形，按上边缘再按左边缘排序
+//
+// 条带中的区间在下一个条带中不变时向下延伸，因此矩形数量通常少于条带区间的总数。
+func (r Region) Rects() []Rect2D {
+	var rects, open, next []Rect2D // open 为上一个条带中仍可向下延伸的矩形
+	for _, band := range r.bands {
+		next = next[:0]
+		for _, s := range band.spans {
+			extended := false
+			for i, rect := range open {
+				if rect.Bottom() == band.y0 && rect.Left() == s.x0 && rect.Right() == s.x1 {
+					rect.Height = band.y1 - rect.Y
+					next = append(next, rect)
+					open = slices.Delete(open, i, i+1)
+					extended = true
+					break
+				}
+			}
+			if !extended {
+				next = append(next, NewRectLTRB(s.x0, band.y0, s.x1, band.y1))
+			}
+		}
+		rects = append(rects, open...)
+		open, next = next, open[:0]
+	}
+	rects = append(rects, open...)
+	slices.SortFunc(rects, func(a, b Rect2D) int {
+		return cmp.Or(cmp.Compare(a.Y, b.Y), cmp.Compare(a.X, b.X))
+	})
+	return rects
+}
+
+// ImageRects 把区域分解为互不重叠的 image.Rectangle，顺序与 Rects 相同
+func (r Region) ImageRects() []image.Rectangle {
+	rects := r.Rects()
+	converted := make([]image.Rectangle, len(rects))
+	for i := range rects {
+		converted[i] = rects[i].ImageRect()
+	}
+	return converted
+}
+
+// MaximalRects 返回区域中所有的最大矩形(不被区域内其他矩形包含的矩形)，按上边缘再按左边缘排序
+//
+// 最大矩形之间可以互相重叠，与 MaxRects 算法的剩余矩形表示相同：
+// 一个尺寸能放入区域，当且仅当它能放入某个最大矩形。
+func (r Region) MaximalRects() []Rect2D {
+	if r.IsEmpty() {
+		return nil
+	}
+	bounds := r.Bounds()
+	rects := []Rect2D{bounds}
+	for _, hole := range NewRegion(bounds).Subtract(r).Rects() {
+		var split []Rect2D
+		for _, rect := range rects {
+			if !rect.Intersects(hole) {
+				split = append(split, rect)
+				continue
+			}
+			// 与 MaxRects 分割剩余矩形相同，保留洞四周的最大部分
+			if hole.Top() > rect.Top() {
+				split = append(split, NewRectLTRB(rect.Left(), rect.Top(), rect.Right(), hole.Top()))
+			}
+			if hole.Bottom() < rect.Bottom() {
+				split = append(split, NewRectLTRB(rect.Left(), hole.Bottom(), rect.Right(), rect.Bottom()))
+			}
+			if hole.Left() > rect.Left() {
+				split = append(split, NewRectLTRB(rect.Left(), rect.Top(), hole.Left(), rect.Bottom()))
+			}
+			if hole.Right() < rect.Right() {
+				split = append(split, NewRectLTRB(hole.Right(), rect.Top(), rect.Right(), rect.Bottom()))
+			}
+		}
+		rects = pruneContained(split)
+	}
+	slices.SortFunc(rects, func(a, b Rect2D) int {
+		return cmp.Or(cmp.Compare(a.Y, b.Y), cmp.Compare(a.X, b.X), cmp.Compare(a.Width, b.Width), cmp.Compare(a.Height, b.Height))
+	})
+	return rects
+}
+
+// pruneContained 去掉被其他矩形包含的矩形，相同的矩形只保留一个
+func pruneContained(rects []Rect2D) []Rect2D {
+	var kept []Rect2D
+	for i, rect := range rects {
+		contained := false
+		for j, other := range rects {
+			if i != j && other.ContainsRect(rect) && (!rect.Eq(other) || j < i) {
+				contained = true
+				break
+			}
+		}
+		if !contained {
+			kept = append(kept, rect)
+		}
+	}
+	return kept
+}
@@ -0,0 +1,71 @@
+package rectpack
+
+import (
+	"image"
+	"math/rand/v2"
+	"slices"
+	"testing"
+)
+
+func TestRegion(t *testing.T) {
+	square := NewRegion(NewRect(0, 0, 30, 30))
+	frame := square.SubtractRects(NewRect(10, 10, 10, 10))
+	if frame.Area() != 800 || frame.Contains(15, 15) || !frame.Contains(5, 15) {
+		t.Fatalf("frame area %d", frame.Area())
+	}
+	if got := frame.Rects(); len(got) != 4 || NewRegion(got...).Area() != 800 {
+		t.Errorf("Rects() = %v, want 4 disjoint rects", got)
+	}
+	want := []Rect2D{NewRect(0, 0, 10, 30), NewRect(0, 0, 30, 10), NewRect(20, 0, 10, 30), NewRect(0, 20, 30, 10)}
+	if got := frame.MaximalRects(); !slices.Equal(got, want) {
+		t.Errorf("MaximalRects() = %v, want %v", got, want)
+	}
+	if !frame.Union(NewRegion(NewRect(10, 10, 10, 10))).Equal(square) {
+		t.Error("filling the hole does not give back the square")
+	}
+	if !frame.ContainsRect(NewRect(0, 0, 10, 30)) || frame.ContainsRect(NewRect(0, 5, 15, 10)) {
+		t.Error("ContainsRect disagrees with the frame")
+	}
+	if frame.Bounds() != NewRect(0, 0, 30, 30) || frame.Intersects(NewRect(12, 12, 5, 5)) {
+		t.Error("Bounds or Intersects disagrees with the frame")
+	}
+	if got := RegionFromImage(frame.ImageRects()...); !got.Equal(frame) {
+		t.Error("image.Rectangle round trip changed the region")
+	}
+	if RectFromImage(image.Rect(5, 6, 1, 2)) != NewRect(1, 2, 4, 4) {
+		t.Error("RectFromImage does not canonicalize")
+	}
+
+	// 随机区域的运算结果与逐点计算一致
+	r := rand.New(rand.NewPCG(1, 1))
+	randomRegion := func() Region {
+		rects := make([]Rect2D, 6)
+		for i := range rects {
+			rects[i] = NewRect(r.IntN(20), r.IntN(20), r.IntN(12)+1, r.IntN(12)+1)
+		}
+		return NewRegion(rects...)
+	}
+	for range 50 {
+		a, b := randomRegion(), randomRegion()
+		union, inter, diff := a.Union(b), a.Intersect(b), a.Subtract(b)
+		for y := -1; y < 33; y++ {
+			for x := -1; x < 33; x++ {
+				inA, inB := a.Contains(x, y), b.Contains(x, y)
+				if union.Contains(x, y) != (inA || inB) || inter.Contains(x, y) != (inA && inB) || diff.Contains(x, y) != (inA && !inB) {
+					t.Fatalf("set operations disagree at (%d, %d)", x, y)
+				}
+			}
+		}
+		if union.Area() != a.Area()+b.Area()-inter.Area() {
+			t.Fatalf("union area %d, want %d", union.Area(), a.Area()+b.Area()-inter.Area())
+		}
+		for _, rect := range diff.MaximalRects() {
+			if !diff.ContainsRect(rect) {
+				t.Fatalf("maximal rect %v is outside the region", rect)
+			}
+		}
+		if !NewRegion(diff.MaximalRects()...).Equal(diff) || !NewRegion(diff.Rects()...).Equal(diff) {
+			t.Fatal("decomposition does not cover the region")
+		}
+	}
+}
@@ -0,0 +1,113 @@
+package render
+
+import (
+	"image"
+	"image/color"
+	"image/draw"
+	"math"
+
+	"golang.org/x/image/font"
+	"golang.org/x/image/font/basicfont"
+	"golang.org/x/image/math/fixed"
+)
+
+// fillAlpha 是 Draw 绘制矩形时填充色的不透明度，叠加在图集上时仍能看到原图
+const fillAlpha = 0x80
+
+// Image 把场景绘制为白色背景的图像，尺寸为容器尺寸乘以缩放比例
+func Image(scene Scene, opts *Options) *image.NRGBA {
+	s := opts.scale()
+	img := image.NewNRGBA(image.Rect(0, 0, px(scene.Bin.Width, s), px(scene.Bin.Height, s)))
+	draw.Draw(img, img.Bounds(), image.White, image.Point{}, draw.Src)
+	Draw(img, scene, opts)
+	return img
+}
+
+// Draw 把场景叠加绘制到 dst 上，矩形使用半透明的填充色，可用于在图集上绘制调试信息
+func Draw(dst draw.Image, scene Scene, opts *Options) {
+	s := opts.scale()
+	for _, rect := range scene.Rects {
+		for _, band := range paddingBands(rect, scene.Padding) {
+			fill(dst, scaled(band.X, band.Y, band.Width, band.Height, s), paddingColor)
+		}
+	}
+	for _, rect := range scene.Rects {
+		r := scaled(rect.X, rect.Y, rect.Width, rect.Height, s)
+		c := Color(rect.ID)
+		c.A = fillAlpha
+		fill(dst, r, c)
+		outline(dst, r, binColor)
+		if scene.Rotated[rect.ID] {
+			marker(dst, r)
+		}
+		text(dst, r, opts.label(rect.ID))
+	}
+	if opts.showFree() {
+		for _, rect := range scene.Free {
+			outline(dst, scaled(rect.X, rect.Y, rect.Width, rect.Height, s), freeColor)
+		}
+	}
+	outline(dst, image.Rect(0, 0, px(scene.Bin.Width, s), px(scene.Bin.Height, s)), binColor)
+}
+
+// px 把长度按缩放比例转换为像素
+func px(v int, s float64) int {
+	return int(math.Round(float64(v) * s))
+}
+
+// scaled 返回按缩放比例转换后的像素矩形
+func scaled(x, y, w, h int, s float64) image.Rectangle {
+	return image.Rect(px(x, s), px(y, s), px(x+w, s), px(y+h, s))
+}
+
+// fill 用颜色 c 混合填充矩形 r
+func fill(dst draw.Image, r image.Rectangle, c color.Color) {
+	draw.Draw(dst, r, image.NewUniform(c), image.Point{}, draw.Over)
+}
+
+// outline 绘制矩形 r 的1像素边框
+func outline(dst draw.Image, r image.Rectangle, c color.Color) {
+	if r.Empty() {
+		return
+	}
+	fill(dst, image.Rect(r.Min.X, r.Min.Y, r.Max.X, r.Min.Y+1), c)
+	fill(dst, image.Rect(r.Min.X, r.Max.Y-1, r.Max.X, r.Max.Y), c)
+	fill(dst, image.Rect(r.Min.X, r.Min.Y+1, r.Min.X+1, r.Max.Y-1), c)
+	fill(dst, image.Rect(r.Max.X-1, r.Min.Y+1, r.Max.X, r.Max.Y-1), c)
+}
+
+// markerSize 返回旋转标记的边长，不超过矩形短边的三分之一
+func markerSize(w, h, limit int) int {
+	return min(limit, w/3, h/3)
+}
+
+// marker 在矩形 r 的左上角绘制表示旋转的三角形
+func marker(dst draw.Image, r image.Rectangle) {
+	size := markerSize(r.Dx(), r.Dy(), 10)
+	for y := 0; y < size; y++ {
+		fill(dst, image.Rect(r.Min.X, r.Min.Y+y, r.Min.X+size-y, r.Min.Y+y+1), markerColor)
+	}
+}
+
+// text 在矩形 r 的中央绘制标签，放不下的字符被截去
+func text(dst draw.Image, r image.Rectangle, label string) {
+	face := basicfont.Face7x13
+	if r.Dy() < face.Height+2 {
+		return
+	}
+	chars := []rune(label)
+	if n := (r.Dx() - 4) / face.Advance; n < len(chars) {
+		chars = chars[:max(n, 0)]
+	}
+	if len(chars) == 0 {
+		return
+	}
+	width := len(chars) * face.Advance
+	drawer := font.Drawer{
+		Dst:  dst,
+		Src:  image.Black,
+		Face: face,
+		Dot:  fixed.P(r.Min.X+(r.Dx()-width)/2, r.Min.Y+(r.Dy()+face.Ascent-face.Descent)/2),
+	}
+	drawer.DrawString(string(chars))
+}
@@ -0,0 +1,119 @@
+// Package render 把打包结果绘制为图像或 SVG，用于调试和展示布局
+//
+// 绘制内容包括容器边框、带 ID 标签的已放置矩形、旋转标记(左上角的三角形)、
+// 间距区域，以及可选的剩余矩形。矩形的颜色由 ID 决定，同一布局每次绘制的结果相同。
+package render
+
+import (
+	"image/color"
+	"math"
+	"strconv"
+
+	"rectpack2d/rectpack"
+)
+
+// Scene 是要绘制的一个容器的布局
+type Scene struct {
+	// Bin 是容器尺寸
+	Bin rectpack.Size2D
+	// Rects 是已放置的矩形
+	Rects []rectpack.Rect2D
+	// Rotated 记录每个 ID 是否被旋转
+	Rotated map[int]bool
+	// Padding 是矩形之间的间距，每个矩形左侧和上方的间距区域会被绘制出来
+	Padding int
+	// Free 是剩余矩形，只在 Options.ShowFree 为 true 时绘制
+	Free []rectpack.Rect2D
+}
+
+// FromPacker 返回包装器当前布局的场景，容器尺寸为包装器的最大尺寸
+func FromPacker(p *rectpack.Packer) Scene {
+	return Scene{
+		Bin:     p.MaxSize(),
+		Rects:   p.GetPackedRects(),
+		Rotated: p.GetIdMapRotated(),
+		Padding: p.Padding(),
+		Free:    p.FreeRects(),
+	}
+}
+
+// Options 是绘制选项
+type Options struct {
+	// Scale 是每单位长度对应的像素数，0 表示1
+	Scale float64
+	// ShowFree 为 true 时绘制剩余矩形的边框
+	ShowFree bool
+	// Label 返回矩形的标签，nil 时显示 ID
+	Label func(id int) string
+}
+
+// scale 返回缩放比例
+func (o *Options) scale() float64 {
+	if o == nil || o.Scale <= 0 {
+		return 1
+	}
+	return o.Scale
+}
+
+// label 返回矩形的标签
+func (o *Options) label(id int) string {
+	if o == nil || o.Label == nil {
+		return strconv.Itoa(id)
+	}
+	return o.Label(id)
+}
+
+// showFree 判断是否绘制剩余矩形
+func (o *Options) showFree() bool {
+	return o != nil && o.ShowFree
+}
+
+var (
+	binColor     = color.NRGBA{0x33, 0x33, 0x33, 0xff}
+	paddingColor = color.NRGBA{0x99, 0x99, 0x99, 0x60}
+	freeColor    = color.NRGBA{0xe0, 0x30, 0x30, 0xc0}
+	markerColor  = color.NRGBA{0x00, 0x00, 0x00, 0xc0}
+)
+
+// paddingBands 返回矩形的间距区域：放置时间距加在矩形的左侧和上方
+func paddingBands(rect rectpack.Rect2D, padding int) []rectpack.Rect2D {
+	if padding <= 0 {
+		return nil
+	}
+	return []rectpack.Rect2D{
+		rectpack.NewRect(rect.X-padding, rect.Y-padding, rect.Width+padding, padding),
+		rectpack.NewRect(rect.X-padding, rect.Y, padding, rect.Height),
+	}
+}
+
+// Color 返回 ID 对应的填充颜色，色相按黄金分割均匀分布，相邻 ID 的颜色差别明显
+func Color(id int) color.NRGBA {
+	_, hue := math.Modf(float64(id) * 0.6180339887498949)
+	if hue < 0 {
+		hue++
+	}
+	return hsv(hue*360, 0.55, 0.92)
+}
+
+// hsv 把 HSV 颜色转换为不透明的 RGB 颜色
+func hsv(h, s, v float64) color.NRGBA {
+	c := v * s
+	x := c * (1 - math.Abs(math.Mod(h/60, 2)-1))
+	m := v - c
+	var r, g, b float64
+	switch {
+	case h < 60:
+		r, g, b = c, x, 0
+	case h < 120:
+		r, g, b = x, c, 0
+	case h < 180:
+		r, g, b = 0, c, x
+	case h < 240:
+		r, g, b = 0, x, c
+	case h < 300:
+		r, g, b = x, 0, c
+	default:
+		r, g, b = c, 0, x
+	}
+	return color.NRGBA{uint8((r + m) * 255), uint8((g + m) * 255), uint8((b + m) * 255), 0xff}
+}
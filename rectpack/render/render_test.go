@@ -0,0 +1,85 @@
+package render
+
+import (
+	"bytes"
+	"image/color"
+	"strings"
+	"testing"
+
+	"rectpack2d/rectpack"
+)
+
+// testScene 打包几个矩形，第一个必须旋转才能放下
+func testScene(t *testing.T) Scene {
+	packer, err := rectpack.NewPacker(100, 80, rectpack.MaxRectsBSSF)
+	if err != nil {
+		t.Fatal(err)
+	}
+	packer.AllowRotate(true)
+	packer.SetPadding(2)
+	packer.Insert(rectpack.NewSize2DByID(7, 40, 90), rectpack.NewSize2DByID(8, 30, 30))
+	if !packer.Pack() {
+		t.Fatal("test layout does not fit")
+	}
+	return FromPacker(packer)
+}
+
+func TestImage(t *testing.T) {
+	scene := testScene(t)
+	img := Image(scene, &Options{Scale: 2})
+	if b := img.Bounds(); b.Dx() != 200 || b.Dy() != 160 {
+		t.Fatalf("image size %v, want 200x160", b)
+	}
+	if !bytes.Equal(img.Pix, Image(scene, &Options{Scale: 2}).Pix) {
+		t.Fatal("rendering is not deterministic")
+	}
+	for _, rect := range scene.Rects {
+		// 矩形内部靠近右下角的像素为 ID 颜色与白色背景的混合
+		got := img.NRGBAAt(2*(rect.X+rect.Width)-3, 2*(rect.Y+rect.Height)-3)
+		want := Color(rect.ID)
+		if got == (color.NRGBA{0xff, 0xff, 0xff, 0xff}) || got.R < want.R || got.G < want.G || got.B < want.B {
+			t.Errorf("rect %d: pixel %v is not a tint of %v", rect.ID, got, want)
+		}
+		marked := img.NRGBAAt(2*rect.X+1, 2*rect.Y+1).R < 0x80
+		if marked != scene.Rotated[rect.ID] {
+			t.Errorf("rect %d: rotation marker %v, rotated %v", rect.ID, marked, scene.Rotated[rect.ID])
+		}
+	}
+	if Color(7) == Color(8) {
+		t.Fatal("adjacent IDs share a colour")
+	}
+}
+
+func TestSVG(t *testing.T) {
+	scene := testScene(t)
+	var buf bytes.Buffer
+	opts := &Options{ShowFree: true, Label: func(id int) string { return "<" + string(rune('a'+id)) + ">" }}
+	if err := SVG(&buf, scene, opts); err != nil {
+		t.Fatal(err)
+	}
+	svg := buf.String()
+	for _, want := range []string{`viewBox="0 0 100 80"`, "&lt;h&gt;", "&lt;i&gt;", "<polygon", `class="padding"`, `class="free"`} {
+		if !strings.Contains(svg, want) {
+			t.Errorf("svg does not contain %q", want)
+		}
+	}
+}
+
+func TestTraceHTML(t *testing.T) {
+	packer, _ := rectpack.NewPacker(64, 64, rectpack.GuillotineBAF)
+	var trace rectpack.Trace
+	packer.SetTracer(&trace)
+	packer.Insert(rectpack.NewSize2DByID(1, 30, 20), rectpack.NewSize2DByID(2, 20, 20))
+	packer.Pack()
+
+	var buf bytes.Buffer
+	if err := TraceHTML(&buf, &trace, "a < b"); err != nil {
+		t.Fatal(err)
+	}
+	html := buf.String()
+	for _, want := range []string{"<title>a &lt; b</title>", `const trace = {"runs":[{"bin":{`, `"candidates":[`} {
+		if !strings.Contains(html, want) {
+			t.Errorf("html does not contain %q", want)
+		}
+	}
+}
@@ -0,0 +1,72 @@
+package render
+
+import (
+	"bufio"
+	"encoding/xml"
+	"fmt"
+	"image/color"
+	"io"
+	"strings"
+)
+
+// SVG 把场景写为 SVG，坐标使用布局的单位长度，宽高为容器尺寸乘以缩放比例
+func SVG(w io.Writer, scene Scene, opts *Options) error {
+	s := opts.scale()
+	bw := bufio.NewWriter(w)
+	fmt.Fprintf(bw, "<svg xmlns=\"http://www.w3.org/2000/svg\" width=\"%d\" height=\"%d\" viewBox=\"0 0 %d %d\">\n",
+		px(scene.Bin.Width, s), px(scene.Bin.Height, s), scene.Bin.Width, scene.Bin.Height)
+	fmt.Fprintf(bw, "<rect x=\"0\" y=\"0\" width=\"%d\" height=\"%d\" fill=\"#fff\"/>\n", scene.Bin.Width, scene.Bin.Height)
+	if scene.Padding > 0 {
+		bw.WriteString("<g class=\"padding\">\n")
+		for _, rect := range scene.Rects {
+			for _, band := range paddingBands(rect, scene.Padding) {
+				fmt.Fprintf(bw, "<rect x=\"%d\" y=\"%d\" width=\"%d\" height=\"%d\" fill=\"%s\"/>\n",
+					band.X, band.Y, band.Width, band.Height, svgColor(paddingColor))
+			}
+		}
+		bw.WriteString("</g>\n")
+	}
+	bw.WriteString("<g class=\"rects\" stroke=\"#333\" stroke-width=\"1\" vector-effect=\"non-scaling-stroke\">\n")
+	for _, rect := range scene.Rects {
+		label := svgEscape(opts.label(rect.ID))
+		fmt.Fprintf(bw, "<rect x=\"%d\" y=\"%d\" width=\"%d\" height=\"%d\" fill=\"%s\"><title>%s</title></rect>\n",
+			rect.X, rect.Y, rect.Width, rect.Height, svgColor(Color(rect.ID)), label)
+		if scene.Rotated[rect.ID] {
+			if size := markerSize(rect.Width, rect.Height, max(scene.Bin.Width, scene.Bin.Height)/40); size > 0 {
+				fmt.Fprintf(bw, "<polygon points=\"%d,%d %d,%d %d,%d\" fill=\"%s\" stroke=\"none\"/>\n",
+					rect.X, rect.Y, rect.X+size, rect.Y, rect.X, rect.Y+size, svgColor(markerColor))
+			}
+		}
+		if size := min(rect.Width, rect.Height) / 3; size > 0 {
+			fmt.Fprintf(bw, "<text x=\"%d\" y=\"%d\" font-size=\"%d\" text-anchor=\"middle\" dominant-baseline=\"central\" stroke=\"none\">%s</text>\n",
+				rect.X+rect.Width/2, rect.Y+rect.Height/2, size, label)
+		}
+	}
+	bw.WriteString("</g>\n")
+	if opts.showFree() && len(scene.Free) > 0 {
+		fmt.Fprintf(bw, "<g class=\"free\" fill=\"none\" stroke=\"%s\" stroke-dasharray=\"4 2\" vector-effect=\"non-scaling-stroke\">\n", svgColor(freeColor))
+		for _, rect := range scene.Free {
+			fmt.Fprintf(bw, "<rect x=\"%d\" y=\"%d\" width=\"%d\" height=\"%d\"/>\n", rect.X, rect.Y, rect.Width, rect.Height)
+		}
+		bw.WriteString("</g>\n")
+	}
+	fmt.Fprintf(bw, "<rect x=\"0\" y=\"0\" width=\"%d\" height=\"%d\" fill=\"none\" stroke=\"%s\" vector-effect=\"non-scaling-stroke\"/>\n",
+		scene.Bin.Width, scene.Bin.Height, svgColor(binColor))
+	bw.WriteString("</svg>\n")
+	return bw.Flush()
+}
+
+// svgColor 返回 SVG 的颜色值，带透明度时使用 rgba()
+func svgColor(c color.NRGBA) string {
+	if c.A == 0xff {
+		return fmt.Sprintf("#%02x%02x%02x", c.R, c.G, c.B)
+	}
+	return fmt.Sprintf("rgba(%d,%d,%d,%.2f)", c.R, c.G, c.B, float64(c.A)/255)
+}
+
+// svgEscape 转义 SVG 文本中的特殊字符
+func svgEscape(s string) string {
+	var buf strings.Builder
+	xml.EscapeText(&buf, []byte(s))
+	return buf.String()
+}
@@ -0,0 +1,181 @@
+package render
+
+import (
+	"html/template"
+	"io"
+
+	"rectpack2d/rectpack"
+)
+
+// traceTemplate 是打包过程动画的页面，数据以 JSON 嵌入，不依赖外部资源
+var traceTemplate = template.Must(template.New("trace").Parse(`<!DOCTYPE html>
+<html>
+<head>
+  <meta charset="UTF-8">
+  <title>{{.Title}}</title>
+  <style>
+    body { font-family: sans-serif; margin: 16px; }
+    canvas { border: 1px solid #ccc; background: #fff; }
+    #main { display: flex; gap: 16px; align-items: flex-start; }
+    #controls { margin-bottom: 8px; display: flex; gap: 8px; align-items: center; flex-wrap: wrap; }
+    #step { width: 320px; }
+    #info { font-size: 13px; max-height: 800px; overflow-y: auto; }
+    table { border-collapse: collapse; }
+    td, th { border: 1px solid #ddd; padding: 2px 6px; text-align: right; }
+    tr.chosen { background: #ffe08a; font-weight: bold; }
+    tr:hover { background: #def; }
+  </style>
+</head>
+<body>
+  <h3>{{.Title}}</h3>
+  <div id="controls">
+    <select id="run"></select>
+    <button id="prev">上一步</button>
+    <button id="play">播放</button>
+    <button id="next">下一步</button>
+    <input id="step" type="range" min="0" value="0">
+    <span id="label"></span>
+    <label><input id="after" type="checkbox">显示分割和剪枝后的剩余矩形</label>
+  </div>
+  <div id="main">
+    <canvas id="canvas" width="800" height="800"></canvas>
+    <div id="info"></div>
+  </div>
+  <script>
+    const trace = {{.Trace}};
+    const runs = trace.runs || [];
+    const canvas = document.getElementById("canvas");
+    const ctx = canvas.getContext("2d");
+    const runSelect = document.getElementById("run");
+    const slider = document.getElementById("step");
+    const label = document.getElementById("label");
+    const after = document.getElementById("after");
+    const info = document.getElementById("info");
+    const maxCandidates = 100;
+    let run = null, hover = null, timer = null;
+
+    // 与 render.Color 相同：色相按黄金分割分布
+    function color(id, alpha) {
+      let h = (id * 0.6180339887498949) % 1;
+      if (h < 0) h += 1;
+      h *= 360;
+      const s = 0.55, v = 0.92, c = v * s, x = c * (1 - Math.abs((h / 60) % 2 - 1)), m = v - c;
+      const [r, g, b] = h < 60 ? [c, x, 0] : h < 120 ? [x, c, 0] : h < 180 ? [0, c, x] : h < 240 ? [0, x, c] : h < 300 ? [x, 0, c] : [c, 0, x];
+      return "rgba(" + Math.floor((r + m) * 255) + "," + Math.floor((g + m) * 255) + "," + Math.floor((b + m) * 255) + "," + alpha + ")";
+    }
+
+    function chosen(step, c) {
+      if (step.item < 0 || c.item !== step.item || c.rotated !== step.rotated) return false;
+      const f = step.freeRects[c.free];
+      return f.X <= step.placed.X && f.Y <= step.placed.Y && f.X + f.Width >= step.placed.X + step.placed.Width && f.Y + f.Height >= step.placed.Y + step.placed.Height;
+    }
+
+    function selectRun(i) {
+      run = runs[i];
+      slider.max = Math.max(run.steps.length - 1, 0);
+      slider.value = 0;
+      const scale = Math.min(800 / run.bin.Width, 800 / run.bin.Height);
+      canvas.width = Math.ceil(run.bin.Width * scale);
+      canvas.height = Math.ceil(run.bin.Height * scale);
+      ctx.setTransform(scale, 0, 0, scale, 0, 0);
+      ctx.lineWidth = 1 / scale;
+      draw();
+    }
+
+    function drawRect(r, fill, stroke, dash) {
+      if (fill) { ctx.fillStyle = fill; ctx.fillRect(r.X, r.Y, r.Width, r.Height); }
+      if (stroke) {
+        ctx.strokeStyle = stroke;
+        ctx.setLineDash(dash ? [4 * ctx.lineWidth, 2 * ctx.lineWidth] : []);
+        ctx.strokeRect(r.X, r.Y, r.Width, r.Height);
+      }
+    }
+
+    function draw() {
+      ctx.clearRect(0, 0, run.bin.Width, run.bin.Height);
+      const k = +slider.value;
+      const step = run.steps[k];
+      for (let i = 0; i < k; i++) {
+        const s = run.steps[i];
+        if (s.item >= 0) drawRect(s.placed, color(s.placed.ID, 0.8), "#333");
+      }
+      if (!step) { label.textContent = "没有记录"; info.innerHTML = ""; return; }
+      const free = (after.checked ? step.freeAfter : step.freeRects) || [];
+      free.forEach(r => drawRect(r, "rgba(224,48,48,0.04)", "rgba(224,48,48,0.8)", true));
+      if (step.item >= 0) {
+        drawRect(step.placed, color(step.placed.ID, 0.9), "#000");
+        ctx.save(); ctx.lineWidth *= 3; drawRect(step.placed, null, "#000"); ctx.restore();
+      }
+      if (hover) {
+        const f = step.freeRects[hover.free];
+        ctx.save(); ctx.lineWidth *= 3;
+        drawRect(f, "rgba(0,120,255,0.1)", "#0078ff", true);
+        drawRect({X: f.X, Y: f.Y, Width: hover.width, Height: hover.height}, "rgba(0,120,255,0.3)", "#0078ff");
+        ctx.restore();
+      }
+      label.textContent = "第 " + (k + 1) + " / " + run.steps.length + " 步" +
+        (step.item >= 0 ? "，放置 ID " + step.placed.ID + (step.rotated ? " (旋转)" : "") + " 于 (" + step.placed.X + ", " + step.placed.Y + ")" : "，剩余尺寸都放不下");
+      if (!hover) showCandidates(step);
+    }
+
+    function showCandidates(step) {
+      const candidates = (step.candidates || []).slice().sort((a, b) => a.score1 - b.score1 || a.score2 - b.score2);
+      let html = "<p>剩余矩形: " + (step.freeRects || []).length + " → " + (step.freeAfter || []).length +
+        "，候选位置: " + candidates.length + (candidates.length > maxCandidates ? "(显示评分最优的 " + maxCandidates + " 个)" : "") + "</p>";
+      html += "<table><tr><th>ID</th><th>剩余矩形</th><th>尺寸</th><th>旋转</th><th>评分1</th><th>评分2</th><th>完全匹配</th></tr>";
+      candidates.slice(0, maxCandidates).forEach((c, i) => {
+        html += "<tr data-i='" + i + "'" + (chosen(step, c) ? " class='chosen'" : "") + "><td>" + c.id + "</td><td>#" + c.free + "</td><td>" +
+          c.width + "x" + c.height + "</td><td>" + (c.rotated ? "是" : "") + "</td><td>" + c.score1 + "</td><td>" + c.score2 + "</td><td>" + (c.exact ? "是" : "") + "</td></tr>";
+      });
+      info.innerHTML = html + "</table>";
+      info.querySelectorAll("tr[data-i]").forEach(row => {
+        row.onmouseenter = () => { hover = candidates[+row.dataset.i]; draw(); };
+        row.onmouseleave = () => { hover = null; draw(); };
+      });
+    }
+
+    function go(delta) {
+      slider.value = Math.min(Math.max(+slider.value + delta, 0), +slider.max);
+      hover = null;
+      draw();
+    }
+
+    runs.forEach((r, i) => {
+      const option = document.createElement("option");
+      option.value = i;
+      option.textContent = "运行 " + (i + 1) + ": " + r.bin.Width + "x" + r.bin.Height + "，" + r.steps.length + " 步";
+      runSelect.appendChild(option);
+    });
+    runSelect.onchange = () => selectRun(+runSelect.value);
+    slider.oninput = () => { hover = null; draw(); };
+    after.onchange = draw;
+    document.getElementById("prev").onclick = () => go(-1);
+    document.getElementById("next").onclick = () => go(1);
+    document.getElementById("play").onclick = function () {
+      if (timer) { clearInterval(timer); timer = null; this.textContent = "播放"; return; }
+      if (+slider.value >= +slider.max) slider.value = 0;
+      this.textContent = "暂停";
+      timer = setInterval(() => {
+        if (+slider.value >= +slider.max) { this.click(); return; }
+        go(1);
+      }, 300);
+    };
+    if (runs.length > 0) {
+      runSelect.value = runs.length - 1;
+      selectRun(runs.length - 1);
+    }
+  </script>
+</body>
+</html>
+`))
+
+// TraceHTML 把打包过程的记录写为独立的 HTML 页面，以动画逐步展示每次放置
+//
+// 页面显示已放置的矩形、选择前(或分割和剪枝后)的剩余矩形和本步放置的位置，
+// 并按评分列出候选位置，鼠标悬停在候选上时在画布中标出。记录包含多次运行时可以切换，默认显示最后一次。
+func TraceHTML(w io.Writer, trace *rectpack.Trace, title string) error {
+	return traceTemplate.Execute(w, struct {
+		Title string
+		Trace *rectpack.Trace
+	}{title, trace})
+}
@@ -0,0 +1,77 @@
+package rectpack
+
+import (
+	"fmt"
+	"math"
+	"math/rand/v2"
+	"slices"
+	"testing"
+)
+
+// scaleInstance 生成 n 个随机尺寸和一个面积约为其总面积 1.1 倍的正方形容器，
+// 宽高为 16 到 128 之间 4 的倍数，与图集中大量重复的图块相近
+func scaleInstance(n int) (Size2D, []Size2D) {
+	r := rand.New(rand.NewPCG(uint64(n), 37))
+	sizes := make([]Size2D, n)
+	area := 0
+	for i := range sizes {
+		sizes[i] = NewSize2DByID(i, 16+4*r.IntN(29), 16+4*r.IntN(29))
+		area += sizes[i].Area()
+	}
+	side := int(math.Sqrt(float64(area) * 1.1))
+	return NewSize2D(side, side), sizes
+}
+
+func BenchmarkMaxRectsScale(b *testing.B) {
+	for _, n := range []int{1000, 10000, 100000} {
+		bin, sizes := scaleInstance(n)
+		for _, heuristic := range []Heuristic{MaxRectsBSSF, MaxRectsBAF, MaxRectsBL} {
+			b.Run(fmt.Sprintf("%v/%d", heuristic, n), func(b *testing.B) {
+				for i := 0; i < b.N; i++ {
+					packer, _ := NewPacker(bin.Width, bin.Height, heuristic)
+					packer.AllowRotate(true)
+					packer.Insert(sizes...)
+					packer.Pack()
+					b.ReportMetric(float64(len(packer.algo.getFreeRects())), "free")
+				}
+			})
+		}
+	}
+}
+
+// TestMaxRectsScaleIdentity 检查评分缓存和网格索引不改变布局：
+// 对照组每一步都清空缓存并停用索引，即每个尺寸都完整扫描所有剩余矩形
+func TestMaxRectsScaleIdentity(t *testing.T) {
+	// 尺寸种类较少，使完整扫描足够快，同时剩余矩形足够多以启用网格索引
+	r := rand.New(rand.NewPCG(7, 37))
+	sizes := make([]Size2D, 2000)
+	for i := range sizes {
+		sizes[i] = NewSize2DByID(i, 8+7*r.IntN(6), 8+5*r.IntN(6))
+	}
+	bin := NewSize2D(1100, 1100)
+	for _, heuristic := range []Heuristic{MaxRectsBSSF, MaxRectsBLSF, MaxRectsBAF, MaxRectsBL} {
+		for _, padding := range []int{0, 2} {
+			fast := newMaxRects(bin.Width, bin.Height, heuristic)
+			fast.AllowRotate(true)
+			failed := fast.Insert(padding, sizes...)
+
+			slow := newMaxRects(bin.Width, bin.Height, heuristic)
+			slow.AllowRotate(true)
+			slowFailed := slow.insertClasses(padding, sizes, func(padding int, sizes []Size2D) int {
+				slow.cache = slow.cache[:0]
+				slow.grid.reset()
+				return slow.insertBest(padding, sizes)
+			}, false)
+
+			if !fast.grid.enabled {
+				t.Fatalf("%v padding %d: free rects never reached the grid threshold", heuristic, padding)
+			}
+			if !slices.Equal(fast.packed, slow.packed) || len(failed) != len(slowFailed) {
+				t.Fatalf("%v padding %d: cached layout differs from full scan", heuristic, padding)
+			}
+			if !slices.Equal(fast.freeRects, slow.freeRects) {
+				t.Fatalf("%v padding %d: free rects differ from full scan", heuristic, padding)
+			}
+		}
+	}
+}
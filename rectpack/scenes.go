@@ -0,0 +1,236 @@
+package rectpack
+
+import (
+	"cmp"
+	"slices"
+)
+
+// Scene 是同时使用的一组矩形，例如一个界面或关卡用到的精灵
+type Scene struct {
+	Name string
+	// IDs 是该场景用到的矩形ID，同一个ID可以属于多个场景
+	IDs []int
+	// Weight 是该场景的权重，例如出现的频率，小于等于0时为1
+	Weight float64
+}
+
+// SceneUsage 是一个场景的矩形在页面上的分布
+type SceneUsage struct {
+	Name string
+	// Pages 是该场景用到的页面下标(升序)
+	Pages []int
+	// DrawCalls 是绘制该场景估计需要的绘制调用次数，每个用到的页面(纹理)一次
+	DrawCalls int
+	// Weight 是该场景的有效权重，Scene.Weight 小于等于0时为1
+	Weight float64
+}
+
+// PackScenes 把暂存的矩形打包到多个最大尺寸的页面中，使每个场景用到的页面尽量少
+//
+// 逐页填充：新页面先放入权重最大的场景的全部剩余矩形，之后优先补全已有矩形在本页的场景，
+// 其次按权重从大到小加入能整体放下的场景；没有场景能再整体放下时，用不属于任何场景的矩形
+// 填满剩余空间，再打开下一页。空页面也放不下一个场景时，场景按能放下的部分拆分到多页。
+// 每一页使用与当前包装器相同的算法和配置。
+// 返回:
+//
+//	打开的页面；true: 全部打包成功 false: 部分失败(可通过GetUnpackedRects获取放不进空页面的尺寸)
+func (p *Packer) PackScenes(scenes []Scene) ([]Page, bool) {
+	bin := p.algo.MaxSize()
+	binType := BinType{Width: bin.Width, Height: bin.Height, Cost: float64(bin.Width * bin.Height)}
+	pack := func(sizes []Size2D) *Packer {
+		sub := p.newSubPacker(bin.Width, bin.Height)
+		sub.Hierarchical = p.Hierarchical
+		sub.Insert(sizes...)
+		sub.Pack()
+		return sub
+	}
+
+	// pending 为尚未分配页面的尺寸，放不进空页面的尺寸直接作为失败
+	pending := make(map[int]Size2D, len(p.unpackedSize2Ds))
+	sizeOf := make(map[int]Size2D, len(p.unpackedSize2Ds))
+	var order, unpacked []Size2D
+	for _, size := range p.unpackedSize2Ds {
+		padded := size
+		padSize(&padded, p.padding)
+		if !fitsIn(padded, bin, p.allowRotate && !size.NoRotate) {
+			unpacked = append(unpacked, size)
+			continue
+		}
+		pending[size.ID] = size
+		sizeOf[size.ID] = size
+		order = append(order, size)
+	}
+	inScene := make(map[int]bool)
+	for _, scene := range scenes {
+		for _, id := range scene.IDs {
+			inScene[id] = true
+		}
+	}
+	// rest 返回场景中尚未分配页面的尺寸
+	rest := func(scene *Scene) []Size2D {
+		var sizes []Size2D
+		for _, id := range scene.IDs {
+			if size, ok := pending[id]; ok && !slices.ContainsFunc(sizes, func(s Size2D) bool { return s.ID == id }) {
+				sizes = append(sizes, size)
+			}
+		}
+		return sizes
+	}
+	take := func(packer *Packer) {
+		for _, rect := range packer.GetPackedRects() {
+			delete(pending, rect.ID)
+		}
+	}
+
+	var pages []Page
+	for len(pending) > 0 {
+		var members []Size2D // 当前页的尺寸
+		var packer *Packer
+		onPage := make(map[int]bool)
+		skipped := make([]bool, len(scenes)) // 本页放不下剩余部分的场景
+		for {
+			best := nextScene(scenes, skipped, onPage, rest)
+			if best < 0 {
+				break
+			}
+			sizes := rest(&scenes[best])
+			trial := pack(slices.Concat(members, sizes))
+			if len(trial.GetUnpackedRects()) > 0 && len(members) > 0 {
+				skipped[best] = true
+				continue
+			}
+			// 空页面放不下整个场景时保留能放下的部分，其余留给之后的页面
+			packer = trial
+			members = members[:0]
+			for _, rect := range trial.GetPackedRects() {
+				// 已放置的矩形可能旋转过，按原始尺寸记录
+				members = append(members, sizeOf[rect.ID])
+				onPage[rect.ID] = true
+			}
+			take(trial)
+			skipped[best] = len(trial.GetUnpackedRects()) > 0
+		}
+
+		// 用不属于任何场景的尺寸填满剩余空间
+		var loose []Size2D
+		for _, size := range order {
+			if _, ok := pending[size.ID]; ok && !inScene[size.ID] {
+				loose = append(loose, size)
+			}
+		}
+		if len(loose) > 0 {
+			trial := pack(slices.Concat(members, loose))
+			if trial.placedAll(members) {
+				packer = trial
+			} else {
+				// 一起排序打包时本页原有的尺寸放不下，改为逐个尝试
+				for _, size := range loose {
+					if trial := pack(append(slices.Clone(members), size)); len(trial.GetUnpackedRects()) == 0 {
+						packer = trial
+						members = append(members, size)
+					}
+				}
+			}
+		}
+		if packer == nil || len(packer.GetPackedRects()) == 0 {
+			break
+		}
+		take(packer)
+		pages = append(pages, Page{Bin: binType, Packer: packer})
+	}
+	for _, size := range order {
+		if _, ok := pending[size.ID]; ok {
+			unpacked = append(unpacked, size)
+		}
+	}
+	p.unpackedSize2Ds = unpacked
+	return pages, len(unpacked) == 0
+}
+
+// nextScene 选择下一个加入当前页的场景，没有时返回 -1
+//
+// 已有矩形在本页的场景优先，其次权重大的，再次剩余矩形总面积大的。
+func nextScene(scenes []Scene, skipped []bool, onPage map[int]bool, rest func(*Scene) []Size2D) int {
+	type key struct {
+		touching bool
+		weight   float64
+		area     int
+	}
+	best, bestKey := -1, key{}
+	for i := range scenes {
+		if skipped[i] {
+			continue
+		}
+		sizes := rest(&scenes[i])
+		if len(sizes) == 0 {
+			continue
+		}
+		k := key{weight: sceneWeight(&scenes[i])}
+		for _, id := range scenes[i].IDs {
+			k.touching = k.touching || onPage[id]
+		}
+		for _, size := range sizes {
+			k.area += size.Area()
+		}
+		if best < 0 || cmp.Or(compareBool(k.touching, bestKey.touching), cmp.Compare(k.weight, bestKey.weight), cmp.Compare(k.area, bestKey.area)) > 0 {
+			best, bestKey = i, k
+		}
+	}
+	return best
+}
+
+// placedAll 判断 sizes 是否全部已放置
+func (p *Packer) placedAll(sizes []Size2D) bool {
+	placed := make(map[int]bool, len(p.GetPackedRects()))
+	for _, rect := range p.GetPackedRects() {
+		placed[rect.ID] = true
+	}
+	for _, size := range sizes {
+		if !placed[size.ID] {
+			return false
+		}
+	}
+	return true
+}
+
+// SceneUsages 统计每个场景的矩形分布在哪些页面上，以及估计的绘制调用次数
+func SceneUsages(scenes []Scene, pages []Page) []SceneUsage {
+	pageOf := make(map[int]int)
+	for i, page := range pages {
+		for _, rect := range page.Packer.GetPackedRects() {
+			pageOf[rect.ID] = i
+		}
+	}
+	usages := make([]SceneUsage, len(scenes))
+	for i, scene := range scenes {
+		usages[i].Name = scene.Name
+		usages[i].Weight = sceneWeight(&scenes[i])
+		for _, id := range scene.IDs {
+			if page, ok := pageOf[id]; ok && !slices.Contains(usages[i].Pages, page) {
+				usages[i].Pages = append(usages[i].Pages, page)
+			}
+		}
+		slices.Sort(usages[i].Pages)
+		usages[i].DrawCalls = len(usages[i].Pages)
+	}
+	return usages
+}
+
+// sceneWeight 返回场景的有效权重
+func sceneWeight(scene *Scene) float64 {
+	if scene.Weight <= 0 {
+		return 1
+	}
+	return scene.Weight
+}
+
+// compareBool 比较两个布尔值，false 小于 true
+func compareBool(a, b bool) int {
+	switch {
+	case a == b:
+		return 0
+	case a:
+		return 1
+	}
+	return -1
+}
@@ -0,0 +1,291 @@
+package rectpack
+
+import (
+	"bytes"
+	"encoding/gob"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"reflect"
+)
+
+// packerStateVersion 是序列化格式的版本，格式不兼容地改变时递增
+const packerStateVersion = 1
+
+// sorterNames 是可以序列化的排序函数，自定义的排序函数序列化为 "custom"
+var sorterNames = []struct {
+	name string
+	sort SortFunc
+}{
+	{"area", SortArea},
+	{"perimeter", SortPerimeter},
+	{"diff", SortDiff},
+	{"minside", SortMinSide},
+	{"maxside", SortMaxSide},
+	{"ratio", SortRatio},
+}
+
+// packerState 是包装器序列化后的状态
+type packerState struct {
+	Version      int             `json:"version"`
+	Heuristic    Heuristic       `json:"heuristic"`
+	Sorter       string          `json:"sorter,omitempty"`
+	SortReverse  bool            `json:"sortReverse,omitempty"`
+	Padding      int             `json:"padding,omitempty"`
+	AllowRotate  bool            `json:"allowRotate,omitempty"`
+	Online       bool            `json:"online,omitempty"`
+	Hierarchical bool            `json:"hierarchical,omitempty"`
+	Lookahead    int             `json:"lookahead,omitempty"`
+	Unpacked     []Size2D        `json:"unpacked,omitempty"`
+	Pending      []Size2D        `json:"pending,omitempty"`
+	Algorithm    *algorithmState `json:"algorithm"`
+}
+
+// algorithmState 是包装算法序列化后的状态
+type algorithmState struct {
+	Width    int          `json:"width"`
+	Height   int          `json:"height"`
+	UsedArea int          `json:"usedArea"`
+	Packed   []Rect2D     `json:"packed,omitempty"`
+	Rotated  map[int]bool `json:"rotated,omitempty"`
+	// FreeRects 是剩余矩形，顺序影响之后的放置位置，必须原样保存
+	FreeRects []Rect2D `json:"freeRects,omitempty"`
+	// 以下只用于按顺序放置的基础算法：下一个矩形的位置和当前行的最大高度
+	CursorX   int `json:"cursorX,omitempty"`
+	CursorY   int `json:"cursorY,omitempty"`
+	RowHeight int `json:"rowHeight,omitempty"`
+	// 以下只用于 Guillotine
+	MergePolicy MergePolicy `json:"mergePolicy,omitempty"`
+	Stages      int         `json:"stages,omitempty"`
+	CutTree     *CutNode    `json:"cutTree,omitempty"`
+}
+
+// MarshalJSON 把包装器的完整状态编码为 JSON，包括容器尺寸、配置、已放置的矩形、
+// 旋转状态、剩余空间和暂存的尺寸，用 UnmarshalJSON 恢复后可以从中断处继续插入，
+// 结果与不中断完全一致。
+//
+// 自定义的排序函数无法编码，只记录为 "custom"，恢复后需要重新调用 SetSorter。
+func (p *Packer) MarshalJSON() ([]byte, error) {
+	return json.Marshal(p.state())
+}
+
+// UnmarshalJSON 从 MarshalJSON 的结果恢复包装器，p 可以是零值
+func (p *Packer) UnmarshalJSON(data []byte) error {
+	var state packerState
+	if err := json.Unmarshal(data, &state); err != nil {
+		return err
+	}
+	return p.restore(&state)
+}
+
+// MarshalBinary 把包装器的完整状态编码为二进制，内容与 MarshalJSON 相同但更紧凑
+func (p *Packer) MarshalBinary() ([]byte, error) {
+	var buf bytes.Buffer
+	if err := gob.NewEncoder(&buf).Encode(p.state()); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
+
+// UnmarshalBinary 从 MarshalBinary 的结果恢复包装器，p 可以是零值
+func (p *Packer) UnmarshalBinary(data []byte) error {
+	var state packerState
+	if err := gob.NewDecoder(bytes.NewReader(data)).Decode(&state); err != nil {
+		return err
+	}
+	return p.restore(&state)
+}
+
+// state 返回包装器当前的状态
+func (p *Packer) state() *packerState {
+	state := &packerState{
+		Version:      packerStateVersion,
+		Heuristic:    p.heuristic,
+		Sorter:       sorterName(p.sortFunc),
+		SortReverse:  p.sortRev,
+		Padding:      p.padding,
+		AllowRotate:  p.allowRotate,
+		Online:       p.Online,
+		Hierarchical: p.Hierarchical,
+		Lookahead:    p.Lookahead,
+		Unpacked:     p.unpackedSize2Ds,
+		Pending:      p.pending,
+		Algorithm:    &algorithmState{},
+	}
+	p.algo.saveState(state.Algorithm)
+	return state
+}
+
+// restore 用 state 替换包装器的状态，失败时包装器保持不变
+func (p *Packer) restore(state *packerState) error {
+	if state.Version != packerStateVersion {
+		return fmt.Errorf("unsupported packer state version %d", state.Version)
+	}
+	if state.Algorithm == nil || state.Algorithm.Width <= 0 || state.Algorithm.Height <= 0 {
+		return errors.New("packer state has no valid bin size")
+	}
+	sortFunc, ok := sorterFunc(state.Sorter, p.sortFunc)
+	if !ok {
+		return fmt.Errorf("unknown sorter %q", state.Sorter)
+	}
+	algo := newPackAlgorithm(state.Algorithm.Width, state.Algorithm.Height, state.Heuristic)
+	algo.AllowRotate(state.AllowRotate)
+	if err := algo.loadState(state.Algorithm); err != nil {
+		return err
+	}
+	p.algo = algo
+	p.heuristic = state.Heuristic
+	p.sortFunc = sortFunc
+	p.sortRev = state.SortReverse
+	p.padding = state.Padding
+	p.allowRotate = state.AllowRotate
+	p.Online = state.Online
+	p.Hierarchical = state.Hierarchical
+	p.Lookahead = state.Lookahead
+	p.unpackedSize2Ds = append(p.unpackedSize2Ds[:0], state.Unpacked...)
+	p.pending = append(p.pending[:0], state.Pending...)
+	// 恢复后的算法从新的状态开始记录
+	p.SetTracer(p.tracer)
+	return nil
+}
+
+// sorterName 返回排序函数的序列化名称，nil 为空字符串
+func sorterName(sort SortFunc) string {
+	if sort == nil {
+		return ""
+	}
+	pc := reflect.ValueOf(sort).Pointer()
+	for _, s := range sorterNames {
+		if reflect.ValueOf(s.sort).Pointer() == pc {
+			return s.name
+		}
+	}
+	return "custom"
+}
+
+// sorterFunc 返回名称对应的排序函数，"custom" 保留 current
+func sorterFunc(name string, current SortFunc) (SortFunc, bool) {
+	switch name {
+	case "":
+		return nil, true
+	case "custom":
+		return current, true
+	}
+	for _, s := range sorterNames {
+		if s.name == name {
+			return s.sort, true
+		}
+	}
+	return nil, false
+}
+
+// saveState 保存所有算法共有的状态
+func (p *algorithmBase) saveState(state *algorithmState) {
+	state.Width = p.maxWidth
+	state.Height = p.maxHeight
+	state.UsedArea = p.usedArea
+	state.Packed = p.packed
+	state.Rotated = p.idMapRotated
+	state.CursorX, state.CursorY, state.RowHeight = p.cursorX, p.cursorY, p.rowHeight
+}
+
+// loadState 恢复所有算法共有的状态，调用前算法已按 state 的尺寸重置
+func (p *algorithmBase) loadState(state *algorithmState) error {
+	p.usedArea = state.UsedArea
+	p.packed = append(p.packed[:0], state.Packed...)
+	if p.idMapRotated != nil || len(state.Rotated) > 0 {
+		if p.idMapRotated == nil {
+			p.idMapRotated = make(map[int]bool, len(state.Rotated))
+		}
+		for id, rotated := range state.Rotated {
+			p.idMapRotated[id] = rotated
+		}
+	}
+	p.cursorX, p.cursorY, p.rowHeight = state.CursorX, state.CursorY, state.RowHeight
+	if p.cursorX == 0 && p.cursorY == 0 && len(p.packed) > 0 {
+		// 没有记录放置位置的旧状态，从所有矩形下方继续
+		p.moveCursorBelow()
+	}
+	return nil
+}
+
+// saveState 保存剩余矩形
+func (p *maxRects) saveState(state *algorithmState) {
+	p.algorithmBase.saveState(state)
+	state.FreeRects = p.freeRects
+}
+
+// loadState 恢复剩余矩形，评分缓存和网格索引按恢复后的剩余矩形重建
+func (p *maxRects) loadState(state *algorithmState) error {
+	if err := p.algorithmBase.loadState(state); err != nil {
+		return err
+	}
+	p.freeRects = append(p.freeRects[:0], state.FreeRects...)
+	p.freeSerials = p.freeSerials[:0]
+	p.serialPos = p.serialPos[:0]
+	for i := range p.freeRects {
+		p.freeSerials = append(p.freeSerials, i)
+		p.serialPos = append(p.serialPos, i)
+	}
+	p.firstNew = len(p.serialPos)
+	if len(p.freeRects) > freeGridThreshold {
+		p.buildGrid()
+	}
+	return nil
+}
+
+// saveState 保存剩余矩形、合并策略、阶段数和切割树
+func (p *guillotinePack) saveState(state *algorithmState) {
+	p.algorithmBase.saveState(state)
+	state.FreeRects = p.freeRects
+	state.MergePolicy = p.mergePolicy
+	state.Stages = p.stages
+	state.CutTree = p.CutTree()
+}
+
+// loadState 恢复剩余矩形和切割树，并把切割树中的剩余材料与剩余矩形重新对应
+func (p *guillotinePack) loadState(state *algorithmState) error {
+	if err := p.algorithmBase.loadState(state); err != nil {
+		return err
+	}
+	p.freeRects = append(p.freeRects[:0], state.FreeRects...)
+	p.mergePolicy = state.MergePolicy
+	p.stages = state.Stages
+	p.freeNodes = p.freeNodes[:0]
+	if state.CutTree == nil {
+		// 合并过剩余矩形，切割树已无效
+		p.cutTree = nil
+		p.cutBroken = true
+		for range p.freeRects {
+			p.freeNodes = append(p.freeNodes, nil)
+		}
+		return nil
+	}
+	p.cutTree = state.CutTree
+	p.cutBroken = false
+	leaves := make(map[Rect2D]*CutNode)
+	p.cutTree.restoreFrom(CutNone, leaves)
+	for _, rect := range p.freeRects {
+		node, ok := leaves[NewRect(rect.X, rect.Y, rect.Width, rect.Height)]
+		if !ok {
+			return fmt.Errorf("free rect %v has no matching cut tree leaf", rect)
+		}
+		delete(leaves, node.Rect())
+		p.freeNodes = append(p.freeNodes, node)
+	}
+	return nil
+}
+
+// restoreFrom 恢复解码后切割树节点的切割来源方向，并收集未放置矩形的叶子节点
+func (n *CutNode) restoreFrom(from CutDirection, leaves map[Rect2D]*CutNode) {
+	n.from = from
+	if len(n.Children) == 0 {
+		if !n.Placed {
+			leaves[n.Rect()] = n
+		}
+		return
+	}
+	for _, child := range n.Children {
+		child.restoreFrom(n.Cut, leaves)
+	}
+}
@@ -0,0 +1,176 @@
+package rectpack
+
+import "slices"
+
+// Tracer 接收打包过程的逐步记录，用于分析启发式为什么做出某个选择
+//
+// 设置了 Tracer 的包装器每放置一个矩形(或确认剩余的矩形都放不下)时调用一次 Step，
+// 算法重置(包括 Shrink 的每次尝试)时调用一次 Reset。记录会额外为每个候选位置评分，
+// 只用于调试，不影响放置结果。
+type Tracer interface {
+	// Reset 在算法以容器尺寸 bin 重新开始时调用
+	Reset(bin Size2D)
+	// Step 在每一步放置后调用，step 中的切片归 Tracer 所有
+	Step(step TraceStep)
+}
+
+// TraceCandidate 是一个尺寸以一个方向放入一个剩余矩形的候选位置
+type TraceCandidate struct {
+	// Item 是尺寸在本步待选尺寸中的下标，ID 是其 ID
+	Item int `json:"item"`
+	ID   int `json:"id"`
+	// Free 是剩余矩形在 TraceStep.FreeRects 中的下标
+	Free int `json:"free"`
+	// Width 和 Height 是按该方向放置时(含间距)的尺寸
+	Width   int  `json:"width"`
+	Height  int  `json:"height"`
+	Rotated bool `json:"rotated"`
+	// Score1 和 Score2 是算法比较位置时使用的评分，越小越好；
+	// ContactPoint 的 Score1 为接触长度，越大越好；Guillotine 只有 Score1
+	Score1 int `json:"score1"`
+	Score2 int `json:"score2"`
+	// Exact 为 true 表示尺寸与剩余矩形完全相同，Guillotine 会直接选择它
+	Exact bool `json:"exact,omitempty"`
+}
+
+// TraceStep 是打包过程中的一步
+type TraceStep struct {
+	// Index 是这一步在本次运行中的序号，从0开始
+	Index int `json:"index"`
+	// FreeRects 是选择前的剩余矩形，按顺序放置的算法为空
+	FreeRects []Rect2D `json:"freeRects"`
+	// Candidates 是所有待选尺寸在每个剩余矩形中每个方向的评分
+	Candidates []TraceCandidate `json:"candidates"`
+	// Item 是被放置的尺寸在待选尺寸中的下标，-1 表示都放不下
+	Item int `json:"item"`
+	// Placed 是放置结果(不含间距)，Rotated 表示是否旋转
+	Placed  Rect2D `json:"placed"`
+	Rotated bool   `json:"rotated"`
+	// FreeAfter 是分割和剪枝后的剩余矩形
+	FreeAfter []Rect2D `json:"freeAfter"`
+}
+
+// TraceRun 是算法从一次重置开始的所有步骤
+type TraceRun struct {
+	Bin   Size2D      `json:"bin"`
+	Steps []TraceStep `json:"steps"`
+}
+
+// Trace 是把所有步骤保存在内存中的 Tracer
+type Trace struct {
+	Runs []TraceRun `json:"runs"`
+}
+
+// Reset 开始新的一次运行
+func (t *Trace) Reset(bin Size2D) {
+	t.Runs = append(t.Runs, TraceRun{Bin: bin})
+}
+
+// Step 把一步记录到最后一次运行中
+func (t *Trace) Step(step TraceStep) {
+	if len(t.Runs) == 0 {
+		t.Runs = append(t.Runs, TraceRun{})
+	}
+	run := &t.Runs[len(t.Runs)-1]
+	run.Steps = append(run.Steps, step)
+}
+
+// SetTracer 设置记录打包过程的 Tracer，nil 表示不记录
+//
+// 设置时立即以当前容器尺寸开始一次运行，之前已放置的矩形不会被记录。
+// 按容器目录打包(PackCatalog)和分层打包时创建的其他包装器不会被记录。
+func (p *Packer) SetTracer(tracer Tracer) {
+	p.tracer = tracer
+	p.algo.setTracer(tracer)
+	if tracer != nil {
+		tracer.Reset(p.algo.MaxSize())
+	}
+}
+
+// setTracer 设置算法的 Tracer
+func (p *algorithmBase) setTracer(tracer Tracer) {
+	p.tracer = tracer
+	p.traceIndex = 0
+}
+
+// traceBegin 开始记录一步，保存选择前的剩余矩形
+func (p *algorithmBase) traceBegin(freeRects []Rect2D) *TraceStep {
+	return &TraceStep{Index: p.traceIndex, FreeRects: slices.Clone(freeRects), Item: -1}
+}
+
+// traceEnd 记录放置结果和放置后的剩余矩形，把这一步交给 Tracer
+func (p *algorithmBase) traceEnd(step *TraceStep, item int, placed Rect2D, rotated bool, freeAfter []Rect2D) {
+	step.Item = item
+	if item >= 0 {
+		step.Placed = placed
+		step.Rotated = rotated
+	}
+	step.FreeAfter = slices.Clone(freeAfter)
+	p.traceIndex++
+	p.tracer.Step(*step)
+}
+
+// orientations 返回需要尝试的方向，false 为原方向
+func orientations(rotate bool) []bool {
+	if rotate {
+		return []bool{false, true}
+	}
+	return []bool{false}
+}
+
+// traceCandidates 为每个尺寸在每个剩余矩形中的每个方向评分
+func (p *maxRects) traceCandidates(padding int, sizes []Size2D) []TraceCandidate {
+	var candidates []TraceCandidate
+	for item, size := range sizes {
+		rotate := p.allowRotate && !size.NoRotate
+		padSize(&size, padding)
+		for i := range p.freeRects {
+			freeRect := &p.freeRects[i]
+			for _, rotated := range orientations(rotate) {
+				width, height := size.Width, size.Height
+				if rotated {
+					width, height = height, width
+				}
+				if freeRect.Width < width || freeRect.Height < height {
+					continue
+				}
+				c := TraceCandidate{Item: item, ID: size.ID, Free: i, Width: width, Height: height, Rotated: rotated}
+				if p.contact {
+					c.Score1 = p.contactPointScoreNode(freeRect.X, freeRect.Y, width, height)
+				} else {
+					c.Score1, c.Score2 = p.fit(freeRect, width, height)
+				}
+				c.Exact = width == freeRect.Width && height == freeRect.Height
+				candidates = append(candidates, c)
+			}
+		}
+	}
+	return candidates
+}
+
+// traceCandidates 为每个尺寸在每个满足切割阶段限制的剩余矩形中的每个方向评分
+func (p *guillotinePack) traceCandidates(padding int, sizes []Size2D) []TraceCandidate {
+	var candidates []TraceCandidate
+	for item, size := range sizes {
+		rotate := p.allowRotate && !size.NoRotate
+		padSize(&size, padding)
+		for i := range p.freeRects {
+			freeRect := &p.freeRects[i]
+			for _, rotated := range orientations(rotate) {
+				width, height := size.Width, size.Height
+				if rotated {
+					width, height = height, width
+				}
+				if freeRect.Width < width || freeRect.Height < height || !p.canCut(i, width, height) {
+					continue
+				}
+				candidates = append(candidates, TraceCandidate{
+					Item: item, ID: size.ID, Free: i, Width: width, Height: height, Rotated: rotated,
+					Score1: p.scoreRect(width, height, freeRect),
+					Exact:  width == freeRect.Width && height == freeRect.Height,
+				})
+			}
+		}
+	}
+	return candidates
+}
@@ -0,0 +1,175 @@
+package rectpack
+
+import "fmt"
+
+// ViolationKind 布局违规的类型
+type ViolationKind int
+
+const (
+	// ViolationOverlap 两个矩形互相重叠
+	ViolationOverlap ViolationKind = iota + 1
+	// ViolationPadding 两个矩形不重叠，但间距小于 padding
+	ViolationPadding
+	// ViolationOutOfBounds 矩形超出容器边界
+	ViolationOutOfBounds
+	// ViolationSize 放置的尺寸与输入不符(旋转时宽高应互换)
+	ViolationSize
+	// ViolationDuplicate 同一ID被放置了多次
+	ViolationDuplicate
+	// ViolationMissing 输入的矩形既没有被放置，也不在未打包列表中
+	ViolationMissing
+	// ViolationUnknown 放置了输入中不存在的ID
+	ViolationUnknown
+)
+
+// String 返回违规类型的名称
+func (k ViolationKind) String() string {
+	switch k {
+	case ViolationOverlap:
+		return "overlap"
+	case ViolationPadding:
+		return "padding"
+	case ViolationOutOfBounds:
+		return "out of bounds"
+	case ViolationSize:
+		return "size"
+	case ViolationDuplicate:
+		return "duplicate"
+	case ViolationMissing:
+		return "missing"
+	case ViolationUnknown:
+		return "unknown"
+	}
+	return fmt.Sprintf("ViolationKind(%d)", int(k))
+}
+
+// Violation 描述布局中的一处违规
+type Violation struct {
+	Kind ViolationKind
+	// ID 是违规矩形的ID
+	ID int
+	// Other 是重叠或间距违规时另一个矩形的ID
+	Other int
+	// Detail 是便于阅读的详细说明
+	Detail string
+}
+
+// String 返回违规的描述
+func (v Violation) String() string {
+	return fmt.Sprintf("%s: %s", v.Kind, v.Detail)
+}
+
+// Layout 是待验证的打包结果
+type Layout struct {
+	// Items 是输入的全部尺寸，ID 应当唯一(Quantity 展开后的实例ID也不能重复)
+	Items []Size2D
+	// Rects 是放置结果
+	Rects []Rect2D
+	// Unpacked 是未能放置的尺寸
+	Unpacked []Size2D
+	// Rotated 记录每个ID是否被旋转
+	Rotated map[int]bool
+}
+
+// Layout 返回包装器当前的打包结果，items 为插入过的全部尺寸
+func (p *Packer) Layout(items []Size2D) Layout {
+	return Layout{
+		Items:    items,
+		Rects:    p.GetPackedRects(),
+		Unpacked: p.GetUnpackedRects(),
+		Rotated:  p.GetIdMapRotated(),
+	}
+}
+
+// Validate 检查布局是否有效
+//
+// 检查内容包括：矩形互不重叠、间距不小于 padding、全部位于容器内、
+// 尺寸与输入一致(旋转时宽高互换，可伸缩矩形只检查是否在允许范围内)、
+// 每个输入ID恰好被放置一次或列入未打包列表。
+// 包装器把间距加在每个矩形的左上方，因此间距只检查矩形之间以及矩形与容器左边和上边之间，
+// 矩形可以紧贴容器的右边和下边。
+// 返回:
+//
+//	发现的所有违规，布局有效时返回 nil
+func Validate(layout Layout, bin Size2D, padding int) []Violation {
+	var violations []Violation
+	report := func(kind ViolationKind, id, other int, format string, args ...any) {
+		violations = append(violations, Violation{Kind: kind, ID: id, Other: other, Detail: fmt.Sprintf(format, args...)})
+	}
+
+	layout.Items = expandQuantities(layout.Items)
+	items := make(map[int]Size2D, len(layout.Items))
+	for _, item := range layout.Items {
+		items[item.ID] = item
+	}
+	placed := make(map[int]int, len(layout.Rects))
+	for i := range layout.Rects {
+		rect := &layout.Rects[i]
+		placed[rect.ID]++
+		if placed[rect.ID] == 2 {
+			report(ViolationDuplicate, rect.ID, rect.ID, "id %d placed more than once", rect.ID)
+		}
+		if rect.X < 0 || rect.Y < 0 || rect.Right() > bin.Width || rect.Bottom() > bin.Height {
+			report(ViolationOutOfBounds, rect.ID, rect.ID, "id %d at %s is outside bin %s", rect.ID, rect.String(), bin.ToString())
+		} else if padding > 0 && (rect.X < padding || rect.Y < padding) {
+			report(ViolationPadding, rect.ID, rect.ID, "id %d at %s is closer than %d to the left or top edge", rect.ID, rect.String(), padding)
+		}
+
+		item, ok := items[rect.ID]
+		if !ok {
+			report(ViolationUnknown, rect.ID, rect.ID, "id %d is not an input item", rect.ID)
+			continue
+		}
+		width, height := rect.Width, rect.Height
+		if layout.Rotated[rect.ID] {
+			width, height = height, width
+		}
+		if !sizeMatches(item, width, height) {
+			report(ViolationSize, rect.ID, rect.ID, "id %d placed as %dx%d (rotated=%v), input is %dx%d",
+				rect.ID, rect.Width, rect.Height, layout.Rotated[rect.ID], item.Width, item.Height)
+		}
+	}
+
+	for i := 0; i < len(layout.Rects); i++ {
+		a := layout.Rects[i]
+		inflated := a
+		inflated.Inflate(padding, padding)
+		for j := i + 1; j < len(layout.Rects); j++ {
+			b := layout.Rects[j]
+			if a.Intersects(b) {
+				report(ViolationOverlap, a.ID, b.ID, "id %d at %s overlaps id %d at %s", a.ID, a.String(), b.ID, b.String())
+			} else if padding > 0 && inflated.Intersects(b) {
+				report(ViolationPadding, a.ID, b.ID, "id %d at %s is closer than %d to id %d at %s", a.ID, a.String(), padding, b.ID, b.String())
+			}
+		}
+	}
+
+	unpacked := make(map[int]bool, len(layout.Unpacked))
+	for _, size := range layout.Unpacked {
+		unpacked[size.ID] = true
+	}
+	for _, item := range layout.Items {
+		if placed[item.ID] == 0 && !unpacked[item.ID] {
+			report(ViolationMissing, item.ID, item.ID, "id %d was neither placed nor reported unpacked", item.ID)
+		}
+	}
+	return violations
+}
+
+// sizeMatches 判断放置的(未旋转)宽高是否与输入尺寸一致，可伸缩矩形只要求在允许范围内
+func sizeMatches(item Size2D, width, height int) bool {
+	if item.Elastic == nil {
+		return item.Width == width && item.Height == height
+	}
+	if len(item.Elastic.Scales) > 0 {
+		for _, scale := range item.Elastic.Scales {
+			if w, h := scaled(item, scale); w == width && h == height {
+				return true
+			}
+		}
+		return false
+	}
+	minW, maxW := item.Elastic.widthRange(item)
+	minH, maxH := item.Elastic.heightRange(item)
+	return minW <= width && width <= maxW && minH <= height && height <= maxH
+}
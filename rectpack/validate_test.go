@@ -0,0 +1,249 @@
+package rectpack
+
+import (
+	"encoding/json"
+	"math/rand/v2"
+	"slices"
+	"strings"
+	"testing"
+)
+
+func TestValidate(t *testing.T) {
+	layout := Layout{
+		Items: []Size2D{NewSize2DByID(0, 10, 20), NewSize2DByID(1, 10, 10), NewSize2DByID(2, 5, 5)},
+		Rects: []Rect2D{
+			{Point2D: NewPoint(0, 0), Size2D: NewSize2DByID(0, 20, 10)},
+			{Point2D: NewPoint(15, 5), Size2D: NewSize2DByID(1, 10, 10)},
+		},
+	}
+	kinds := make(map[ViolationKind]int)
+	for _, v := range Validate(layout, NewSize2D(24, 24), 0) {
+		kinds[v.Kind]++
+	}
+	// 0 未标记旋转但宽高互换，0 与 1 重叠，1 超出右边界，2 缺失
+	for _, kind := range []ViolationKind{ViolationSize, ViolationOverlap, ViolationOutOfBounds, ViolationMissing} {
+		if kinds[kind] != 1 {
+			t.Errorf("got %d %v violations, want 1", kinds[kind], kind)
+		}
+	}
+
+	layout.Rotated = map[int]bool{0: true}
+	layout.Rects[1].X = 22
+	layout.Rects[1].Width = 2
+	layout.Unpacked = []Size2D{layout.Items[2]}
+	for _, v := range Validate(layout, NewSize2D(24, 24), 2) {
+		if v.Kind != ViolationPadding && v.Kind != ViolationSize {
+			t.Errorf("unexpected violation %s", v.String())
+		}
+	}
+
+	// 间距只要求在矩形之间和容器的左边、上边，紧贴右边和下边是有效的
+	layout = Layout{
+		Items: []Size2D{NewSize2DByID(0, 10, 10), NewSize2DByID(1, 10, 10)},
+		Rects: []Rect2D{
+			{Point2D: NewPoint(2, 2), Size2D: NewSize2DByID(0, 10, 10)},
+			{Point2D: NewPoint(14, 14), Size2D: NewSize2DByID(1, 10, 10)},
+		},
+	}
+	if violations := Validate(layout, NewSize2D(24, 24), 2); len(violations) != 0 {
+		t.Errorf("flush right and bottom edges reported %v", violations)
+	}
+	layout.Rects[0].X = 1
+	if violations := Validate(layout, NewSize2D(24, 24), 2); len(violations) != 1 || violations[0].Kind != ViolationPadding {
+		t.Errorf("rect 1 from the left edge reported %v, want one padding violation", violations)
+	}
+}
+
+// FuzzPack 用随机尺寸、间距和旋转设置驱动每一种启发式，并用 Validate 检查结果
+func FuzzPack(f *testing.F) {
+	f.Add(uint64(1), uint8(64), uint8(0), false)
+	f.Add(uint64(2), uint8(128), uint8(1), true)
+	f.Add(uint64(3), uint8(200), uint8(3), true)
+	f.Add(uint64(4), uint8(32), uint8(8), false)
+	f.Fuzz(func(t *testing.T, seed uint64, count, padding uint8, rotate bool) {
+		r := rand.New(rand.NewPCG(seed, uint64(count)))
+		items := make([]Size2D, count)
+		for i := range items {
+			items[i] = NewSize2DByID(i, r.IntN(80)+1, r.IntN(80)+1)
+		}
+		bin := NewSize2D(r.IntN(300)+100, r.IntN(300)+100)
+
+		for _, heuristic := range Heuristics {
+			packer, _ := NewPacker(bin.Width, bin.Height, heuristic)
+			packer.AllowRotate(rotate)
+			packer.SetPadding(int(padding))
+			packer.SetMergePolicy(MergePolicy(seed % 3))
+			packer.SetGuillotineStages(int(seed % 4))
+			packer.Insert(items...)
+			packer.Pack()
+			for _, v := range Validate(packer.Layout(items), bin, int(padding)) {
+				t.Errorf("%v (bin %s, padding %d, rotate %v): %s", heuristic, bin.ToString(), padding, rotate, v.String())
+			}
+		}
+	})
+}
+
+func TestCutTree(t *testing.T) {
+	r := rand.New(rand.NewPCG(7, 7))
+	items := make([]Size2D, 120)
+	for i := range items {
+		items[i] = NewSize2DByID(i, r.IntN(60)+5, r.IntN(60)+5)
+	}
+	bin := NewSize2D(400, 300)
+
+	for _, stages := range []int{0, 2, 3} {
+		packer, _ := NewPacker(bin.Width, bin.Height, GuillotineBSSF|SplitShorterLeftoverAxis)
+		packer.AllowRotate(true)
+		packer.SetPadding(2)
+		// 默认不合并剩余矩形，不限制阶段数时也应记录完整的切割树
+		packer.SetGuillotineStages(stages)
+		packer.Insert(items...)
+		packer.Pack()
+		for _, v := range Validate(packer.Layout(items), bin, 2) {
+			t.Errorf("stages %d: %s", stages, v.String())
+		}
+
+		tree := packer.CutTree()
+		if tree == nil {
+			t.Fatalf("stages %d: CutTree() = nil", stages)
+		}
+		if stages > 0 && tree.Stages() > stages {
+			t.Errorf("cut tree uses %d stages, limit is %d", tree.Stages(), stages)
+		}
+		placed := 0
+		tree.walk(func(node *CutNode) {
+			if node.Placed {
+				placed++
+			}
+		})
+		if placed != len(packer.GetPackedRects()) {
+			t.Errorf("stages %d: %d placed leaves, want %d", stages, placed, len(packer.GetPackedRects()))
+		}
+		if len(tree.Sequence()) == 0 {
+			t.Errorf("stages %d: empty cut sequence", stages)
+		}
+		if _, err := json.Marshal(tree); err != nil {
+			t.Errorf("stages %d: marshal cut tree: %v", stages, err)
+		}
+	}
+
+	adjacent, _ := NewPacker(bin.Width, bin.Height, GuillotineBSSF)
+	adjacent.SetPadding(2)
+	adjacent.SetMergePolicy(MergeAdjacent)
+	adjacent.Insert(items...)
+	adjacent.Pack()
+	packer, _ := NewPacker(bin.Width, bin.Height, GuillotineBSSF)
+	packer.SetPadding(2)
+	packer.SetMergePolicy(MergeMaximal)
+	packer.Insert(items...)
+	packer.Pack()
+	for _, v := range Validate(packer.Layout(items), bin, 2) {
+		t.Errorf("MergeMaximal: %s", v.String())
+	}
+	if packer.CutTree() != nil {
+		t.Error("CutTree() should be nil after free rects were merged")
+	}
+	// 剩余矩形应当正好是剩余区域的全部最大矩形
+	free := packer.FreeRects()
+	maximal := NewRegion(free...).MaximalRects()
+	if len(free) != len(maximal) {
+		t.Errorf("MergeMaximal: %d free rects, region has %d maximal rects", len(free), len(maximal))
+	}
+	for _, rect := range free {
+		if !slices.Contains(maximal, rect) {
+			t.Errorf("MergeMaximal: free rect %v is not maximal", rect)
+		}
+	}
+	// 合并后的剩余矩形没有切割树节点，此时不能再限制阶段数
+	merged, _ := NewPacker(bin.Width, bin.Height, GuillotineBSSF)
+	merged.SetMergePolicy(MergeAdjacent)
+	merged.Online = true
+	failed := slices.Clone(merged.Insert(items[:60]...))
+	if err := merged.SetGuillotineStages(2); err == nil {
+		t.Error("SetGuillotineStages succeeded after rects were placed")
+	}
+	failed = append(failed, merged.Insert(items[60:]...)...)
+	for _, v := range Validate(Layout{Items: items, Rects: merged.GetPackedRects(), Unpacked: failed}, bin, 0) {
+		t.Errorf("online insert after refused stages: %s", v.String())
+	}
+	merged.Reset()
+	if err := merged.SetGuillotineStages(2); err != nil {
+		t.Errorf("SetGuillotineStages after Reset: %v", err)
+	}
+	if got, want := packer.GetAreaUsedRate(false), adjacent.GetAreaUsedRate(false); got <= want {
+		t.Errorf("MergeMaximal fill %.4f, MergeAdjacent %.4f", got, want)
+	}
+}
+
+func TestPlanCuts(t *testing.T) {
+	parts := []Part{
+		{Label: "side", Width: 600, Height: 300, Quantity: 4, Grain: true},
+		{Label: "shelf", Width: 560, Height: 280, Quantity: 6},
+		{Label: "back", Width: 580, Height: 900, Quantity: 1},
+		{Label: "huge", Width: 5000, Height: 10},
+	}
+	stock := []Sheet{
+		{Label: "full", Width: 2440, Height: 1220, Quantity: 1, Cost: 60},
+		{Label: "half", Width: 1220, Height: 1220, Cost: 35},
+	}
+	const kerf = 3
+	plan, err := PlanCuts(parts, stock, CuttingOptions{Kerf: kerf, Stages: 3})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(plan.Unplaced) != 1 || plan.Unplaced[0].Label != "huge" {
+		t.Errorf("unplaced = %v, want only the oversized part", plan.Unplaced)
+	}
+	if len(plan.Sheets) == 0 || plan.Sheets[0].Sheet.Label != "full" || plan.Cost != 60 {
+		t.Errorf("got %d sheets costing %v, want one full sheet costing 60", len(plan.Sheets), plan.Cost)
+	}
+
+	placed := 0
+	for _, sheet := range plan.Sheets {
+		var layout Layout
+		layout.Rotated = make(map[int]bool)
+		for _, part := range sheet.Parts {
+			// 板材边缘没有锯缝，按包装器的方式把零件平移一个锯缝宽度再检查
+			rect := part.Rect
+			rect.Offset(kerf, kerf)
+			layout.Rects = append(layout.Rects, rect)
+			layout.Rotated[part.Rect.ID] = part.Rotated
+			size := part.Rect.Size2D
+			if part.Rotated {
+				size.Width, size.Height = size.Height, size.Width
+				if part.Label == "side" {
+					t.Errorf("grain part %d was rotated", part.Rect.ID)
+				}
+			}
+			layout.Items = append(layout.Items, size)
+		}
+		for _, v := range Validate(layout, NewSize2D(sheet.Sheet.Width+kerf, sheet.Sheet.Height+kerf), kerf) {
+			t.Error(v.String())
+		}
+		if sheet.Tree == nil || sheet.Tree.Width != sheet.Sheet.Width || sheet.Tree.Stages() > 3 {
+			t.Errorf("cut tree %+v does not match sheet %+v", sheet.Tree, sheet.Sheet)
+		}
+		placed += len(sheet.Parts)
+	}
+	if placed != 11 {
+		t.Errorf("placed %d parts, want 11", placed)
+	}
+
+	var csv strings.Builder
+	if err := plan.WriteCSV(&csv); err != nil {
+		t.Fatal(err)
+	}
+	if lines := strings.Count(csv.String(), "\n"); lines != placed+1 {
+		t.Errorf("CSV has %d lines, want %d", lines, placed+1)
+	}
+
+	// 只有整板能切出大面板，先用便宜的小板切小零件会多用一张板材
+	parts = []Part{{Label: "panel", Width: 2000, Height: 1000}, {Label: "block", Width: 100, Height: 100, Quantity: 19}}
+	stock = []Sheet{{Label: "full", Width: 2440, Height: 1220, Cost: 60}, {Label: "offcut", Width: 600, Height: 600, Cost: 8}}
+	if plan, err = PlanCuts(parts, stock, CuttingOptions{Kerf: kerf}); err != nil {
+		t.Fatal(err)
+	}
+	if len(plan.Sheets) != 1 || plan.Sheets[0].Sheet.Label != "full" || len(plan.Unplaced) != 0 {
+		t.Errorf("got %d sheets costing %v, want one full sheet", len(plan.Sheets), plan.Cost)
+	}
+}
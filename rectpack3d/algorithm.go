@@ -0,0 +1,179 @@
+package rectpack3d
+
+// packAlgorithm 是一个包装算法的接口
+type packAlgorithm interface {
+	// 重置包装器到初始状态，设置最大宽高深。
+	Reset(width, height, depth int)
+	// 按顺序插入新长方体，指定长方体间的间距。
+	// 返回无法包装的尺寸，由算法内部管理，下次插入前有效。
+	Insert(padding int, sizes ...Size3D) []Size3D
+	// 返回已包装的长方体列表。
+	GetPackedBoxes() []Box3D
+	// 设置是否允许旋转长方体以优化放置。
+	// 默认：false
+	AllowRotate(enabled bool)
+	// 返回算法可包装的最大尺寸。
+	MaxSize() Size3D
+	// 返回已使用的总体积。
+	GetUsedVolume() int
+	// 返回每个ID使用的摆放方向。
+	GetIdMapOrientation() map[int]Orientation
+}
+
+// placer 是具体算法寻找和占用位置的部分
+type placer interface {
+	// findPosition 为含间距的 w x h x d 节点寻找评分最优的位置
+	findPosition(w, h, d int) (Box3D, score, bool)
+	// placeBox 占用含间距的节点
+	placeBox(node Box3D)
+	// reset 清空剩余空间
+	reset()
+}
+
+// algorithmBase 是所有算法共用的部分：按顺序为每个尺寸选择方向和位置
+type algorithmBase struct {
+	placer
+	packed       []Box3D // 已包装的长方体
+	nodes        []Box3D // 已包装的长方体(含间距)
+	maxWidth     int
+	maxHeight    int
+	maxDepth     int
+	usedVolume   int
+	allowRotate  bool
+	fit          Heuristic
+	orientations map[int]Orientation
+	failed       []Size3D // Insert 返回的无法包装的尺寸，下次插入时复用
+}
+
+// newPackAlgorithm 创建 heuristic 对应的包装算法
+func newPackAlgorithm(width, height, depth int, heuristic Heuristic) packAlgorithm {
+	p := &algorithmBase{fit: heuristic.Bin(), orientations: make(map[int]Orientation)}
+	switch heuristic.Algorithm() {
+	case ExtremePoints:
+		p.placer = &extremePoints{algorithmBase: p}
+	default:
+		p.placer = &maxSpaces{algorithmBase: p}
+	}
+	p.Reset(width, height, depth)
+	return p
+}
+
+// Reset 重置包装器的状态，设置新的最大尺寸，清空已包装长方体。
+func (p *algorithmBase) Reset(width, height, depth int) {
+	p.maxWidth, p.maxHeight, p.maxDepth = width, height, depth
+	p.usedVolume = 0
+	p.packed = p.packed[:0]
+	p.nodes = p.nodes[:0]
+	clear(p.orientations)
+	p.placer.reset()
+}
+
+// GetPackedBoxes 返回已包装的长方体列表。
+func (p *algorithmBase) GetPackedBoxes() []Box3D {
+	return p.packed
+}
+
+// AllowRotate 设置是否允许旋转长方体
+func (p *algorithmBase) AllowRotate(enabled bool) {
+	p.allowRotate = enabled
+}
+
+// MaxSize 返回算法可包装的最大尺寸
+func (p *algorithmBase) MaxSize() Size3D {
+	return NewSize3D(p.maxWidth, p.maxHeight, p.maxDepth)
+}
+
+// GetUsedVolume 返回已使用的总体积(含间距)
+func (p *algorithmBase) GetUsedVolume() int {
+	return p.usedVolume
+}
+
+// GetIdMapOrientation 返回每个ID使用的摆放方向
+func (p *algorithmBase) GetIdMapOrientation() map[int]Orientation {
+	return p.orientations
+}
+
+// Insert 按顺序为每个尺寸在所有允许的方向中选择评分最优的位置
+func (p *algorithmBase) Insert(padding int, sizes ...Size3D) []Size3D {
+	unpacked := p.failed[:0]
+	padding = max(padding, 0)
+	for _, size := range sizes {
+		var best Box3D
+		var bestScore score
+		var bestOrient Orientation
+		found := false
+		allowed := size.allowed(p.allowRotate)
+		for _, orient := range orientations {
+			if allowed&orient == 0 {
+				continue
+			}
+			w, h, d := orient.Apply(size)
+			if orient != OrientWHD && allowed&OrientWHD != 0 && w == size.Width && h == size.Height && d == size.Depth {
+				continue // 边长相同时方向等价
+			}
+			node, s, ok := p.findPosition(w+padding, h+padding, d+padding)
+			if ok && (!found || s.less(bestScore)) {
+				best, bestScore, bestOrient, found = node, s, orient, true
+			}
+		}
+		if !found {
+			unpacked = append(unpacked, size)
+			continue
+		}
+		p.placeBox(best)
+		p.nodes = append(p.nodes, best)
+		p.usedVolume += best.Volume()
+
+		box := best
+		box.Size3D = size
+		box.Width, box.Height, box.Depth = bestOrient.Apply(size)
+		box.X += padding
+		box.Y += padding
+		box.Z += padding
+		p.packed = append(p.packed, box)
+		p.orientations[size.ID] = bestOrient
+	}
+	p.failed = unpacked
+	return unpacked
+}
+
+// contactScore 返回节点与容器壁和已放置节点的接触面积
+func (p *algorithmBase) contactScore(node Box3D) int {
+	contact := 0
+	if node.X == 0 || node.Right() == p.maxWidth {
+		contact += node.Height * node.Depth
+	}
+	if node.Y == 0 || node.Bottom() == p.maxHeight {
+		contact += node.Width * node.Depth
+	}
+	if node.Z == 0 || node.Back() == p.maxDepth {
+		contact += node.Width * node.Height
+	}
+	for i := range p.nodes {
+		other := &p.nodes[i]
+		if other.Right() == node.X || node.Right() == other.X {
+			contact += overlap(node.Y, node.Bottom(), other.Y, other.Bottom()) * overlap(node.Z, node.Back(), other.Z, other.Back())
+		}
+		if other.Bottom() == node.Y || node.Bottom() == other.Y {
+			contact += overlap(node.X, node.Right(), other.X, other.Right()) * overlap(node.Z, node.Back(), other.Z, other.Back())
+		}
+		if other.Back() == node.Z || node.Back() == other.Z {
+			contact += overlap(node.X, node.Right(), other.X, other.Right()) * overlap(node.Y, node.Bottom(), other.Y, other.Bottom())
+		}
+	}
+	return contact
+}
+
+// overlap 返回区间 [a0, a1) 与 [b0, b1) 重叠的长度
+func overlap(a0, a1, b0, b1 int) int {
+	return max(min(a1, b1)-max(a0, b0), 0)
+}
+
+// score 返回在剩余空间 space 的左上前角放置 w x h x d 节点的评分
+func (p *algorithmBase) score(space *Box3D, w, h, d int) score {
+	if p.fit == ContactPoint {
+		node := NewBox(space.X, space.Y, space.Z, w, h, d)
+		return score{-p.contactScore(node), space.Y + h, space.X + w}
+	}
+	return fitScore(p.fit, space, w, h, d)
+}
@@ -0,0 +1,226 @@
+// Package rectpack3d 把长方体装入容器，API 与 rectpack 对应：
+// Size3D/Box3D 对应 Size2D/Rect2D，Packer、Heuristic、SortFunc 和 PackCatalog 的用法相同，
+// 旋转扩展为六种摆放方向，每个尺寸可以限制允许的方向。
+//
+// 坐标系：X 为宽度方向，Y 为高度方向，Z 为深度方向，原点在容器的左上前角。
+package rectpack3d
+
+import (
+	"fmt"
+	"math/bits"
+)
+
+// Point3D 描述了三维空间中的一个位置。
+type Point3D struct {
+	X int
+	Y int
+	Z int
+}
+
+// NewPoint 初始化一个具有指定坐标的新点。
+func NewPoint(x, y, z int) Point3D {
+	return Point3D{X: x, Y: y, Z: z}
+}
+
+// Eq 判断接收者和另一个点是否具有相同的值。
+func (p *Point3D) Eq(point Point3D) bool {
+	return p.X == point.X && p.Y == point.Y && p.Z == point.Z
+}
+
+// String 返回点的字符串表示形式。
+func (p *Point3D) String() string {
+	return fmt.Sprintf("[%v, %v, %v]", p.X, p.Y, p.Z)
+}
+
+// Orientation 是长方体摆放方向的位集合，每一位表示原尺寸的哪条边分别沿 X、Y、Z 轴放置
+type Orientation uint8
+
+const (
+	// OrientWHD 为原方向：宽沿 X，高沿 Y，深沿 Z
+	OrientWHD Orientation = 1 << iota
+	// OrientDHW 绕 Y 轴旋转：深沿 X，高沿 Y，宽沿 Z
+	OrientDHW
+	// OrientHWD 绕 Z 轴旋转：高沿 X，宽沿 Y，深沿 Z
+	OrientHWD
+	// OrientHDW 高沿 X，深沿 Y，宽沿 Z
+	OrientHDW
+	// OrientWDH 绕 X 轴旋转：宽沿 X，深沿 Y，高沿 Z
+	OrientWDH
+	// OrientDWH 深沿 X，宽沿 Y，高沿 Z
+	OrientDWH
+
+	// OrientAll 表示六种方向都允许
+	OrientAll = OrientWHD | OrientDHW | OrientHWD | OrientHDW | OrientWDH | OrientDWH
+	// OrientUpright 表示高度保持沿 Y 轴，只能绕 Y 轴旋转，例如“此面向上”的货物
+	OrientUpright = OrientWHD | OrientDHW
+)
+
+// orientations 按尝试顺序列出每一种方向
+var orientations = [...]Orientation{OrientWHD, OrientDHW, OrientHWD, OrientHDW, OrientWDH, OrientDWH}
+
+// String 返回方向的名称，多个方向以 "|" 连接
+func (o Orientation) String() string {
+	if o == 0 {
+		return "none"
+	}
+	names := [...]string{"WHD", "DHW", "HWD", "HDW", "WDH", "DWH"}
+	s := ""
+	for i, orient := range orientations {
+		if o&orient != 0 {
+			if s != "" {
+				s += "|"
+			}
+			s += names[i]
+		}
+	}
+	return s
+}
+
+// Count 返回集合中方向的数量
+func (o Orientation) Count() int {
+	return bits.OnesCount8(uint8(o & OrientAll))
+}
+
+// Apply 返回以单个方向 o 摆放 size 时沿 X、Y、Z 轴的尺寸
+func (o Orientation) Apply(size Size3D) (width, height, depth int) {
+	w, h, d := size.Width, size.Height, size.Depth
+	switch o {
+	case OrientDHW:
+		return d, h, w
+	case OrientHWD:
+		return h, w, d
+	case OrientHDW:
+		return h, d, w
+	case OrientWDH:
+		return w, d, h
+	case OrientDWH:
+		return d, w, h
+	default:
+		return w, h, d
+	}
+}
+
+// Size3D 描述了三维空间中实体的尺寸。
+type Size3D struct {
+	// Width 是沿 x 轴的尺寸。
+	Width int
+	// Height 是沿 y 轴的尺寸。
+	Height int
+	// Depth 是沿 z 轴的尺寸。
+	Depth int
+	// ID 是用户定义的标识符，用于区分此实例与其他实例。
+	ID int
+	// Orientations 限制此尺寸允许的摆放方向，0 表示不限制(仍受包装器是否允许旋转的约束)。
+	// 非0时只使用集合中的方向，例如 OrientDHW 表示只能绕 Y 轴转过来放置；
+	// 包装器不允许旋转时只使用原方向 OrientWHD。
+	Orientations Orientation
+	// Quantity 大于1时表示 Quantity 个相同的尺寸，插入时展开为 ID 依次为 ID, ID+1, ..., ID+Quantity-1 的实例。
+	Quantity int
+}
+
+// NewSize3D 创建具有指定尺寸的新尺寸对象。
+func NewSize3D(width, height, depth int) Size3D {
+	return Size3D{Width: width, Height: height, Depth: depth}
+}
+
+// NewSize3DByID 创建具有指定尺寸和唯一标识符的新尺寸对象。
+func NewSize3DByID(id, width, height, depth int) Size3D {
+	return Size3D{ID: id, Width: width, Height: height, Depth: depth}
+}
+
+// Eq 判断接收者和另一个尺寸是否具有相同的值。ID 字段被忽略。
+func (sz *Size3D) Eq(size Size3D) bool {
+	return sz.Width == size.Width && sz.Height == size.Height && sz.Depth == size.Depth
+}
+
+// ToString 返回尺寸的字符串表示形式。
+func (sz *Size3D) ToString() string {
+	return fmt.Sprintf("[%v, %v, %v]", sz.Width, sz.Height, sz.Depth)
+}
+
+// Volume 返回体积（宽度 * 高度 * 深度）。
+func (sz *Size3D) Volume() int {
+	return sz.Width * sz.Height * sz.Depth
+}
+
+// SurfaceArea 返回表面积。
+func (sz *Size3D) SurfaceArea() int {
+	return (sz.Width*sz.Height + sz.Width*sz.Depth + sz.Height*sz.Depth) << 1
+}
+
+// MaxSide 返回最长边的值。
+func (sz *Size3D) MaxSide() int {
+	return max(sz.Width, sz.Height, sz.Depth)
+}
+
+// MinSide 返回最短边的值。
+func (sz *Size3D) MinSide() int {
+	return min(sz.Width, sz.Height, sz.Depth)
+}
+
+// allowed 返回包装器允许旋转时(rotate)此尺寸可以使用的方向
+func (sz *Size3D) allowed(rotate bool) Orientation {
+	if !rotate {
+		return OrientWHD
+	}
+	if sz.Orientations == 0 {
+		return OrientAll
+	}
+	if allowed := sz.Orientations & OrientAll; allowed != 0 {
+		return allowed
+	}
+	return OrientWHD
+}
+
+// Box3D 描述了三维空间中的一个位置（左上前角）和尺寸。
+type Box3D struct {
+	Point3D
+	Size3D
+}
+
+// NewBox 初始化一个使用指定点和尺寸值的新长方体。
+func NewBox(x, y, z, w, h, d int) Box3D {
+	return Box3D{
+		Point3D: Point3D{X: x, Y: y, Z: z},
+		Size3D:  Size3D{Width: w, Height: h, Depth: d},
+	}
+}
+
+// String 返回描述长方体的字符串。
+func (b *Box3D) String() string {
+	return fmt.Sprintf("[%v, %v, %v, %v, %v, %v]", b.X, b.Y, b.Z, b.Width, b.Height, b.Depth)
+}
+
+// Right 返回长方体在 x 轴上的远端坐标。
+func (b *Box3D) Right() int {
+	return b.X + b.Width
+}
+
+// Bottom 返回长方体在 y 轴上的远端坐标。
+func (b *Box3D) Bottom() int {
+	return b.Y + b.Height
+}
+
+// Back 返回长方体在 z 轴上的远端坐标。
+func (b *Box3D) Back() int {
+	return b.Z + b.Depth
+}
+
+// IsEmpty 测试长方体的任意一边是否小于1。
+func (b *Box3D) IsEmpty() bool {
+	return b.Width <= 0 || b.Height <= 0 || b.Depth <= 0
+}
+
+// ContainsBox 测试指定的长方体是否包含在当前接收者的边界内。
+func (b *Box3D) ContainsBox(box Box3D) bool {
+	return b.X <= box.X && box.Right() <= b.Right() &&
+		b.Y <= box.Y && box.Bottom() <= b.Bottom() &&
+		b.Z <= box.Z && box.Back() <= b.Back()
+}
+
+// Intersects 测试接收者是否与指定的长方体有任何重叠。
+func (b *Box3D) Intersects(box Box3D) bool {
+	return box.X < b.Right() && b.X < box.Right() &&
+		box.Y < b.Bottom() && b.Y < box.Bottom() &&
+		box.Z < b.Back() && b.Z < box.Back()
+}
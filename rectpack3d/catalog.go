@@ -0,0 +1,81 @@
+package rectpack3d
+
+import "rectpack2d/internal/binselect"
+
+// BinType 是容器目录中的一种容器
+type BinType struct {
+	Width  int
+	Height int
+	Depth  int
+	// Cost 是每个容器的成本，例如运费
+	Cost float64
+	// Limit 是可用数量，0 表示不限
+	Limit int
+}
+
+// Page 是按容器目录打包时打开的一个容器
+type Page struct {
+	// Bin 是该容器的类型
+	Bin BinType
+	// Packer 保存该容器的打包结果
+	Packer *Packer
+}
+
+// PackCatalog 从容器目录中选择容器，把暂存的长方体打包到多个容器中，使总成本尽量小
+//
+// 策略与 rectpack.Packer.PackCatalog 相同：每次为剩余长方体依次尝试每种还有数量的容器，
+// 估算选择它之后的总成本(该容器的成本加上剩余长方体按单位已用体积成本还需要的容器成本，不超过每种容器的剩余数量)，
+// 选择总成本最低的容器。重复直到长方体全部放下或没有容器能再放下任何长方体。
+// 每个容器使用与当前包装器相同的算法和配置。
+// 返回:
+//
+//	打开的容器；true: 全部打包成功 false: 部分失败(可通过GetUnpackedBoxes获取失败尺寸)
+func (p *Packer) PackCatalog(catalog []BinType) ([]Page, bool) {
+	remaining := make([]int, len(catalog))
+	for i, bin := range catalog {
+		remaining[i] = bin.Limit
+	}
+	sizes := p.unpackedSize3Ds
+	var pages []Page
+	for len(sizes) > 0 {
+		trials := make([]binselect.Trial[Size3D], len(catalog))
+		packers := make([]*Packer, len(catalog))
+		for i, bin := range catalog {
+			if bin.Width <= 0 || bin.Height <= 0 || bin.Depth <= 0 || bin.Limit > 0 && remaining[i] == 0 {
+				continue
+			}
+			packer := p.newSubPacker(bin.Width, bin.Height, bin.Depth)
+			packer.Insert(sizes...)
+			packer.Pack()
+			packers[i] = packer
+			trials[i] = binselect.Trial[Size3D]{Cost: bin.Cost, Placed: packer.algo.GetUsedVolume(), Leftover: packer.GetUnpackedBoxes(), Limit: remaining[i]}
+		}
+		best := binselect.Choose(trials, func(size Size3D, bin int) bool {
+			return packers[bin].fits(size)
+		}, func(size Size3D) int {
+			return (size.Width + p.padding) * (size.Height + p.padding) * (size.Depth + p.padding)
+		})
+		if best < 0 {
+			break
+		}
+		remaining[best]--
+		pages = append(pages, Page{Bin: catalog[best], Packer: packers[best]})
+		sizes = packers[best].GetUnpackedBoxes()
+	}
+	p.unpackedSize3Ds = append([]Size3D(nil), sizes...)
+	return pages, len(sizes) == 0
+}
+
+// fits 判断尺寸(加上间距后)能否以允许的方向放入当前的空容器
+func (p *Packer) fits(size Size3D) bool {
+	bin := p.algo.MaxSize()
+	padding := max(p.padding, 0)
+	allowed := size.allowed(p.allowRotate)
+	for _, orient := range orientations {
+		w, h, d := orient.Apply(size)
+		if allowed&orient != 0 && w+padding <= bin.Width && h+padding <= bin.Height && d+padding <= bin.Depth {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,137 @@
+package rectpack3d
+
+// extremePoints 只在极点上尝试放置：每放置一个长方体，把它的三个远端角沿坐标轴向原点投影，
+// 投影碰到容器壁或其他长方体的位置成为新的极点(Crainic 等人的 Extreme Point 方法)
+type extremePoints struct {
+	*algorithmBase
+	points []Point3D
+}
+
+func (p *extremePoints) reset() {
+	p.points = append(p.points[:0], Point3D{})
+}
+
+func (p *extremePoints) findPosition(w, h, d int) (Box3D, score, bool) {
+	var best Box3D
+	var bestScore score
+	found := false
+	for _, point := range p.points {
+		node := NewBox(point.X, point.Y, point.Z, w, h, d)
+		if node.Right() > p.maxWidth || node.Bottom() > p.maxHeight || node.Back() > p.maxDepth || p.collides(node) {
+			continue
+		}
+		space := p.residual(node)
+		s := p.score(&space, w, h, d)
+		if !found || s.less(bestScore) {
+			best, bestScore, found = node, s, true
+		}
+	}
+	return best, bestScore, found
+}
+
+// collides 判断节点是否与已放置的节点重叠
+func (p *extremePoints) collides(node Box3D) bool {
+	for i := range p.nodes {
+		if p.nodes[i].Intersects(node) {
+			return true
+		}
+	}
+	return false
+}
+
+// residual 返回节点所在极点处的剩余空间：沿每个轴延伸到容器壁或挡住节点的第一个长方体
+func (p *extremePoints) residual(node Box3D) Box3D {
+	right, bottom, back := p.maxWidth, p.maxHeight, p.maxDepth
+	for i := range p.nodes {
+		other := &p.nodes[i]
+		overlapX := other.X < node.Right() && node.X < other.Right()
+		overlapY := other.Y < node.Bottom() && node.Y < other.Bottom()
+		overlapZ := other.Z < node.Back() && node.Z < other.Back()
+		if overlapY && overlapZ && other.X >= node.Right() {
+			right = min(right, other.X)
+		}
+		if overlapX && overlapZ && other.Y >= node.Bottom() {
+			bottom = min(bottom, other.Y)
+		}
+		if overlapX && overlapY && other.Z >= node.Back() {
+			back = min(back, other.Z)
+		}
+	}
+	return NewBox(node.X, node.Y, node.Z, right-node.X, bottom-node.Y, back-node.Z)
+}
+
+// placeBox 生成新节点投影得到的极点，并去掉被节点占用或重复的极点
+func (p *extremePoints) placeBox(node Box3D) {
+	// 放置前的节点不包括 node，投影时需要把它也算作障碍
+	p.nodes = append(p.nodes, node)
+	candidates := []Point3D{
+		p.project(NewPoint(node.Right(), node.Y, node.Z), 1), p.project(NewPoint(node.Right(), node.Y, node.Z), 2),
+		p.project(NewPoint(node.X, node.Bottom(), node.Z), 0), p.project(NewPoint(node.X, node.Bottom(), node.Z), 2),
+		p.project(NewPoint(node.X, node.Y, node.Back()), 0), p.project(NewPoint(node.X, node.Y, node.Back()), 1),
+	}
+	p.nodes = p.nodes[:len(p.nodes)-1]
+
+	kept := p.points[:0]
+	for _, point := range p.points {
+		if !containsPoint(&node, point) {
+			kept = append(kept, point)
+		}
+	}
+	p.points = kept
+	for _, point := range candidates {
+		if point.X >= p.maxWidth || point.Y >= p.maxHeight || point.Z >= p.maxDepth || containsPoint(&node, point) {
+			continue
+		}
+		duplicate := false
+		for _, existing := range p.points {
+			if existing == point {
+				duplicate = true
+				break
+			}
+		}
+		if !duplicate {
+			p.points = append(p.points, point)
+		}
+	}
+}
+
+// project 把点沿 axis(0: x, 1: y, 2: z) 轴向原点移动，直到碰到容器壁或其他节点的远端面
+func (p *extremePoints) project(point Point3D, axis int) Point3D {
+	limit := 0
+	for i := range p.nodes {
+		other := &p.nodes[i]
+		switch axis {
+		case 0:
+			if other.Right() <= point.X && inRange(point.Y, other.Y, other.Bottom()) && inRange(point.Z, other.Z, other.Back()) {
+				limit = max(limit, other.Right())
+			}
+		case 1:
+			if other.Bottom() <= point.Y && inRange(point.X, other.X, other.Right()) && inRange(point.Z, other.Z, other.Back()) {
+				limit = max(limit, other.Bottom())
+			}
+		default:
+			if other.Back() <= point.Z && inRange(point.X, other.X, other.Right()) && inRange(point.Y, other.Y, other.Bottom()) {
+				limit = max(limit, other.Back())
+			}
+		}
+	}
+	switch axis {
+	case 0:
+		point.X = limit
+	case 1:
+		point.Y = limit
+	default:
+		point.Z = limit
+	}
+	return point
+}
+
+// inRange 判断 v 是否在 [lo, hi) 内
+func inRange(v, lo, hi int) bool {
+	return lo <= v && v < hi
+}
+
+// containsPoint 判断点是否在长方体内
+func containsPoint(box *Box3D, point Point3D) bool {
+	return inRange(point.X, box.X, box.Right()) && inRange(point.Y, box.Y, box.Bottom()) && inRange(point.Z, box.Z, box.Back())
+}
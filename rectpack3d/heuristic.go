@@ -0,0 +1,129 @@
+package rectpack3d
+
+import (
+	"cmp"
+	"fmt"
+)
+
+// Heuristic 是包装算法与放置评分方式的组合，与 rectpack.Heuristic 的位布局相同
+type Heuristic uint16
+
+const (
+	// MaxSpaces 记录所有最大剩余空间(三维的 MaxRects)，放置后分割并剪枝
+	MaxSpaces Heuristic = 0x0
+	// ExtremePoints 只在已放置长方体投影得到的极点上尝试放置，速度快，空间利用率略低
+	ExtremePoints Heuristic = 0x1
+
+	// BestShortSideFit 使剩余空间(或极点处的剩余空间)最短的剩余边最小
+	BestShortSideFit Heuristic = 0x00
+	// BestLongSideFit 使最长的剩余边最小
+	BestLongSideFit Heuristic = 0x10
+	// BestVolumeFit 使剩余空间的剩余体积最小
+	BestVolumeFit Heuristic = 0x20
+	// BottomLeft 依次使放置后的 Y、X、Z 远端坐标最小
+	BottomLeft Heuristic = 0x30
+	// ContactPoint 使与容器壁和已放置长方体的接触面积最大
+	ContactPoint Heuristic = 0x40
+
+	typeMask = 0x000F
+	fitMask  = 0x00F0
+
+	/**********************************************************************************************
+	* Present combinations of valid heuristics
+	**********************************************************************************************/
+	MaxSpacesBSSF     = MaxSpaces | BestShortSideFit
+	MaxSpacesBLSF     = MaxSpaces | BestLongSideFit
+	MaxSpacesBVF      = MaxSpaces | BestVolumeFit
+	MaxSpacesBL       = MaxSpaces | BottomLeft
+	MaxSpacesCP       = MaxSpaces | ContactPoint
+	ExtremePointsBSSF = ExtremePoints | BestShortSideFit
+	ExtremePointsBLSF = ExtremePoints | BestLongSideFit
+	ExtremePointsBVF  = ExtremePoints | BestVolumeFit
+	ExtremePointsBL   = ExtremePoints | BottomLeft
+	ExtremePointsCP   = ExtremePoints | ContactPoint
+)
+
+// Heuristics 列出所有预设的启发式组合
+var Heuristics = []Heuristic{
+	MaxSpacesBSSF, MaxSpacesBLSF, MaxSpacesBVF, MaxSpacesBL, MaxSpacesCP,
+	ExtremePointsBSSF, ExtremePointsBLSF, ExtremePointsBVF, ExtremePointsBL, ExtremePointsCP,
+}
+
+// Algorithm returns the algorithm portion of the bitmask.
+func (e Heuristic) Algorithm() Heuristic {
+	return e & typeMask
+}
+
+// Bin returns the bin selection method portion of the bitmask.
+func (e Heuristic) Bin() Heuristic {
+	return e & fitMask
+}
+
+// String 返回 "算法-变体" 形式的名称，与 ResolveAlgorithm 接受的名称一致
+func (e Heuristic) String() string {
+	var algo string
+	switch e.Algorithm() {
+	case MaxSpaces:
+		algo = "MaxSpaces"
+	case ExtremePoints:
+		algo = "ExtremePoints"
+	default:
+		return fmt.Sprintf("Heuristic(%#x)", uint16(e))
+	}
+	variant, ok := variantNames[e.Bin()]
+	if !ok {
+		return fmt.Sprintf("Heuristic(%#x)", uint16(e))
+	}
+	return algo + "-" + variant
+}
+
+// variantNames 是每种评分方式的名称
+var variantNames = map[Heuristic]string{
+	BestShortSideFit: "BestShortSideFit",
+	BestLongSideFit:  "BestLongSideFit",
+	BestVolumeFit:    "BestVolumeFit",
+	BottomLeft:       "BottomLeft",
+	ContactPoint:     "ContactPoint",
+}
+
+// ResolveAlgorithm 根据算法和变体名称返回启发式，名称无效时返回 99(与 rectpack 相同)
+func ResolveAlgorithm(algo, variant string) Heuristic {
+	var h Heuristic
+	switch algo {
+	case "MaxSpaces":
+		h = MaxSpaces
+	case "ExtremePoints":
+		h = ExtremePoints
+	default:
+		return 99
+	}
+	for fit, name := range variantNames {
+		if name == variant {
+			return h | fit
+		}
+	}
+	return 99
+}
+
+// score 是放置位置的评分，按顺序比较，越小越好
+type score [3]int
+
+// less 判断评分 s 是否优于 o
+func (s score) less(o score) bool {
+	return cmp.Or(cmp.Compare(s[0], o[0]), cmp.Compare(s[1], o[1]), cmp.Compare(s[2], o[2])) < 0
+}
+
+// fitScore 返回在剩余空间 space 的左上前角放置 w x h x d 的评分，ContactPoint 由算法单独计算
+func fitScore(fit Heuristic, space *Box3D, w, h, d int) score {
+	leftW, leftH, leftD := space.Width-w, space.Height-h, space.Depth-d
+	switch fit {
+	case BestLongSideFit:
+		return score{max(leftW, leftH, leftD), min(leftW, leftH, leftD)}
+	case BestVolumeFit:
+		return score{space.Volume() - w*h*d, min(leftW, leftH, leftD)}
+	case BottomLeft:
+		return score{space.Y + h, space.X + w, space.Z + d}
+	default: // BestShortSideFit
+		return score{min(leftW, leftH, leftD), max(leftW, leftH, leftD)}
+	}
+}
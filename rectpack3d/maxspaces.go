@@ -0,0 +1,80 @@
+package rectpack3d
+
+// maxSpaces 记录所有最大剩余空间，是 MaxRects 算法在三维的推广
+type maxSpaces struct {
+	*algorithmBase
+	free  []Box3D
+	split []Box3D // placeBox 复用的缓冲区
+}
+
+func (p *maxSpaces) reset() {
+	p.free = append(p.free[:0], NewBox(0, 0, 0, p.maxWidth, p.maxHeight, p.maxDepth))
+}
+
+func (p *maxSpaces) findPosition(w, h, d int) (Box3D, score, bool) {
+	var best Box3D
+	var bestScore score
+	found := false
+	for i := range p.free {
+		space := &p.free[i]
+		if space.Width < w || space.Height < h || space.Depth < d {
+			continue
+		}
+		s := p.score(space, w, h, d)
+		if !found || s.less(bestScore) {
+			best, bestScore, found = NewBox(space.X, space.Y, space.Z, w, h, d), s, true
+		}
+	}
+	return best, bestScore, found
+}
+
+// placeBox 把与节点相交的剩余空间分割为节点六个方向上的最大部分，再去掉被包含的部分
+func (p *maxSpaces) placeBox(node Box3D) {
+	p.split = p.split[:0]
+	kept := p.free[:0]
+	for _, space := range p.free {
+		if !space.Intersects(node) {
+			kept = append(kept, space)
+			continue
+		}
+		if node.X > space.X {
+			p.split = append(p.split, NewBox(space.X, space.Y, space.Z, node.X-space.X, space.Height, space.Depth))
+		}
+		if node.Right() < space.Right() {
+			p.split = append(p.split, NewBox(node.Right(), space.Y, space.Z, space.Right()-node.Right(), space.Height, space.Depth))
+		}
+		if node.Y > space.Y {
+			p.split = append(p.split, NewBox(space.X, space.Y, space.Z, space.Width, node.Y-space.Y, space.Depth))
+		}
+		if node.Bottom() < space.Bottom() {
+			p.split = append(p.split, NewBox(space.X, node.Bottom(), space.Z, space.Width, space.Bottom()-node.Bottom(), space.Depth))
+		}
+		if node.Z > space.Z {
+			p.split = append(p.split, NewBox(space.X, space.Y, space.Z, space.Width, space.Height, node.Z-space.Z))
+		}
+		if node.Back() < space.Back() {
+			p.split = append(p.split, NewBox(space.X, space.Y, node.Back(), space.Width, space.Height, space.Back()-node.Back()))
+		}
+	}
+	p.free = kept
+	// 未被分割的剩余空间仍是最大的，新的部分只需与所有空间比较
+	for i, space := range p.split {
+		contained := false
+		for j := range p.free {
+			if p.free[j].ContainsBox(space) {
+				contained = true
+				break
+			}
+		}
+		for j := range p.split {
+			if contained {
+				break
+			}
+			other := &p.split[j]
+			contained = j != i && other.ContainsBox(space) && (*other != space || j < i)
+		}
+		if !contained {
+			p.free = append(p.free, space)
+		}
+	}
+}
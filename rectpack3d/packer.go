@@ -0,0 +1,231 @@
+package rectpack3d
+
+import (
+	"fmt"
+	"slices"
+)
+
+// DefaultSize 是 NewDefaultPacker 使用的容器边长
+const DefaultSize = 1024
+
+// Packer 把长方体装入一个容器，用法与 rectpack.Packer 相同
+type Packer struct {
+	unpackedSize3Ds []Size3D
+	algo            packAlgorithm
+	heuristic       Heuristic
+	sortFunc        SortFunc
+	padding         int
+	sortRev         bool
+	allowRotate     bool
+	// Online 为 true 时 Insert 立即按插入顺序放置，否则暂存到 Pack 时排序后一次性放置
+	Online  bool
+	scratch []Size3D // 在线插入时复制可变参数的缓冲区
+}
+
+// NewPacker 创建并初始化一个新的长方体包装器
+// 参数:
+//
+//	maxWidth - 容器的最大宽度(必须大于0)
+//	maxHeight - 容器的最大高度(必须大于0)
+//	maxDepth - 容器的最大深度(必须大于0)
+//	heuristic - 包装算法和方法组合
+//
+// 返回:
+//
+//	*Packer - 初始化成功的包装器实例
+//	error - 如果参数无效则返回错误
+func NewPacker(maxWidth, maxHeight, maxDepth int, heuristic Heuristic) (*Packer, error) {
+	if maxWidth <= 0 || maxHeight <= 0 || maxDepth <= 0 {
+		return nil, fmt.Errorf("width, height and depth must be greater than 0 (given %vx%vx%v)", maxWidth, maxHeight, maxDepth)
+	}
+	if _, ok := variantNames[heuristic.Bin()]; !ok || heuristic.Algorithm() > ExtremePoints {
+		return nil, fmt.Errorf("invalid heuristic %#x", uint16(heuristic))
+	}
+	return &Packer{
+		heuristic: heuristic,
+		sortFunc:  SortVolume,
+		algo:      newPackAlgorithm(maxWidth, maxHeight, maxDepth, heuristic),
+	}, nil
+}
+
+// NewDefaultPacker 创建使用默认配置的包装器
+// 默认配置:
+//   - 最大尺寸: DefaultSize (1024x1024x1024)
+//   - 算法: MaxSpacesBSSF
+func NewDefaultPacker() *Packer {
+	packer, _ := NewPacker(DefaultSize, DefaultSize, DefaultSize, MaxSpacesBSSF)
+	return packer
+}
+
+// MaxSize 返回容器的尺寸
+func (p *Packer) MaxSize() Size3D {
+	return p.algo.MaxSize()
+}
+
+// GetIdMapOrientation 返回每个已放置ID使用的摆放方向
+func (p *Packer) GetIdMapOrientation() map[int]Orientation {
+	return p.algo.GetIdMapOrientation()
+}
+
+// MinSize 包含所有已包装长方体所需的最小尺寸
+func (p *Packer) MinSize() Size3D {
+	var size Size3D
+	for _, box := range p.algo.GetPackedBoxes() {
+		size.Width = max(size.Width, box.Right()+p.padding)
+		size.Height = max(size.Height, box.Bottom()+p.padding)
+		size.Depth = max(size.Depth, box.Back()+p.padding)
+	}
+	return size
+}
+
+// Insert 向包装器中插入多个尺寸，Quantity 大于1的尺寸会展开为多个实例
+// 在线模式下会立即尝试包装，离线模式下只是暂存尺寸
+// 返回:
+//
+//	在线模式下为无法包装的尺寸，离线模式下为所有暂存的尺寸(由内部管理，下次插入前有效)
+func (p *Packer) Insert(sizes ...Size3D) []Size3D {
+	sizes = expandQuantities(sizes)
+	if p.Online {
+		p.scratch = append(p.scratch[:0], sizes...)
+		return p.algo.Insert(p.padding, p.scratch...)
+	}
+	p.unpackedSize3Ds = append(p.unpackedSize3Ds, sizes...)
+	return p.unpackedSize3Ds
+}
+
+// InsertNewSize3D 向包装器中插入指定ID和尺寸的长方体
+// 返回是否插入/包装成功
+func (p *Packer) InsertNewSize3D(id, width, height, depth int) bool {
+	return len(p.Insert(NewSize3DByID(id, width, height, depth))) == 0
+}
+
+// SetSorter 设置离线打包时的排序函数，reverse 为 true 时反向排序，compare 为 nil 时保持插入顺序
+func (p *Packer) SetSorter(compare SortFunc, reverse bool) {
+	p.sortFunc, p.sortRev = compare, reverse
+}
+
+// SetPadding 设置长方体之间的间距
+func (p *Packer) SetPadding(padding int) {
+	p.padding = padding
+}
+
+// Padding 返回长方体之间的间距
+func (p *Packer) Padding() int {
+	return p.padding
+}
+
+// AllowRotate 设置是否允许旋转长方体，尺寸的 Orientations 可以进一步限制方向
+func (p *Packer) AllowRotate(enabled bool) {
+	p.allowRotate = enabled
+	p.algo.AllowRotate(enabled)
+}
+
+// GetPackedBoxes 获取所有已成功包装的长方体
+// 返回:
+//
+//	已包装长方体的切片(由内部管理，如需修改请复制)
+func (p *Packer) GetPackedBoxes() []Box3D {
+	return p.algo.GetPackedBoxes()
+}
+
+// GetUnpackedBoxes 获取所有暂存但未包装的尺寸
+// 返回:
+//
+//	未包装尺寸的切片(由内部管理，如需修改请复制)
+func (p *Packer) GetUnpackedBoxes() []Size3D {
+	return p.unpackedSize3Ds
+}
+
+// GetVolumeUsedRate 计算当前空间利用率
+// 参数:
+//
+//	current - true:相对于包含所有长方体的最小尺寸 false:相对于容器尺寸
+//
+// 返回:
+//
+//	空间利用率(0.0-1.0)
+func (p *Packer) GetVolumeUsedRate(current bool) float64 {
+	size := p.algo.MaxSize()
+	if current {
+		size = p.MinSize()
+	}
+	if size.Volume() == 0 {
+		return 0
+	}
+	return float64(p.algo.GetUsedVolume()) / float64(size.Volume())
+}
+
+// Reset 重置包装器状态(保留配置)
+// 清除所有已包装和暂存的长方体
+func (p *Packer) Reset() {
+	size := p.algo.MaxSize()
+	p.algo.Reset(size.Width, size.Height, size.Depth)
+	p.unpackedSize3Ds = p.unpackedSize3Ds[:0]
+}
+
+// ResetMaxSize 清除所有已包装和暂存的长方体，并重置容器尺寸
+// 返回:
+//
+//	true: 重置成功 false: 重置失败(尺寸无效)
+func (p *Packer) ResetMaxSize(maxWidth, maxHeight, maxDepth int) bool {
+	if maxWidth <= 0 || maxHeight <= 0 || maxDepth <= 0 {
+		return false
+	}
+	p.algo.Reset(maxWidth, maxHeight, maxDepth)
+	p.unpackedSize3Ds = p.unpackedSize3Ds[:0]
+	return true
+}
+
+// Pack 按排序规则排列暂存的长方体并一次性打包
+// 返回:
+//
+//	true: 全部打包成功 false: 部分失败(可通过GetUnpackedBoxes获取失败尺寸)
+func (p *Packer) Pack() bool {
+	if len(p.unpackedSize3Ds) == 0 {
+		return true
+	}
+	if p.sortFunc != nil {
+		if p.sortRev {
+			slices.SortStableFunc(p.unpackedSize3Ds, func(a, b Size3D) int { return p.sortFunc(b, a) })
+		} else {
+			slices.SortStableFunc(p.unpackedSize3Ds, p.sortFunc)
+		}
+	} else if p.sortRev {
+		slices.Reverse(p.unpackedSize3Ds)
+	}
+	failed := p.algo.Insert(p.padding, p.unpackedSize3Ds...)
+	// 失败的尺寸由算法内部管理，复制到暂存列表中
+	p.unpackedSize3Ds = append(p.unpackedSize3Ds[:0], failed...)
+	return len(p.unpackedSize3Ds) == 0
+}
+
+// newSubPacker 创建一个与当前包装器配置相同（算法、旋转、间距、排序）的新包装器
+func (p *Packer) newSubPacker(width, height, depth int) *Packer {
+	sub, _ := NewPacker(width, height, depth, p.heuristic)
+	sub.AllowRotate(p.allowRotate)
+	sub.SetPadding(p.padding)
+	sub.SetSorter(p.sortFunc, p.sortRev)
+	return sub
+}
+
+// expandQuantities 把 Quantity 大于1的尺寸展开为多个实例，ID 依次为 ID, ID+1, ..., ID+Quantity-1
+func expandQuantities(sizes []Size3D) []Size3D {
+	n := 0
+	for _, size := range sizes {
+		n += max(size.Quantity, 1)
+	}
+	if n == len(sizes) {
+		return sizes
+	}
+	expanded := make([]Size3D, 0, n)
+	for _, size := range sizes {
+		count := max(size.Quantity, 1)
+		size.Quantity = 0
+		for i := range count {
+			instance := size
+			instance.ID += i
+			expanded = append(expanded, instance)
+		}
+	}
+	return expanded
+}
@@ -0,0 +1,156 @@
+package rectpack3d
+
+import (
+	"math/rand/v2"
+	"slices"
+	"testing"
+)
+
+// checkLayout 检查长方体互不重叠、位于容器内、尺寸是原尺寸按允许方向的排列
+func checkLayout(t *testing.T, packer *Packer, sizes []Size3D) {
+	t.Helper()
+	bin := packer.MaxSize()
+	boxes := packer.GetPackedBoxes()
+	orient := packer.GetIdMapOrientation()
+	for i, box := range boxes {
+		if box.X < 0 || box.Y < 0 || box.Z < 0 || box.Right() > bin.Width || box.Bottom() > bin.Height || box.Back() > bin.Depth {
+			t.Fatalf("box %d at %s is outside the bin", box.ID, box.String())
+		}
+		size := sizes[slices.IndexFunc(sizes, func(s Size3D) bool { return s.ID == box.ID })]
+		if w, h, d := orient[box.ID].Apply(size); w != box.Width || h != box.Height || d != box.Depth {
+			t.Fatalf("box %d is %s, orientation %v of %s", box.ID, box.String(), orient[box.ID], size.ToString())
+		}
+		if size.allowed(true)&orient[box.ID] == 0 {
+			t.Fatalf("box %d uses forbidden orientation %v", box.ID, orient[box.ID])
+		}
+		inflated := box
+		inflated.X -= packer.Padding()
+		inflated.Y -= packer.Padding()
+		inflated.Z -= packer.Padding()
+		inflated.Width += packer.Padding() * 2
+		inflated.Height += packer.Padding() * 2
+		inflated.Depth += packer.Padding() * 2
+		for _, other := range boxes[i+1:] {
+			if inflated.Intersects(other) {
+				t.Fatalf("box %d at %s overlaps box %d at %s", box.ID, box.String(), other.ID, other.String())
+			}
+		}
+	}
+}
+
+func randomBoxes(seed uint64, n int) []Size3D {
+	r := rand.New(rand.NewPCG(seed, seed))
+	sizes := make([]Size3D, n)
+	for i := range sizes {
+		sizes[i] = NewSize3DByID(i, r.IntN(30)+5, r.IntN(30)+5, r.IntN(30)+5)
+		if i%3 == 0 {
+			sizes[i].Orientations = OrientUpright
+		}
+	}
+	return sizes
+}
+
+func TestPack(t *testing.T) {
+	sizes := randomBoxes(1, 120)
+	for _, heuristic := range Heuristics {
+		packer, err := NewPacker(100, 80, 100, heuristic)
+		if err != nil {
+			t.Fatal(err)
+		}
+		packer.AllowRotate(true)
+		packer.SetPadding(1)
+		packer.Insert(sizes...)
+		packer.Pack()
+		checkLayout(t, packer, sizes)
+		packed := len(packer.GetPackedBoxes())
+		if packed+len(packer.GetUnpackedBoxes()) != len(sizes) {
+			t.Fatalf("%v: %d packed + %d unpacked, want %d", heuristic, packed, len(packer.GetUnpackedBoxes()), len(sizes))
+		}
+		if rate := packer.GetVolumeUsedRate(false); rate < 0.4 {
+			t.Errorf("%v: volume used rate %.2f", heuristic, rate)
+		}
+	}
+
+	// 8 个立方体正好填满容器
+	for _, heuristic := range []Heuristic{MaxSpacesBSSF, ExtremePointsBL} {
+		packer, _ := NewPacker(20, 20, 20, heuristic)
+		packer.Insert(Size3D{Width: 10, Height: 10, Depth: 10, Quantity: 8})
+		if !packer.Pack() || packer.GetVolumeUsedRate(false) != 1 {
+			t.Errorf("%v: cubes do not fill the bin", heuristic)
+		}
+	}
+}
+
+func TestOrientations(t *testing.T) {
+	tall := NewSize3DByID(1, 10, 50, 10)
+	packer, _ := NewPacker(50, 20, 50, MaxSpacesBSSF)
+	packer.AllowRotate(true)
+	packer.Insert(tall)
+	if !packer.Pack() || packer.GetIdMapOrientation()[1] == OrientWHD {
+		t.Fatal("a tall box should be laid down to fit")
+	}
+
+	upright := tall
+	upright.Orientations = OrientUpright
+	packer.Reset()
+	packer.Insert(upright)
+	if packer.Pack() {
+		t.Error("an upright box must not be laid down")
+	}
+
+	// 只允许非原方向时不能退回原方向，即使原方向也放得下
+	cube := NewSize3DByID(2, 10, 10, 20)
+	cube.Orientations = OrientDHW
+	packer.Reset()
+	packer.Insert(cube)
+	if !packer.Pack() || packer.GetIdMapOrientation()[2] != OrientDHW {
+		t.Errorf("box restricted to %v was placed as %v", OrientDHW, packer.GetIdMapOrientation()[2])
+	}
+	square := NewSize3DByID(3, 10, 20, 10)
+	square.Orientations = OrientDHW // 宽和深相同，与原方向尺寸一致
+	packer.Reset()
+	packer.Insert(square)
+	if !packer.Pack() || packer.GetIdMapOrientation()[3] != OrientDHW {
+		t.Errorf("box restricted to %v was placed as %v", OrientDHW, packer.GetIdMapOrientation()[3])
+	}
+
+	packer.Reset()
+	packer.AllowRotate(false)
+	packer.Insert(tall)
+	if packer.Pack() {
+		t.Error("rotation is disabled")
+	}
+}
+
+func TestPackCatalog(t *testing.T) {
+	sizes := randomBoxes(2, 200)
+	packer, _ := NewPacker(100, 100, 100, ExtremePointsBSSF)
+	packer.AllowRotate(true)
+	packer.Insert(sizes...)
+	pages, ok := packer.PackCatalog([]BinType{{Width: 100, Height: 100, Depth: 100, Cost: 10}, {Width: 60, Height: 60, Depth: 60, Cost: 3}})
+	if !ok {
+		t.Fatalf("%d boxes left unpacked", len(packer.GetUnpackedBoxes()))
+	}
+	placed := 0
+	for _, page := range pages {
+		checkLayout(t, page.Packer, sizes)
+		placed += len(page.Packer.GetPackedBoxes())
+	}
+	if placed != len(sizes) || len(pages) < 2 {
+		t.Errorf("placed %d boxes in %d bins", placed, len(pages))
+	}
+
+	// 只有大容器能放下的长方体不应因为先选了便宜的小容器而多用一个容器
+	packer.Reset()
+	packer.Insert(NewSize3DByID(0, 90, 90, 90))
+	for i := 1; i < 20; i++ {
+		packer.Insert(NewSize3DByID(i, 10, 10, 10))
+	}
+	pages, ok = packer.PackCatalog([]BinType{{Width: 100, Height: 100, Depth: 100, Cost: 1e6}, {Width: 50, Height: 50, Depth: 50, Cost: 125000}})
+	if !ok || len(pages) != 1 || pages[0].Bin.Width != 100 {
+		t.Errorf("opened %d bins, want a single 100x100x100 bin", len(pages))
+	}
+	if h := ResolveAlgorithm("ExtremePoints", "BottomLeft"); h != ExtremePointsBL || h.String() != "ExtremePoints-BottomLeft" {
+		t.Errorf("ResolveAlgorithm returned %v", h)
+	}
+}
@@ -0,0 +1,36 @@
+package rectpack3d
+
+import "cmp"
+
+// SortFunc 定义长方体尺寸比较函数的原型
+// 返回值:
+//
+//	-1: a < b
+//	 0: a == b
+//	 1: a > b
+type SortFunc func(a, b Size3D) int
+
+// SortVolume 按体积降序排序(从大到小)
+func SortVolume(a, b Size3D) int {
+	return cmp.Compare(b.Volume(), a.Volume())
+}
+
+// SortSurfaceArea 按表面积降序排序(从大到小)
+func SortSurfaceArea(a, b Size3D) int {
+	return cmp.Compare(b.SurfaceArea(), a.SurfaceArea())
+}
+
+// SortMaxSide 按最长边降序排序(从大到小)
+func SortMaxSide(a, b Size3D) int {
+	return cmp.Compare(b.MaxSide(), a.MaxSide())
+}
+
+// SortMinSide 按最短边降序排序(从大到小)
+func SortMinSide(a, b Size3D) int {
+	return cmp.Compare(b.MinSide(), a.MinSide())
+}
+
+// SortHeight 按高度降序排序(从大到小)，适合逐层堆放
+func SortHeight(a, b Size3D) int {
+	return cmp.Compare(b.Height, a.Height)
+}
@@ -0,0 +1,473 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"encoding/csv"
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"rectpack2d/rectpack"
+	"rectpack2d/rectpack/instances"
+	"rectpack2d/rectpack/render"
+	"slices"
+	"strconv"
+	"strings"
+)
+
+// rectID 是输入矩形的标识，JSON 中可以是字符串或数字
+type rectID string
+
+// UnmarshalJSON 接受字符串或数字形式的标识
+func (id *rectID) UnmarshalJSON(data []byte) error {
+	if len(data) > 0 && data[0] == '"' {
+		var s string
+		if err := json.Unmarshal(data, &s); err != nil {
+			return err
+		}
+		*id = rectID(s)
+		return nil
+	}
+	var n json.Number
+	if err := json.Unmarshal(data, &n); err != nil {
+		return fmt.Errorf("id must be a string or number: %w", err)
+	}
+	*id = rectID(n)
+	return nil
+}
+
+// rectItem 是 rects 子命令输入的一种矩形
+type rectItem struct {
+	ID       rectID `json:"id"`
+	Width    int    `json:"width"`
+	Height   int    `json:"height"`
+	Quantity int    `json:"quantity,omitempty"` // 数量，小于1时视为1
+	Rotate   *bool  `json:"rotate,omitempty"`   // false 时禁止旋转，未指定时由全局选项决定
+}
+
+// rectsInput 是 rects 子命令读取的输入
+type rectsInput struct {
+	Bin    *rectpack.Size2D `json:"bin,omitempty"`    // 输入中指定的容器尺寸，命令行未指定 -width/-height 时使用
+	Rotate *bool            `json:"rotate,omitempty"` // 输入中指定的是否允许旋转，命令行未指定 -rotate 时使用
+	Items  []rectItem       `json:"items"`
+}
+
+// rectPlacement 是一个矩形的放置结果，Width 和 Height 为旋转后的尺寸
+type rectPlacement struct {
+	ID      string `json:"id"`
+	Copy    int    `json:"copy"` // 数量大于1时是第几个，从0开始
+	Bin     int    `json:"bin"`  // 所在容器的序号，未能放置时为-1
+	X       int    `json:"x"`
+	Y       int    `json:"y"`
+	Width   int    `json:"width"`
+	Height  int    `json:"height"`
+	Rotated bool   `json:"rotated"`
+}
+
+// rectsBin 是打包时打开的一个容器
+type rectsBin struct {
+	Index  int     `json:"index"`
+	Width  int     `json:"width"`
+	Height int     `json:"height"`
+	Fill   float64 `json:"fill"` // 已放置矩形面积占容器面积的比例
+}
+
+// rectsResult 是 rects 子命令的输出
+type rectsResult struct {
+	Heuristic  string          `json:"heuristic"`
+	Bins       []rectsBin      `json:"bins"`
+	Placements []rectPlacement `json:"placements"`
+	Unpacked   []rectPlacement `json:"unpacked,omitempty"`
+}
+
+// rectsOptions 是 rects 子命令的选项
+type rectsOptions struct {
+	Width       int
+	Height      int
+	AllowRotate bool
+	Padding     int
+	Algorithm   rectpack.Heuristic
+	Bins        []rectpack.BinType
+	Improve     int
+}
+
+// runRects 执行 rects 子命令：读取纯矩形列表并打包，输出放置结果，返回进程退出码
+func runRects(args []string, stdin io.Reader, stdout, stderr io.Writer) int {
+	flags := flag.NewFlagSet("rects", flag.ContinueOnError)
+	flags.SetOutput(stderr)
+	formatPtr := flags.String("format", "", "输入格式 (text, csv, json)，默认按扩展名或内容判断")
+	outputPtr := flags.String("o", "", "输出文件，默认为标准输出")
+	outFormatPtr := flags.String("output-format", "", "输出格式 (json, csv)，默认按输出文件扩展名判断，否则为 json")
+	svgPtr := flags.String("svg", "", "同时输出 SVG 预览图的路径，多个容器时按序号分别输出，例如 out_0.svg")
+	widthPtr := flags.Int("width", 4096, "容器宽度，未指定时使用输入中的容器尺寸")
+	heightPtr := flags.Int("height", 4096, "容器高度，未指定时使用输入中的容器尺寸")
+	rotationPtr := flags.Bool("rotate", true, "允许矩形旋转，未指定时使用输入中的设置")
+	paddingPtr := flags.Int("padding", 0, "填充")
+	algorithmPtr := flags.String("algorithm", "MaxRects", "打包算法 (MaxRects, Guillotine)")
+	variantPtr := flags.String("variant", "BestAreaFit", "打包算法变体 (BestShortSideFit, BestLongSideFit, BestAreaFit, BottomLeft, ContactPoint, WorstAreaFit, ...)")
+	binsPtr := flags.String("bins", "", "容器尺寸目录，逗号分隔的 宽x高[:成本[:数量]]，为空时使用同一尺寸的容器")
+	improvePtr := flags.Int("improve", 0, "打包后局部搜索每个容器的最大迭代次数，0 表示不搜索，负数表示直到无法改进")
+	flags.Usage = func() {
+		fmt.Fprintln(stderr, "用法: rectpack2d rects [选项] [输入文件]")
+		fmt.Fprintln(stderr, "读取矩形列表(data.txt 格式、CSV 或 JSON)并打包，输入文件为空或 - 时读取标准输入")
+		flags.PrintDefaults()
+	}
+	if err := flags.Parse(args); err != nil {
+		if errors.Is(err, flag.ErrHelp) {
+			return 0
+		}
+		return 2
+	}
+	if flags.NArg() > 1 {
+		flags.Usage()
+		return 2
+	}
+	fail := func(format string, args ...any) int {
+		fmt.Fprintf(stderr, format+"\n", args...)
+		return 1
+	}
+
+	path := flags.Arg(0)
+	var data []byte
+	var err error
+	if path == "" || path == "-" {
+		data, err = io.ReadAll(stdin)
+	} else {
+		data, err = os.ReadFile(path)
+	}
+	if err != nil {
+		return fail("读取输入失败: %v", err)
+	}
+	input, err := readRects(data, rectsInputFormat(*formatPtr, path, data))
+	if err != nil {
+		return fail("解析输入失败: %v", err)
+	}
+
+	set := make(map[string]bool)
+	flags.Visit(func(f *flag.Flag) { set[f.Name] = true })
+	options := rectsOptions{
+		Width:       *widthPtr,
+		Height:      *heightPtr,
+		AllowRotate: *rotationPtr,
+		Padding:     *paddingPtr,
+		Algorithm:   rectpack.ResolveAlgorithm(*algorithmPtr, *variantPtr),
+		Improve:     *improvePtr,
+	}
+	if input.Bin != nil && !set["width"] && !set["height"] {
+		options.Width, options.Height = input.Bin.Width, input.Bin.Height
+	}
+	if input.Rotate != nil && !set["rotate"] {
+		options.AllowRotate = *input.Rotate
+	}
+	if !slices.Contains(rectpack.Heuristics, options.Algorithm) {
+		return fail("未知的打包算法: %s %s", *algorithmPtr, *variantPtr)
+	}
+	if *binsPtr != "" {
+		if options.Bins, err = rectpack.ParseBinCatalog(*binsPtr); err != nil {
+			return fail("容器尺寸目录无效: %v", err)
+		}
+	}
+
+	result, err := packRects(input, &options)
+	if err != nil {
+		return fail("打包失败: %v", err)
+	}
+	fmt.Fprintf(stderr, "容器数量: %d, 已放置矩形: %d, 未放置矩形: %d\n", len(result.Bins), len(result.Placements), len(result.Unpacked))
+	for _, r := range result.Unpacked {
+		fmt.Fprintf(stderr, "  已跳过 %s#%d (%dx%d)\n", r.ID, r.Copy, r.Width, r.Height)
+	}
+
+	outFormat := *outFormatPtr
+	if outFormat == "" {
+		outFormat = "json"
+		if strings.EqualFold(filepath.Ext(*outputPtr), ".csv") {
+			outFormat = "csv"
+		}
+	}
+	var buf bytes.Buffer
+	switch outFormat {
+	case "json":
+		err = writeRectsJSON(&buf, result)
+	case "csv":
+		err = writeRectsCSV(&buf, result)
+	default:
+		return fail("未知的输出格式: %s", outFormat)
+	}
+	if err != nil {
+		return fail("生成输出失败: %v", err)
+	}
+	if *outputPtr == "" {
+		_, err = stdout.Write(buf.Bytes())
+	} else {
+		err = os.WriteFile(*outputPtr, buf.Bytes(), 0644)
+	}
+	if err != nil {
+		return fail("写入输出失败: %v", err)
+	}
+	if *svgPtr != "" {
+		scenes := rectsScenes(result, options.Padding)
+		opts := &render.Options{Label: func(id int) string { return result.Placements[id].ID }}
+		for i, scene := range scenes {
+			buf.Reset()
+			if err := render.SVG(&buf, scene, opts); err != nil {
+				return fail("生成 SVG 失败: %v", err)
+			}
+			if err := os.WriteFile(rectsSVGPath(*svgPtr, i, len(scenes)), buf.Bytes(), 0644); err != nil {
+				return fail("写入 SVG 失败: %v", err)
+			}
+		}
+	}
+	return 0
+}
+
+// rectsInputFormat 确定输入格式：优先使用 -format，其次按扩展名，最后按内容判断
+func rectsInputFormat(format, path string, data []byte) string {
+	if format != "" {
+		return format
+	}
+	switch strings.ToLower(filepath.Ext(path)) {
+	case ".json":
+		return "json"
+	case ".csv":
+		return "csv"
+	case ".txt":
+		return "text"
+	}
+	trimmed := bytes.TrimSpace(data)
+	if len(trimmed) > 0 && (trimmed[0] == '[' || trimmed[0] == '{') {
+		return "json"
+	}
+	if line, _, _ := bytes.Cut(trimmed, []byte("\n")); bytes.ContainsRune(line, ',') {
+		return "csv"
+	}
+	return "text"
+}
+
+// readRects 按指定格式解析输入
+func readRects(data []byte, format string) (*rectsInput, error) {
+	switch format {
+	case "text":
+		return readRectsText(data)
+	case "csv":
+		return readRectsCSV(data)
+	case "json":
+		return readRectsJSON(data)
+	}
+	return nil, fmt.Errorf("unknown input format %q", format)
+}
+
+// readRectsText 解析 data.txt 格式：首行为 "W H rotate"，其余每行为 "w h"，矩形的标识为行的序号
+func readRectsText(data []byte) (*rectsInput, error) {
+	inst, err := instances.ReadText(bytes.NewReader(data))
+	if err != nil {
+		return nil, err
+	}
+	input := &rectsInput{Bin: &inst.Bin, Rotate: &inst.Rotate}
+	for _, size := range inst.Items {
+		input.Items = append(input.Items, rectItem{ID: rectID(strconv.Itoa(size.ID)), Width: size.Width, Height: size.Height})
+	}
+	return input, nil
+}
+
+// readRectsCSV 解析带表头的 CSV，列名不区分大小写：
+// width(w) 和 height(h) 必需，id、quantity(qty) 和 rotate 可选，缺少 id 时以行的序号作为标识
+func readRectsCSV(data []byte) (*rectsInput, error) {
+	reader := csv.NewReader(bytes.NewReader(data))
+	reader.TrimLeadingSpace = true
+	records, err := reader.ReadAll()
+	if err != nil {
+		return nil, err
+	}
+	if len(records) == 0 {
+		return nil, errors.New("empty csv input")
+	}
+	columns := map[string]int{"id": -1, "width": -1, "height": -1, "quantity": -1, "rotate": -1}
+	aliases := map[string]string{"w": "width", "h": "height", "qty": "quantity"}
+	for i, name := range records[0] {
+		name = strings.ToLower(strings.TrimSpace(name))
+		if alias, ok := aliases[name]; ok {
+			name = alias
+		}
+		if _, ok := columns[name]; ok {
+			columns[name] = i
+		}
+	}
+	if columns["width"] < 0 || columns["height"] < 0 {
+		return nil, errors.New("csv header must have width and height columns")
+	}
+	input := &rectsInput{}
+	for line, record := range records[1:] {
+		field := func(name string) string {
+			if i := columns[name]; i >= 0 && i < len(record) {
+				return strings.TrimSpace(record[i])
+			}
+			return ""
+		}
+		item := rectItem{ID: rectID(field("id"))}
+		if item.ID == "" {
+			item.ID = rectID(strconv.Itoa(line))
+		}
+		if item.Width, err = strconv.Atoi(field("width")); err != nil {
+			return nil, fmt.Errorf("line %d: invalid width %q", line+2, field("width"))
+		}
+		if item.Height, err = strconv.Atoi(field("height")); err != nil {
+			return nil, fmt.Errorf("line %d: invalid height %q", line+2, field("height"))
+		}
+		if s := field("quantity"); s != "" {
+			if item.Quantity, err = strconv.Atoi(s); err != nil {
+				return nil, fmt.Errorf("line %d: invalid quantity %q", line+2, s)
+			}
+		}
+		if s := field("rotate"); s != "" {
+			rotate, err := strconv.ParseBool(s)
+			if err != nil {
+				return nil, fmt.Errorf("line %d: invalid rotate %q", line+2, s)
+			}
+			item.Rotate = &rotate
+		}
+		input.Items = append(input.Items, item)
+	}
+	return input, nil
+}
+
+// readRectsJSON 解析矩形数组，或包含 bin、rotate 和 items 的对象
+func readRectsJSON(data []byte) (*rectsInput, error) {
+	input := &rectsInput{}
+	trimmed := bytes.TrimSpace(data)
+	if len(trimmed) > 0 && trimmed[0] == '[' {
+		return input, json.Unmarshal(trimmed, &input.Items)
+	}
+	return input, json.Unmarshal(trimmed, input)
+}
+
+// packRects 按选项打包输入的矩形，放不下的矩形依次打开新的容器
+func packRects(input *rectsInput, options *rectsOptions) (*rectsResult, error) {
+	bins := options.Bins
+	if len(bins) == 0 {
+		bins = []rectpack.BinType{{Width: options.Width, Height: options.Height, Cost: 1}}
+	}
+	var maxW, maxH int
+	for _, bin := range bins {
+		maxW, maxH = max(maxW, bin.Width), max(maxH, bin.Height)
+	}
+	packer, err := rectpack.NewPacker(maxW, maxH, options.Algorithm)
+	if err != nil {
+		return nil, err
+	}
+	packer.AllowRotate(options.AllowRotate)
+	packer.SetPadding(options.Padding)
+
+	// owners 按打包时使用的 ID 记录每个矩形对应的输入项和序号
+	type owner struct{ item, copy int }
+	var owners []owner
+	sizes := make([]rectpack.Size2D, 0, len(input.Items))
+	for i, item := range input.Items {
+		if item.Width <= 0 || item.Height <= 0 {
+			return nil, fmt.Errorf("rect %q has invalid size %dx%d", item.ID, item.Width, item.Height)
+		}
+		quantity := max(item.Quantity, 1)
+		size := rectpack.NewSize2DByID(len(owners), item.Width, item.Height)
+		size.NoRotate = item.Rotate != nil && !*item.Rotate
+		if quantity > 1 {
+			size.Quantity = quantity
+		}
+		sizes = append(sizes, size)
+		for c := range quantity {
+			owners = append(owners, owner{i, c})
+		}
+	}
+	packer.Insert(sizes...)
+	pages, _ := packer.PackCatalog(bins)
+	if options.Improve != 0 {
+		if pages, err = rectpack.ImprovePages(context.Background(), pages, max(options.Improve, 0)); err != nil {
+			return nil, err
+		}
+	}
+
+	result := &rectsResult{Heuristic: options.Algorithm.String(), Placements: make([]rectPlacement, 0, len(owners))}
+	for index, page := range pages {
+		rotated := page.Packer.GetIdMapRotated()
+		used := 0
+		for _, rect := range page.Packer.GetPackedRects() {
+			o := owners[rect.ID]
+			result.Placements = append(result.Placements, rectPlacement{
+				ID:      string(input.Items[o.item].ID),
+				Copy:    o.copy,
+				Bin:     index,
+				X:       rect.X,
+				Y:       rect.Y,
+				Width:   rect.Width,
+				Height:  rect.Height,
+				Rotated: rotated[rect.ID],
+			})
+			used += rect.Area()
+		}
+		result.Bins = append(result.Bins, rectsBin{
+			Index:  index,
+			Width:  page.Bin.Width,
+			Height: page.Bin.Height,
+			Fill:   float64(used) / float64(page.Bin.Width*page.Bin.Height),
+		})
+	}
+	for _, size := range packer.GetUnpackedRects() {
+		o := owners[size.ID]
+		result.Unpacked = append(result.Unpacked, rectPlacement{
+			ID:     string(input.Items[o.item].ID),
+			Copy:   o.copy,
+			Bin:    -1,
+			Width:  size.Width,
+			Height: size.Height,
+		})
+	}
+	return result, nil
+}
+
+// writeRectsJSON 以 JSON 格式输出结果
+func writeRectsJSON(w io.Writer, result *rectsResult) error {
+	encoder := json.NewEncoder(w)
+	encoder.SetIndent("", "  ")
+	return encoder.Encode(result)
+}
+
+// writeRectsCSV 以 CSV 格式输出结果，每行一个矩形，未能放置的矩形 bin 为-1，x 和 y 为空
+func writeRectsCSV(w io.Writer, result *rectsResult) error {
+	writer := csv.NewWriter(w)
+	writer.Write([]string{"id", "copy", "bin", "x", "y", "width", "height", "rotated"})
+	for _, p := range result.Placements {
+		writer.Write([]string{p.ID, strconv.Itoa(p.Copy), strconv.Itoa(p.Bin), strconv.Itoa(p.X), strconv.Itoa(p.Y),
+			strconv.Itoa(p.Width), strconv.Itoa(p.Height), strconv.FormatBool(p.Rotated)})
+	}
+	for _, p := range result.Unpacked {
+		writer.Write([]string{p.ID, strconv.Itoa(p.Copy), "-1", "", "", strconv.Itoa(p.Width), strconv.Itoa(p.Height), "false"})
+	}
+	writer.Flush()
+	return writer.Error()
+}
+
+// rectsScenes 返回每个容器的绘制场景，矩形的 ID 为其在 result.Placements 中的下标
+func rectsScenes(result *rectsResult, padding int) []render.Scene {
+	scenes := make([]render.Scene, len(result.Bins))
+	for i, bin := range result.Bins {
+		scenes[i] = render.Scene{Bin: rectpack.NewSize2D(bin.Width, bin.Height), Rotated: make(map[int]bool), Padding: padding}
+	}
+	for i, p := range result.Placements {
+		rect := rectpack.NewRect(p.X, p.Y, p.Width, p.Height)
+		rect.ID = i
+		scenes[p.Bin].Rects = append(scenes[p.Bin].Rects, rect)
+		scenes[p.Bin].Rotated[i] = p.Rotated
+	}
+	return scenes
+}
+
+// rectsSVGPath 返回第 page 个容器的 SVG 路径，多个容器时在扩展名前加上序号，例如 out_0.svg
+func rectsSVGPath(path string, page, pages int) string {
+	if pages == 1 {
+		return path
+	}
+	ext := filepath.Ext(path)
+	return fmt.Sprintf("%s_%d%s", strings.TrimSuffix(path, ext), page, ext)
+}
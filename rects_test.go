@@ -0,0 +1,71 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestReadRects(t *testing.T) {
+	inputs := map[string]string{
+		"text": "100 80 0\n50 40\n50 40\n30 20\n",
+		"csv":  "ID, Width, Height, Qty, Rotate\na,50,40,2,\nb,30,20,,false\n",
+		"json": `{"bin":{"width":100,"height":80},"rotate":false,"items":[{"id":"a","width":50,"height":40,"quantity":2},{"id":"b","width":30,"height":20,"rotate":false}]}`,
+	}
+	for format, data := range inputs {
+		if got := rectsInputFormat("", "-", []byte(data)); got != format {
+			t.Errorf("%s: detected %s", format, got)
+		}
+		input, err := readRects([]byte(data), format)
+		if err != nil {
+			t.Fatalf("%s: %v", format, err)
+		}
+		count := 0
+		for _, item := range input.Items {
+			count += max(item.Quantity, 1)
+		}
+		if count != 3 {
+			t.Errorf("%s: got %d rects, want 3", format, count)
+		}
+	}
+	if _, err := readRects([]byte("w,h\n10,x\n"), "csv"); err == nil {
+		t.Error("expected an error for an invalid height")
+	}
+}
+
+func TestRunRects(t *testing.T) {
+	dir := t.TempDir()
+	svg := filepath.Join(dir, "out.svg")
+	stdin := strings.NewReader(`[{"id":1,"width":50,"height":40,"quantity":5},{"id":"wide","width":500,"height":10}]`)
+	var stdout, stderr bytes.Buffer
+	code := runRects([]string{"-width", "100", "-height", "80", "-algorithm", "Guillotine", "-variant", "BestShortSideFit", "-svg", svg}, stdin, &stdout, &stderr)
+	if code != 0 {
+		t.Fatalf("exit code %d: %s", code, stderr.String())
+	}
+	var result rectsResult
+	if err := json.Unmarshal(stdout.Bytes(), &result); err != nil {
+		t.Fatal(err)
+	}
+	if len(result.Placements) != 5 || len(result.Unpacked) != 1 || result.Unpacked[0].ID != "wide" {
+		t.Fatalf("unexpected result %+v", result)
+	}
+	for _, p := range result.Placements {
+		if p.ID != "1" || p.Bin < 0 || p.Bin >= len(result.Bins) || p.X+p.Width > 100 || p.Y+p.Height > 80 {
+			t.Fatalf("invalid placement %+v", p)
+		}
+	}
+	// 每个容器一个 SVG
+	for i := range result.Bins {
+		if data, err := os.ReadFile(rectsSVGPath(svg, i, len(result.Bins))); err != nil || !bytes.HasPrefix(data, []byte("<svg")) {
+			t.Fatalf("svg of bin %d not written: %v", i, err)
+		}
+	}
+
+	stdin = strings.NewReader("10 10\n5 5\n")
+	if code := runRects([]string{"-variant", "Nope"}, stdin, &stdout, &stderr); code != 1 {
+		t.Fatalf("unknown variant: exit code %d", code)
+	}
+}
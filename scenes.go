@@ -0,0 +1,120 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"rectpack2d/rectpack"
+	"slices"
+	"strings"
+	"time"
+)
+
+// sceneEntry 是场景文件中的一个场景，可以是精灵文件名的数组，或带权重的对象
+//
+//	{"battle": ["hero.png", "enemy.png"], "menu": {"weight": 3, "sprites": ["button.png"]}}
+type sceneEntry struct {
+	Weight  float64  `json:"weight,omitempty"`
+	Sprites []string `json:"sprites"`
+}
+
+// UnmarshalJSON 接受精灵文件名的数组或 {"weight", "sprites"} 对象
+func (e *sceneEntry) UnmarshalJSON(data []byte) error {
+	if len(data) > 0 && data[0] == '[' {
+		return json.Unmarshal(data, &e.Sprites)
+	}
+	type plain sceneEntry
+	return json.Unmarshal(data, (*plain)(e))
+}
+
+// loadScenes 读取场景文件，把精灵文件名(可省略扩展名)映射为图片的ID
+func loadScenes(path string, imagePaths []string) ([]rectpack.Scene, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	var entries map[string]sceneEntry
+	if err := json.Unmarshal(data, &entries); err != nil {
+		return nil, fmt.Errorf("解析场景文件 %s 失败: %v", path, err)
+	}
+	ids := make(map[string]int, 2*len(imagePaths))
+	for i, p := range imagePaths {
+		name := filepath.Base(p)
+		ids[name] = i
+		ids[strings.TrimSuffix(name, filepath.Ext(name))] = i
+	}
+	// 按名称排序，使结果与 JSON 中的顺序无关
+	names := make([]string, 0, len(entries))
+	for name := range entries {
+		names = append(names, name)
+	}
+	slices.Sort(names)
+	scenes := make([]rectpack.Scene, 0, len(names))
+	for _, name := range names {
+		entry := entries[name]
+		scene := rectpack.Scene{Name: name, Weight: entry.Weight}
+		for _, sprite := range entry.Sprites {
+			id, ok := ids[sprite]
+			if !ok {
+				fmt.Printf("警告: 场景 %s 中的精灵 %s 不在输入目录中\n", name, sprite)
+				continue
+			}
+			scene.IDs = append(scene.IDs, id)
+		}
+		scenes = append(scenes, scene)
+	}
+	return scenes, nil
+}
+
+// packingScenes 按场景的共用关系把图片分配到多个图集，使每个场景用到的图集尽量少
+// 返回每一页的打包器
+func packingScenes(sizes []rectpack.Size2D, imagePaths []string, options *Options) []*rectpack.Packer {
+	scenes, err := loadScenes(options.ScenesPath, imagePaths)
+	if err != nil {
+		fmt.Printf("读取场景文件失败: %v\n", err)
+		os.Exit(1)
+	}
+	if debugInfo.IsDebug {
+		start := time.Now()
+		defer func() {
+			debugInfo.PackTime += time.Since(start)
+		}()
+	}
+	packer, err := rectpack.NewPacker(options.AtlasMaxWidth, options.AtlasMaxHeight, options.Algorithm)
+	if err != nil {
+		fmt.Printf("创建打包器失败: %v\n", err)
+		os.Exit(1)
+	}
+	packer.AllowRotate(options.IsAllowRotate)
+	packer.SetPadding(options.SpritePadding)
+	packer.Insert(sizes...)
+	pages, successful := packer.PackScenes(scenes)
+
+	var packers []*rectpack.Packer
+	for i, page := range pages {
+		fmt.Printf("图集 #%d:\n", i)
+		if options.IsAutoSize {
+			page.Packer.Shrink()
+		}
+		outputResult(page.Packer)
+		packers = append(packers, page.Packer)
+	}
+	// 每个场景绑定一次用到的每个图集，估计为一次绘制调用
+	var total, weighted float64
+	for _, usage := range rectpack.SceneUsages(scenes, pages) {
+		fmt.Printf("场景 %s: %d 次绘制调用, 图集 %v\n", usage.Name, usage.DrawCalls, usage.Pages)
+		total += usage.Weight
+		weighted += usage.Weight * float64(usage.DrawCalls)
+	}
+	if total > 0 {
+		fmt.Printf("图集数量: %d, 场景平均绘制调用(按权重): %.2f\n", len(pages), weighted/total)
+	}
+	if !successful {
+		fmt.Println("警告: 部分图片无法打包到指定尺寸的图集中")
+		for _, size := range packer.GetUnpackedRects() {
+			fmt.Printf("  已跳过 %s (%dx%d)\n", filepath.Base(imagePaths[size.ID]), size.Width, size.Height)
+		}
+	}
+	return packers
+}
@@ -0,0 +1,126 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"rectpack2d/rectpack"
+	"strconv"
+	"strings"
+)
+
+// GridInfo 是网格和条带布局的图集在元数据中的网格信息
+type GridInfo struct {
+	CellSize struct {
+		W int `json:"w"`
+		H int `json:"h"`
+	} `json:"cellSize"`
+	Columns    int             `json:"columns"`
+	Rows       int             `json:"rows"`
+	Spacing    int             `json:"spacing"`
+	Margin     int             `json:"margin"`
+	Animations []AnimationInfo `json:"animations,omitempty"`
+}
+
+// AnimationInfo 是按动画分行时一个动画在图集中的位置
+type AnimationInfo struct {
+	Name   string `json:"name"`
+	Row    int    `json:"row"`    // 第一帧所在的行
+	Frames int    `json:"frames"` // 在该图集中的帧数
+}
+
+// CellInfo 是精灵所在的单元格，Index 为按行排列的序号
+type CellInfo struct {
+	Column int `json:"column"`
+	Row    int `json:"row"`
+	Index  int `json:"index"`
+}
+
+// animationName 返回文件名去掉扩展名和末尾编号后的动画名，例如 walk_01.png -> walk
+func animationName(path string) string {
+	name := filepath.Base(path)
+	name = strings.TrimSuffix(name, filepath.Ext(name))
+	if trimmed := strings.TrimRight(strings.TrimRight(name, "0123456789"), "_-. "); trimmed != "" {
+		return trimmed
+	}
+	return name
+}
+
+// assignAnimations 按动画名为相邻的帧设置分组，每个动画从新的一行开始
+func assignAnimations(sizes []rectpack.Size2D, imagePaths []string) {
+	group, last := 0, ""
+	for i := range sizes {
+		if name := animationName(imagePaths[sizes[i].ID]); group == 0 || name != last {
+			group, last = group+1, name
+		}
+		sizes[i].Group = group
+	}
+}
+
+// parseCellSize 解析 宽x高 形式的单元格尺寸
+func parseCellSize(s string) (int, int, error) {
+	w, h, ok := strings.Cut(s, "x")
+	if !ok {
+		return 0, 0, fmt.Errorf("格式应为 宽x高: %q", s)
+	}
+	width, err := strconv.Atoi(w)
+	if err != nil || width <= 0 {
+		return 0, 0, fmt.Errorf("宽度无效: %q", w)
+	}
+	height, err := strconv.Atoi(h)
+	if err != nil || height <= 0 {
+		return 0, 0, fmt.Errorf("高度无效: %q", h)
+	}
+	return width, height, nil
+}
+
+// gridLayout 返回是否使用网格或条带布局，未设置 Layout 时按 pack 处理
+func (options *Options) gridLayout() bool {
+	return options.Layout == "grid" || options.Layout == "strip"
+}
+
+// packingGrid 按输入顺序把图片排列到网格或条带中，返回图集的网格和是否全部放下
+func packingGrid(packer *rectpack.Packer, options *Options) (rectpack.Grid, bool) {
+	gridOptions := rectpack.GridOptions{
+		CellWidth:   options.CellWidth,
+		CellHeight:  options.CellHeight,
+		Columns:     options.GridColumns,
+		Spacing:     options.GridSpacing,
+		Margin:      options.GridMargin,
+		RowPerGroup: options.RowPerAnimation,
+	}
+	var grid rectpack.Grid
+	var err error
+	if options.Layout == "strip" {
+		grid, err = packer.PackStrip(gridOptions)
+	} else {
+		grid, err = packer.PackGrid(gridOptions)
+	}
+	if err != nil {
+		fmt.Printf("网格布局失败: %v\n", err)
+		os.Exit(1)
+	}
+	return grid, len(packer.GetUnpackedRects()) == 0
+}
+
+// gridInfo 返回图集的网格元数据，非网格布局(grid 为 nil)时为 nil
+// rowPerAnimation 为 true 时同时记录每个动画所在的行
+func gridInfo(packer *rectpack.Packer, grid *rectpack.Grid, imagePaths []string, rowPerAnimation bool) *GridInfo {
+	if grid == nil {
+		return nil
+	}
+	info := &GridInfo{Columns: grid.Columns, Rows: grid.Rows, Spacing: grid.Spacing, Margin: grid.Margin}
+	info.CellSize.W, info.CellSize.H = grid.CellWidth, grid.CellHeight
+	if rowPerAnimation {
+		group := 0
+		for _, rect := range packer.GetPackedRects() {
+			if rect.Group != group {
+				_, row := grid.Cell(rect)
+				info.Animations = append(info.Animations, AnimationInfo{Name: animationName(imagePaths[rect.ID]), Row: row})
+				group = rect.Group
+			}
+			info.Animations[len(info.Animations)-1].Frames++
+		}
+	}
+	return info
+}